@@ -7,6 +7,8 @@ require (
 	github.com/go-chi/render v1.0.1
 	github.com/lib/pq v1.10.4
 	github.com/notnil/chess v1.7.0
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 replace github.com/notnil/chess => ../chess
\ No newline at end of file
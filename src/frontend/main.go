@@ -1,11 +1,17 @@
 package main
 
 import (
+	"flag"
 	"net/http"
+	"os"
 
 	. "remotechess/src/frontend/appcore"
 	"remotechess/src/rc_server"
+	"remotechess/src/rc_server/config"
+	"remotechess/src/rc_server/rcdb"
+	"remotechess/src/rc_server/rcdb/migrations"
 	"remotechess/src/rc_server/servercore"
+	"remotechess/src/rc_server/storage/postgres"
 
 	_ "github.com/lib/pq"
 )
@@ -13,13 +19,53 @@ import (
 func main() {
 	var app AppCore
 
-	app.Server = servercore.NewServerCore()
+	configPath := flag.String("config", os.Getenv("REMOTECHESS_CONFIG"), "YAML configuration file, REMOTECHESS_* environment variables override it")
+	flag.Parse()
 
-	rc_server.InitServer()
+	cfg, err := config.Load(*configPath)
+
+	if err != nil {
+		println("ERROR - " + err.Error())
+		os.Exit(1)
+	}
+
+	db, err := rcdb.ConnectToDb(cfg.Database)
+
+	if err != nil {
+		println("ERROR - database: " + err.Error())
+		os.Exit(1)
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(db, args[1:]); err != nil {
+			println("ERROR - migrate: " + err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	// Serving against an older schema would fail on the first query that needs what is missing
+	if err := migrations.CheckCurrent(db); err != nil {
+		println("ERROR - " + err.Error())
+		os.Exit(1)
+	}
+
+	app.Server = servercore.NewServerCore(cfg, postgres.NewRepositories(db))
+
+	rc_server.InitServer(&app.Server)
 
 	rc_server.Routes(&app.Server)
 	Routes(&app)
 
-	println("Beginning to listen on localhost:3000")
-	http.ListenAndServe(":3000", app.Server.Router)
+	app.Server.Log.Info("Beginning to listen on " + cfg.Server.ListenAddress)
+
+	if cfg.TLSEnabled() {
+		err = http.ListenAndServeTLS(cfg.Server.ListenAddress, cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile, app.Server.Router)
+	} else {
+		err = http.ListenAndServe(cfg.Server.ListenAddress, app.Server.Router)
+	}
+
+	app.Server.Log.Error(err.Error())
+	os.Exit(1)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"remotechess/src/rc_server/rcdb/migrations"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status | baseline <version>"
+
+// The `migrate` command: apply, revert or list the schema migrations built into the binary,
+// or adopt a database set up before them
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrations.Up(db)
+
+		for _, m := range done {
+			println("Applied " + m.String())
+		}
+
+		if err == nil && len(done) == 0 {
+			println("Schema is up to date")
+		}
+
+		return err
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+
+			if err != nil || n <= 0 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+
+			steps = n
+		}
+
+		done, err := migrations.Down(db, steps)
+
+		for _, m := range done {
+			println("Reverted " + m.String())
+		}
+
+		return err
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf(migrateUsage)
+		}
+
+		version, err := strconv.Atoi(args[1])
+
+		if err != nil || version <= 0 {
+			return fmt.Errorf("version must be a positive number, got %q", args[1])
+		}
+
+		done, err := migrations.Baseline(db, version)
+
+		for _, m := range done {
+			println("Marked " + m.String() + " as applied")
+		}
+
+		return err
+	case "status":
+		statuses, err := migrations.Status(db)
+
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+
+			if s.Unknown {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05") + " (not in this build)"
+			} else if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			println(fmt.Sprintf("%04d_%s\t%s", s.Version, s.Name, state))
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf(migrateUsage)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"remotechess/src/rc_server/logging"
 	sv "remotechess/src/rc_server/service"
 
 	"github.com/go-chi/render"
@@ -37,8 +38,7 @@ func NewErrResponseFromServiceErr(err error, httpStatus int, obscureType Obscure
 	serviceError, ok := err.(*sv.ServiceError)
 
 	if !ok {
-		obscure = true
-		println("UNSUPPORTED SERVICE ERROR " + err.Error())
+		// Rendering logs it, since it is obscured
 		return NewErrResponse("UNSUPPORTED SERVICE ERROR "+err.Error(), 500, true)
 	}
 
@@ -57,7 +57,7 @@ func (this *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	render.Status(r, this.StatusCode)
 
 	if this.obscured {
-		println("ERROR - " + fmt.Sprint(this.StatusCode) + " " + this.Detail)
+		logging.FromContext(r.Context()).Error(fmt.Sprint(this.StatusCode) + " " + this.Detail)
 		this.StatusCode = 500
 		this.Detail = "Internal server error"
 	}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	. "remotechess/src/rc_server/api"
+	"remotechess/src/rc_server/api/utility"
+	. "remotechess/src/rc_server/servercore"
+)
+
+type AuthHandler struct {
+	server *ServerCore
+}
+
+func NewAuthHandler(s *ServerCore) AuthHandler {
+	return AuthHandler{s}
+}
+
+func (ah *AuthHandler) Router(router chi.Router) {
+	router.With(utility.RequireFeature(ah.server.Config.Features.Registration, "Registration")).Post("/register", ah.Register)
+	router.Post("/login", ah.Login)
+
+	router.Group(func(g chi.Router) {
+		g.Use(RequireUser)
+		g.Post("/logout", ah.Logout)
+	})
+}
+
+func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Render(w, r, NewErrResponse("Invalid request body", 400, false))
+		return
+	}
+
+	user, err := ah.server.Users.RegisterUser(req.Email, req.Username, req.Password)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, &RegisterResponse{*NewSuccessResponse(), user.Id, user.Username})
+}
+
+func (ah *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Render(w, r, NewErrResponse("Invalid request body", 400, false))
+		return
+	}
+
+	session, err := ah.server.Users.Login(req.Login, req.Password)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, &SessionResponse{
+		GenericResponse: *NewSuccessResponse(),
+		Token:           session.Token,
+		UserId:          session.User.Id,
+		Username:        session.User.Username,
+		ExpiresAt:       session.ExpiresAt.UnixMilli(),
+	})
+}
+
+func (ah *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token, ok := ctx.Value("sessionToken").(string)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := ah.server.Users.Logout(token)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewSuccessResponse())
+}
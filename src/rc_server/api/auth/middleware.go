@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	. "remotechess/src/rc_server/api"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+
+	"github.com/go-chi/render"
+)
+
+// Header physical chessboards put their device secret in
+const BoardSecretHeader = "X-Board-Secret"
+
+// Put the user of the request's bearer token, if any, into the "authUser" context value.
+// Requests without a token carry on anonymously; requests with a bad one are rejected.
+func Authenticate(users *UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := users.FetchSessionUser(token)
+
+			if err != nil {
+				render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "authUser", user)
+			ctx = context.WithValue(ctx, "sessionToken", token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+
+	return ""
+}
+
+// The user the request was authenticated as, or nil for anonymous requests
+func AuthUser(r *http.Request) *UserCore {
+	user, _ := r.Context().Value("authUser").(*UserCore)
+	return user
+}
+
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if AuthUser(r) == nil {
+			render.Render(w, r, newNotLoggedInResponse())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Only let the request through if the caller is the user stored in the given context value
+func RequireSelf(ctxUserName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := AuthUser(r)
+			user, ok := r.Context().Value(ctxUserName).(*UserCore)
+
+			if caller == nil {
+				render.Render(w, r, newNotLoggedInResponse())
+			} else if !ok || user.Id != caller.Id {
+				render.Render(w, r, NewErrResponse("You can only do this for your own account", 403, false))
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Only let the request through if the caller owns at least one of the chessboards stored in the given context values
+func RequireBoardOwner(ctxBoardNames ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := AuthUser(r)
+
+			if caller == nil {
+				render.Render(w, r, newNotLoggedInResponse())
+				return
+			}
+
+			for _, name := range ctxBoardNames {
+				if board, ok := r.Context().Value(name).(*Chessboard); ok && ownsBoard(caller, board) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			render.Render(w, r, NewErrResponse("You do not own this chessboard", 403, false))
+		})
+	}
+}
+
+// Only let the request through if it comes from one of the chessboards stored in the given context values,
+// authenticated by its device secret, or from a user who owns one of them
+func RequireBoardAccess(service *BoardService, ctxBoardNames ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			boards := []*Chessboard{}
+
+			for _, name := range ctxBoardNames {
+				if board, ok := r.Context().Value(name).(*Chessboard); ok {
+					boards = append(boards, board)
+				}
+			}
+
+			if checkBoardAccess(w, r, service, boards, "You do not control this chessboard") {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Only let the request through if it comes from one of the boards playing the game stored in the given context value,
+// or from a user who owns one of them
+func RequireGamePlayer(service *BoardService, ctxGameName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			game, ok := r.Context().Value(ctxGameName).(*ChessGame)
+
+			if !ok {
+				render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+				return
+			}
+
+			if checkBoardAccess(w, r, service, []*Chessboard{&game.White, &game.Black}, "You are not playing in this game") {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Render an error and return false unless the request was made by one of the boards or by one of their owners
+func checkBoardAccess(w http.ResponseWriter, r *http.Request, service *BoardService, boards []*Chessboard, forbidden string) bool {
+	if err := boardAccessError(r, service, boards, forbidden); err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return false
+	}
+
+	return true
+}
+
+func boardAccessError(r *http.Request, service *BoardService, boards []*Chessboard, forbidden string) error {
+	if secret := r.Header.Get(BoardSecretHeader); secret != "" {
+		var err error = sv.NewGenericError(forbidden, 403, sv.NOT_SENSITIVE)
+
+		// A board's secret only identifies that board, try it against each candidate
+		for _, board := range boards {
+			if board.OnboardId == 0 {
+				continue
+			}
+
+			if err = service.Authenticate(board, secret); err == nil {
+				return nil
+			}
+		}
+
+		return err
+	}
+
+	caller := AuthUser(r)
+
+	if caller == nil {
+		return sv.NewGenericError("You must be logged in to do this", 401, sv.NOT_SENSITIVE)
+	}
+
+	for _, board := range boards {
+		if ownsBoard(caller, board) {
+			return nil
+		}
+	}
+
+	return sv.NewGenericError(forbidden, 403, sv.NOT_SENSITIVE)
+}
+
+// Only let the request through if the game stored in the given context value is visible to the caller.
+// Unless allowDelayed is set, spectators of an ongoing game with a broadcast delay are turned away,
+// since anything but the delayed spectator feed would show them the live position.
+func RequireGameViewer(service *BoardService, ctxGameName string, allowDelayed bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			game, ok := r.Context().Value(ctxGameName).(*ChessGame)
+
+			if !ok {
+				render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+				return
+			}
+
+			if boardAccessError(r, service, []*Chessboard{&game.White, &game.Black}, "") == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			visible, err := game.CanSpectate(AuthUser(r))
+
+			if err != nil {
+				render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			} else if !visible {
+				render.Render(w, r, NewErrResponse("You cannot watch this game", 403, false))
+			} else if !allowDelayed && game.BroadcastDelay > 0 && game.GetOutcome() == NO_OUTCOME {
+				render.Render(w, r, NewErrResponse("This game is broadcast with a delay, watch it through /spectate", 403, false))
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func ownsBoard(user *UserCore, board *Chessboard) bool {
+	return board.OwnerId.Valid && uint64(board.OwnerId.Int64) == user.Id
+}
+
+func newNotLoggedInResponse() *ErrResponse {
+	return NewErrResponse("You must be logged in to do this", 401, false)
+}
@@ -0,0 +1,12 @@
+package auth
+
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Login    string `json:"login"` // Username or email
+	Password string `json:"password"`
+}
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	. "remotechess/src/rc_server/api"
+)
+
+type RegisterResponse struct {
+	GenericResponse
+	Id       uint64 `json:"id"`
+	Username string `json:"username"`
+}
+
+type SessionResponse struct {
+	GenericResponse
+	Token     string `json:"token"`
+	UserId    uint64 `json:"userId"`
+	Username  string `json:"username"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"net/http"
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
+	apievents "remotechess/src/rc_server/api/events"
 	"remotechess/src/rc_server/api/games"
 	"remotechess/src/rc_server/api/utility"
 	. "remotechess/src/rc_server/servercore"
 	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/events"
 	. "remotechess/src/rc_server/service/games"
 
 	"github.com/go-chi/chi/v5"
@@ -30,7 +33,7 @@ func (cbh *ChessboardHandler) Router(router chi.Router) {
 
 	router.Group(func(r chi.Router) {
 		r.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "chessboard", func(x uint64) (interface{}, error) {
-			return FetchChessboard(x)
+			return cbh.server.Boards.FetchChessboard(x)
 		}))
 
 		r.Group(func(r chi.Router) {
@@ -38,19 +41,30 @@ func (cbh *ChessboardHandler) Router(router chi.Router) {
 			r.Get("/print", cbh.GetPretty)
 		})
 
-		r.Get("/currentgame", cbh.CurrentGame)
-		r.Get("/leavegame", cbh.LeaveGame)
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/leavegame", cbh.LeaveGame)
+
+		// These show the board's games live, so spectators have to go through the game instead
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/currentgame", cbh.CurrentGame)
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/events", cbh.Events)
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/ongoing", cbh.OngoingGames)
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard"), utility.CtxIntFromURL("gameId", "Game ID")).Get("/focus/{gameId}", cbh.FocusGame)
+
+		// The history lists private and friends-only games too
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard"), games.CtxGameFilterFromQuery).Get("/games", cbh.Games)
+
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/rekey", cbh.Rekey)
+		r.With(RequireBoardOwner("chessboard")).Get("/revoke", cbh.Revoke)
 	})
 
 	router.Group(func(r chi.Router) {
 		r.Use(render.SetContentType(render.ContentTypePlainText))
 
 		r.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "chessboard", func(x uint64) (interface{}, error) {
-			return FetchChessboard(x)
+			return cbh.server.Boards.FetchChessboard(x)
 		}))
 
 		r.Get("/print", cbh.GetPretty)
-		r.Get("/leavegame", cbh.LeaveGame)
+		r.With(RequireBoardAccess(cbh.server.Boards, "chessboard")).Get("/leavegame", cbh.LeaveGame)
 	})
 }
 
@@ -87,7 +101,49 @@ func (cbh *ChessboardHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := RegisterNewChessboard(uint64(boardId))
+	board, secret, err := cbh.server.Boards.RegisterNewChessboard(uint64(boardId))
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, &BoardSecretResponse{*NewSuccessResponse(), board.OnboardId, secret})
+}
+
+// Issue the board a new device secret. Boards call this to rotate their own secret,
+// owners call it to re-provision a board after revoking it.
+func (cbh *ChessboardHandler) Rekey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	secret, err := cbh.server.Boards.RotateSecret(board)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, &BoardSecretResponse{*NewSuccessResponse(), board.OnboardId, secret})
+}
+
+func (cbh *ChessboardHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := cbh.server.Boards.RevokeSecret(board)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -107,7 +163,7 @@ func (cbh *ChessboardHandler) CurrentGame(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	game, err := FetchCurrentGame(board)
+	game, err := cbh.server.Games.FetchFocusedGame(board)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -131,7 +187,7 @@ func (cbh *ChessboardHandler) LeaveGame(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := board.LeaveGame()
+	err := cbh.server.Boards.LeaveGame(board)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -140,3 +196,111 @@ func (cbh *ChessboardHandler) LeaveGame(w http.ResponseWriter, r *http.Request)
 
 	render.Render(w, r, NewSuccessResponse())
 }
+
+// Every unfinished game the board plays in, for choosing which one to show
+func (cbh *ChessboardHandler) OngoingGames(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	ongoing, err := cbh.server.Games.FetchOngoingGames(*board)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewOngoingGamesResponse(*board, ongoing))
+}
+
+func (cbh *ChessboardHandler) FocusGame(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok1 := ctx.Value("chessboard").(*Chessboard)
+	gameId, ok2 := ctx.Value("gameId").(int)
+
+	if !ok1 || !ok2 || gameId < 0 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := cbh.server.Boards.FocusGame(board, uint64(gameId))
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	game, err := cbh.server.Games.FetchFocusedGame(board)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	if game.GetOutcome() == NO_OUTCOME {
+		render.Render(w, r, games.NewGameStateResponse(*game))
+	} else {
+		render.Render(w, r, games.NewWonGameStateResponse(*game))
+	}
+}
+
+func (cbh *ChessboardHandler) Games(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok1 := ctx.Value("chessboard").(*Chessboard)
+	filter, ok2 := ctx.Value("filter").(GameFilter)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	page, err := cbh.server.Games.SearchBoardGames(*board, filter)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, games.NewGameHistoryResponse(*page))
+}
+
+// Stream the events of every game this board plays in, with ids of the form <gameId>:<seq>.
+// A reconnecting board has the missed events of its focused game replayed before live ones if
+// its Last-Event-ID is from that game. Events of its other games, or of the focused game after
+// an id from another game, are not replayed, the board catches up on them through
+// /game/{id}/events/since/{seq}.
+func (cbh *ChessboardHandler) Events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	sub := cbh.server.Events.SubscribeBoard(board.OnboardId)
+	backlog := []Event{}
+	gameId, since := apievents.ParseBoardSince(r)
+
+	// A bare sequence number is from before the ids named their game and refers to the focused one
+	if board.FocusedGame.Valid && (gameId == 0 || gameId == uint64(board.FocusedGame.Int64)) {
+		var err error
+		backlog, err = cbh.server.Events.FetchEventsSince(uint64(board.FocusedGame.Int64), since)
+
+		if err != nil {
+			sub.Close()
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+	}
+
+	apievents.ServeBoardEventStream(w, r, sub, backlog)
+}
@@ -1,5 +1,52 @@
 package chessboards
 
-// import (
-// 	. "remotechess/src/rc_server/api"
-// )
+import (
+	. "remotechess/src/rc_server/api"
+	"remotechess/src/rc_server/api/games"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+)
+
+type BoardSecretResponse struct {
+	GenericResponse
+	OnboardId uint64 `json:"onboardId"`
+	Secret    string `json:"secret"`
+}
+
+type ResponseOngoingGame struct {
+	GameId      uint64                    `json:"gameId"`
+	Color       string                    `json:"color"`
+	YourTurn    bool                      `json:"yourTurn"`
+	TimeControl games.ResponseTimeControl `json:"timeControl"`
+	FlagsAt     int64                     `json:"flagsAt,omitempty"` // When the player to move runs out of time, while the clock runs
+	Focused     bool                      `json:"focused"`
+}
+
+type OngoingGamesResponse struct {
+	GenericResponse
+	Games []ResponseOngoingGame `json:"games"`
+}
+
+func NewOngoingGamesResponse(board Chessboard, ongoing []*ChessGame) *OngoingGamesResponse {
+	response := OngoingGamesResponse{GenericResponse: *NewSuccessResponse(), Games: []ResponseOngoingGame{}}
+
+	for _, cg := range ongoing {
+		color, _ := cg.GetColorOfBoard(board)
+
+		og := ResponseOngoingGame{
+			GameId:      cg.Id,
+			Color:       color.String(),
+			YourTurn:    cg.GetCurrentMover().OnboardId == board.OnboardId,
+			TimeControl: games.NewResponseTimeControl(cg.Clock.TimeControl),
+			Focused:     board.FocusedGame.Valid && uint64(board.FocusedGame.Int64) == cg.Id,
+		}
+
+		if cg.ClockRunning() {
+			og.FlagsAt = cg.Clock.FlagsAt(cg.GetTurn()).UnixMilli()
+		}
+
+		response.Games = append(response.Games, og)
+	}
+
+	return &response
+}
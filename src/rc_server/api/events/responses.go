@@ -0,0 +1,39 @@
+package events
+
+import (
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/events"
+)
+
+type ResponseEvent struct {
+	GameId    uint64    `json:"gameId"`
+	Seq       uint64    `json:"seq"`
+	Kind      EventKind `json:"kind"`
+	Data      EventData `json:"data"`
+	Timestamp int64     `json:"timestamp"`
+}
+
+type EventsResponse struct {
+	GenericResponse
+	Events []ResponseEvent `json:"events"`
+}
+
+func NewResponseEvent(ev Event) ResponseEvent {
+	return ResponseEvent{
+		GameId:    ev.GameId,
+		Seq:       ev.Seq,
+		Kind:      ev.Kind,
+		Data:      ev.Data,
+		Timestamp: ev.CreatedAt.UnixMilli(),
+	}
+}
+
+func NewEventsResponse(events []Event) *EventsResponse {
+	er := EventsResponse{GenericResponse: *NewSuccessResponse(), Events: []ResponseEvent{}}
+
+	for _, ev := range events {
+		er.Events = append(er.Events, NewResponseEvent(ev))
+	}
+
+	return &er
+}
@@ -0,0 +1,199 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/events"
+
+	"github.com/go-chi/render"
+)
+
+const heartbeatInterval = 25 * time.Second
+
+// Read the last sequence number a reconnecting client has seen, either from the standard
+// Last-Event-ID header that EventSource sends or from a ?since= query parameter.
+// Returns 0 if the client has not seen any events.
+func ParseSince(r *http.Request) uint64 {
+	since := r.Header.Get("Last-Event-ID")
+
+	if since == "" {
+		since = r.URL.Query().Get("since")
+	}
+
+	seq, err := strconv.ParseUint(since, 10, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return seq
+}
+
+// Read the last event a client of a stream spanning several games has seen. Its ids are
+// <gameId>:<seq>, a bare sequence number gives a game id of 0. Returns zeroes if the client
+// has not seen any events.
+func ParseBoardSince(r *http.Request) (uint64, uint64) {
+	since := r.Header.Get("Last-Event-ID")
+
+	if since == "" {
+		since = r.URL.Query().Get("since")
+	}
+
+	gameStr, seqStr, qualified := strings.Cut(since, ":")
+
+	if !qualified {
+		gameStr, seqStr = "0", since
+	}
+
+	gameId, err1 := strconv.ParseUint(gameStr, 10, 64)
+	seq, err2 := strconv.ParseUint(seqStr, 10, 64)
+
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+
+	return gameId, seq
+}
+
+// Stream events to the client as Server-Sent Events until it disconnects or the subscription is dropped.
+// The backlog is written first; live events that are already part of the backlog are skipped.
+func ServeEventStream(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event) {
+	serveEventStream(w, r, sub, backlog, 0, seqId)
+}
+
+// Like ServeEventStream, but every event is held back until delay has passed since it happened
+func ServeDelayedEventStream(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event, delay time.Duration) {
+	serveEventStream(w, r, sub, backlog, delay, seqId)
+}
+
+// Like ServeEventStream for a subscription to several games. Sequence numbers are per game,
+// so the ids are <gameId>:<seq>, see ParseBoardSince.
+func ServeBoardEventStream(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event) {
+	serveEventStream(w, r, sub, backlog, 0, qualifiedId)
+}
+
+func seqId(ev Event) string {
+	return fmt.Sprint(ev.Seq)
+}
+
+func qualifiedId(ev Event) string {
+	return fmt.Sprintf("%d:%d", ev.GameId, ev.Seq)
+}
+
+func serveEventStream(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event, delay time.Duration, eventId func(Event) string) {
+	defer sub.Close()
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse("Streaming is not supported", 500, true))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	lastSeq := map[uint64]uint64{}
+
+	send := func(ev Event) error {
+		// Events outside the game log, like chat, have no sequence number and are never replayed
+		logged := ev.Seq != 0
+
+		if logged && ev.Seq <= lastSeq[ev.GameId] {
+			return nil
+		}
+
+		data, err := json.Marshal(NewResponseEvent(ev))
+
+		if err != nil {
+			return err
+		}
+
+		if logged {
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventId(ev), ev.Kind, data)
+		} else {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if logged {
+			lastSeq[ev.GameId] = ev.Seq
+		}
+
+		flusher.Flush()
+		return nil
+	}
+
+	// Events waiting for their delay to pass, oldest first
+	pending := []Event{}
+	release := time.NewTimer(0)
+	defer release.Stop()
+
+	// Send every pending event that is due and wait for the next one
+	flushPending := func() error {
+		now := time.Now()
+
+		for len(pending) > 0 && !now.Before(pending[0].CreatedAt.Add(delay)) {
+			if err := send(pending[0]); err != nil {
+				return err
+			}
+
+			pending = pending[1:]
+		}
+
+		if len(pending) > 0 {
+			release.Reset(time.Until(pending[0].CreatedAt.Add(delay)))
+		}
+
+		return nil
+	}
+
+	pending = append(pending, backlog...)
+
+	if flushPending() != nil {
+		return
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, open := <-sub.Events:
+			if !open {
+				return
+			}
+
+			pending = append(pending, ev)
+
+			if flushPending() != nil {
+				return
+			}
+		case <-release.C:
+			if flushPending() != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
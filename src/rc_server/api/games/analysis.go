@@ -0,0 +1,100 @@
+package games
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "remotechess/src/rc_server/api"
+	"remotechess/src/rc_server/service/engine"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Parse how long the engine may think from the query string into the "limits" context value.
+// Supported parameters:
+//
+//	depth     plies to search, at most 30
+//	movetime  milliseconds to search each position, at most 10000
+//
+// Without either the engine searches each position for a second.
+func CtxEngineLimitsFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		depth, err := queryInt(query.Get("depth"), "Depth")
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		moveTimeMs, err := queryInt(query.Get("movetime"), "Move time")
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		limits, err := engine.NewLimits(depth, time.Duration(moveTimeMs)*time.Millisecond)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "limits", limits)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The engine's best move and evaluation of the current position
+func (gh *GameHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	limits, ok2 := ctx.Value("limits").(engine.Limits)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	analysis, err := game.Evaluate(ctx, limits)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewEvaluationResponse(*analysis))
+}
+
+// The move-by-move analysis of a finished game. The first request starts the analysis, and until it is
+// done every request gets 202 Accepted.
+func (gh *GameHandler) Analysis(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	limits, ok2 := ctx.Value("limits").(engine.Limits)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	analysis, err := game.RequestAnalysis(limits)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	if analysis == nil {
+		render.Render(w, r, NewAnalysisPendingResponse())
+		return
+	}
+
+	render.Render(w, r, NewGameAnalysisResponse(*analysis))
+}
@@ -0,0 +1,55 @@
+package games
+
+import (
+	"math/rand"
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Start a game against the computer. The color is white, black or random.
+func (gh *GameHandler) CreateBotGame(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok1 := ctx.Value("board").(*Chessboard)
+	level, ok2 := ctx.Value("level").(int)
+	colorStr, ok3 := ctx.Value("color").(string)
+	settings, ok4 := ctx.Value("settings").(GameSettings)
+
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	color := PlayerColor(rand.Intn(2))
+
+	if colorStr != "random" {
+		var err error
+
+		if color, err = NewPlayerColor(colorStr); err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+	}
+
+	game, err := gh.server.Games.CreateBotGame(board, color, level, settings)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	gh.publishEvent(game, GAME_STARTED_EVENT, EventData{})
+
+	render.Render(w, r, NewGameStateResponse(*game))
+}
+
+func (gh *GameHandler) BotLevels(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, NewBotLevelsResponse(BotLevels))
+}
@@ -0,0 +1,113 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+type SendChatRequest struct {
+	Message string `json:"message"`
+}
+
+func (gh *GameHandler) ChatPresets(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, NewChatPresetsResponse(ChatPresets))
+}
+
+func (gh *GameHandler) Chat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	messages, err := game.FetchChat(*board)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewChatResponse(messages))
+}
+
+func (gh *GameHandler) SendChat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	var req SendChatRequest
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Render(w, r, NewErrResponse("Invalid request body", 400, false))
+		return
+	}
+
+	msg, err := game.SendChat(*board, req.Message)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewChatMessageResponse(*msg))
+}
+
+func (gh *GameHandler) SendChatPreset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+	presetId, ok3 := ctx.Value("presetId").(int)
+
+	if !ok1 || !ok2 || !ok3 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	msg, err := game.SendChatPreset(*board, presetId)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewChatMessageResponse(*msg))
+}
+
+func (gh *GameHandler) MuteChat(mute bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		game, ok1 := ctx.Value("game").(*ChessGame)
+		board, ok2 := ctx.Value("board").(*Chessboard)
+
+		if !ok1 || !ok2 {
+			render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+			return
+		}
+
+		err := game.MuteOpponent(*board, mute)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		render.Render(w, r, NewSuccessResponse())
+	}
+}
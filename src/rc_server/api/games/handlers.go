@@ -1,19 +1,30 @@
 package games
 
 import (
+	"database/sql"
 	"net/http"
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
+	apievents "remotechess/src/rc_server/api/events"
 	"remotechess/src/rc_server/api/utility"
 	. "remotechess/src/rc_server/servercore"
+	sv "remotechess/src/rc_server/service"
 	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
 	. "remotechess/src/rc_server/service/games"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
-	"github.com/notnil/chess"
 )
 
+const maxPgnSize = 1 << 20
+
+// Sent with a move by a board that may retry it, the same for every retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type GameHandler struct {
 	server *ServerCore
 }
@@ -23,34 +34,144 @@ func NewGameHandler(s *ServerCore) GameHandler {
 }
 
 func (gh *GameHandler) Router(router chi.Router) {
+	features := gh.server.Config.Features
+
 	router.Group(func(g chi.Router) {
 		g.Use(utility.CtxFetchFromUrl("whiteBid", "White Board ID", "whiteBoard", func(x uint64) (interface{}, error) {
-			return FetchChessboard(x)
+			return gh.server.Boards.FetchChessboard(x)
 		}))
 
 		g.Use(utility.CtxFetchFromUrl("blackBid", "Black Board ID", "blackBoard", func(x uint64) (interface{}, error) {
-			return FetchChessboard(x)
+			return gh.server.Boards.FetchChessboard(x)
 		}))
 
+		// The caller must control both boards, a game against someone else's board starts from an invite
+		g.Use(RequireBoardAccess(gh.server.Boards, "whiteBoard"))
+		g.Use(RequireBoardAccess(gh.server.Boards, "blackBoard"))
+		g.Use(CtxGameSettingsFromQuery)
+
 		g.Get("/create/w/{whiteBid}/b/{blackBid}", gh.CreateGame)
 	})
 
+	router.Group(func(g chi.Router) {
+		g.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "board", func(x uint64) (interface{}, error) {
+			return gh.server.Boards.FetchChessboard(x)
+		}))
+
+		g.Use(utility.RequireFeature(features.PgnImport, "PGN import"))
+		g.Use(RequireBoardAccess(gh.server.Boards, "board"))
+		g.Use(utility.CtxStringFromURL("color", "Color", false))
+
+		g.Post("/import/{boardId}/{color}", gh.ImportPGN)
+	})
+
+	router.Group(func(g chi.Router) {
+		g.Use(utility.RequireFeature(features.Bots, "Playing the computer"))
+
+		g.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "board", func(x uint64) (interface{}, error) {
+			return gh.server.Boards.FetchChessboard(x)
+		}))
+
+		g.Use(RequireBoardAccess(gh.server.Boards, "board"))
+		g.Use(utility.CtxIntFromURL("level", "Bot Level"))
+		g.Use(utility.CtxStringFromURL("color", "Color", false))
+		g.Use(CtxGameSettingsFromQuery)
+
+		g.Get("/bot/{boardId}/{level}/{color}", gh.CreateBotGame)
+	})
+
+	router.With(utility.RequireFeature(features.Bots, "Playing the computer")).Get("/botlevels", gh.BotLevels)
+	router.With(utility.RequireFeature(features.Chat, "Chat")).Get("/chatpresets", gh.ChatPresets)
+
 	router.Route("/{gameId}", func(game chi.Router) {
 		game.Use(utility.CtxFetchFromUrl("gameId", "Game ID", "game", func(x uint64) (interface{}, error) {
-			return FetchChessGame(x)
+			return gh.server.Games.FetchChessGame(x)
 		}))
 
-		game.Get("/gamestate", gh.GameState)
-		game.Get("/legalmoves", gh.LegalMoves)
+		game.Group(func(g chi.Router) {
+			g.Use(RequireGameViewer(gh.server.Boards, "game", false))
+
+			g.Get("/gamestate", gh.GameState)
+			g.Get("/legalmoves", gh.LegalMoves)
+			g.Get("/pgn", gh.ExportPGN)
+			g.Get("/events", gh.Events)
+
+			g.Group(func(g chi.Router) {
+				g.Use(utility.CtxIntFromURL("seq", "Sequence Number"))
+				g.Get("/events/since/{seq}", gh.EventsSince)
+			})
+		})
+
+		game.Group(func(g chi.Router) {
+			g.Use(utility.RequireFeature(features.Spectating, "Spectating"))
+			g.Use(RequireGameViewer(gh.server.Boards, "game", true))
+
+			g.Get("/spectate", gh.Spectate)
+			g.Get("/spectators", gh.Spectators)
+		})
+
+		game.Group(func(g chi.Router) {
+			g.Use(utility.RequireFeature(features.Analysis, "Analysis"))
+			g.Use(RequireGameViewer(gh.server.Boards, "game", false))
+			g.Use(CtxEngineLimitsFromQuery)
+
+			g.Get("/analysis", gh.Analysis)
+		})
+
+		game.Group(func(g chi.Router) {
+			g.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "board", func(x uint64) (interface{}, error) {
+				return gh.server.Boards.FetchChessboard(x)
+			}))
+
+			g.Use(RequireBoardAccess(gh.server.Boards, "board"))
+
+			g.Group(func(g chi.Router) {
+				g.Use(utility.RequireFeature(features.Chat, "Chat"))
+
+				g.Get("/chat/{boardId}", gh.Chat)
+				g.Post("/chat/{boardId}", gh.SendChat)
+				g.With(utility.CtxIntFromURL("presetId", "Preset ID")).Get("/chat/{boardId}/preset/{presetId}", gh.SendChatPreset)
+				g.Get("/chat/{boardId}/mute", gh.MuteChat(true))
+				g.Get("/chat/{boardId}/unmute", gh.MuteChat(false))
+			})
+
+			g.Get("/sync/{boardId}", gh.SyncBoard)
+
+			g.With(utility.CtxStringFromURL("square", "Square", false)).Get("/sensor/{boardId}/lift/{square}", gh.SensorEvent(LIFT_PIECE))
+			g.With(utility.CtxStringFromURL("square", "Square", false)).Get("/sensor/{boardId}/place/{square}", gh.SensorEvent(PLACE_PIECE))
+			g.With(utility.CtxStringFromURL("piece", "Piece", false)).Get("/sensor/{boardId}/promote/{piece}", gh.ChoosePromotion)
+			g.Get("/sensor/{boardId}/reset", gh.ResetSensor)
+
+			g.Get("/mirror/{boardId}", gh.MirrorInstructions)
+			g.Get("/mirror/{boardId}/confirm", gh.ConfirmMirrored)
+		})
+
+		game.Group(func(g chi.Router) {
+			g.Use(RequireGamePlayer(gh.server.Boards, "game"))
+			g.Use(utility.CtxStringFromURL("visibility", "Visibility", false))
+
+			g.Get("/visibility/{visibility}", gh.SetVisibility)
+		})
+
+		game.Group(func(g chi.Router) {
+			g.Use(utility.RequireFeature(features.Analysis, "Analysis"))
+			g.Use(RequireGamePlayer(gh.server.Boards, "game"))
+			g.Use(CtxEngineLimitsFromQuery)
+
+			g.Get("/evaluate", gh.Evaluate)
+		})
 
 		game.Group(func(board chi.Router) {
 			// This is temporary only for debugging purposes to easily read the board output
 			board.Use(render.SetContentType(render.ContentTypePlainText))
 
 			board.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "board", func(x uint64) (interface{}, error) {
-				return FetchChessboard(x)
+				return gh.server.Boards.FetchChessboard(x)
 			}))
 
+			board.Use(RequireBoardAccess(gh.server.Boards, "board"))
+			board.Use(AdjudicateFlag)
+
 			board.Group(func(g chi.Router) {
 				g.Use(utility.CtxStringFromURL("move", "Move UCI", false))
 				g.Get("/move/{boardId}/{move}", gh.Move)
@@ -64,12 +185,15 @@ func (gh *GameHandler) Router(router chi.Router) {
 			board.Get("/resign/{boardId}", gh.Resign)
 			board.Get("/draw/{boardId}/accept", gh.ResolveDraw(true))
 			board.Get("/draw/{boardId}/reject", gh.ResolveDraw(false))
+
+			board.With(utility.CtxIntFromURL("plies", "Plies")).Get("/takeback/{boardId}/request/{plies}", gh.RequestTakeback)
+			board.Get("/takeback/{boardId}/accept", gh.ResolveTakeback(true))
+			board.Get("/takeback/{boardId}/decline", gh.ResolveTakeback(false))
 		})
 
 		game.Group(func(g chi.Router) {
 			g.Use(render.SetContentType(render.ContentTypePlainText))
-			g.Get("/undo", gh.Undo)
-			g.Get("/print", gh.Print)
+			g.With(RequireGameViewer(gh.server.Boards, "game", false)).Get("/print", gh.Print)
 		})
 	})
 }
@@ -79,13 +203,14 @@ func (gh *GameHandler) CreateGame(w http.ResponseWriter, r *http.Request) {
 
 	white, ok1 := ctx.Value("whiteBoard").(*Chessboard)
 	black, ok2 := ctx.Value("blackBoard").(*Chessboard)
+	settings, ok3 := ctx.Value("settings").(GameSettings)
 
-	if !ok1 || !ok2 {
+	if !ok1 || !ok2 || !ok3 {
 		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
 		return
 	}
 
-	_, err := CreateChessGame(white, black)
+	_, err := gh.server.Games.CreateChessGame(white, black, settings)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -107,17 +232,20 @@ func (gh *GameHandler) Move(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := game.MakeMove(*board, move)
+	guard, ok := moveGuardFromRequest(r)
 
-	if err != nil {
-		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+	if !ok {
+		render.Render(w, r, NewErrResponse("Invalid version or idempotency key", 422, false))
 		return
 	}
 
-	err = game.Save()
+	if !guard.Version.Valid && gh.server.Config.Moves.RequireVersion {
+		render.Render(w, r, NewErrResponse("Moves must be sent with the game version they were chosen at", 428, false))
+		return
+	}
 
-	if err != nil {
-		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+	if err := gh.playMove(game, board, move, guard); err != nil {
+		gh.renderMoveError(w, r, game, err)
 		return
 	}
 
@@ -128,31 +256,49 @@ func (gh *GameHandler) Move(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (gh *GameHandler) Undo(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// The game version from ?version= and the key from the Idempotency-Key header, both optional here
+func moveGuardFromRequest(r *http.Request) (MoveGuard, bool) {
+	var guard MoveGuard
 
-	game, ok := ctx.Value("game").(*ChessGame)
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, err := strconv.ParseInt(v, 10, 64)
 
-	if !ok {
-		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
-		return
+		if err != nil || version < 0 {
+			return guard, false
+		}
+
+		guard.Version = sql.NullInt64{Int64: version, Valid: true}
 	}
 
-	err := game.UndoMove()
+	guard.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
 
-	if err != nil {
-		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
-		return
+	return guard, len(guard.IdempotencyKey) <= MAX_IDEMPOTENCY_KEY_LENGTH
+}
+
+// Play, store and announce a move, however the board came up with it. A retried move is not announced again.
+func (gh *GameHandler) playMove(game *ChessGame, board *Chessboard, move string, guard MoveGuard) error {
+	played, err := game.PlayMove(*board, move, guard)
+
+	if err != nil || !played {
+		return err
 	}
 
-	err = game.Save()
+	player, _ := game.GetColorOfBoard(*board)
+	gh.publishEvent(game, MOVE_EVENT, EventData{Player: player.String(), Move: move, Version: game.Version})
 
-	if err != nil {
-		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
-		return
+	return nil
+}
+
+// A move or takeback on a game that changed since the board last saw it is answered with where the game stands now
+func (gh *GameHandler) renderMoveError(w http.ResponseWriter, r *http.Request, game *ChessGame, err error) {
+	if sv.IsConflict(err) {
+		if current, fetchErr := gh.server.Games.FetchChessGame(game.Id); fetchErr == nil {
+			render.Render(w, r, NewStaleGameResponse(err, *current))
+			return
+		}
 	}
 
-	render.Render(w, r, NewGameStateResponse(*game))
+	render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
 }
 
 func (gh *GameHandler) Resign(w http.ResponseWriter, r *http.Request) {
@@ -180,6 +326,9 @@ func (gh *GameHandler) Resign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	player, _ := game.GetColorOfBoard(*chessboard)
+	gh.publishEvent(game, RESIGNATION_EVENT, EventData{Player: player.String()})
+
 	render.Render(w, r, NewGameStateResponse(*game))
 }
 
@@ -216,6 +365,9 @@ func (gh *GameHandler) OfferDraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	player, _ := game.GetColorOfBoard(*chessboard)
+	gh.publishEvent(game, DRAW_OFFERED_EVENT, EventData{Player: player.String(), DrawMethod: drawMethod.String()})
+
 	render.Render(w, r, NewSuccessResponse())
 }
 
@@ -231,26 +383,21 @@ func (gh *GameHandler) ResolveDraw(accept bool) func(http.ResponseWriter, *http.
 			return
 		}
 
-		var err error
+		kind := DRAW_REJECTED_EVENT
+		drawMethod := game.OfferedDraw
 
 		if accept {
-			err = game.AcceptDraw(*chessboard)
-
-			if err != nil {
-				render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
-				return
-			}
-
-			err = game.Save()
-		} else {
-			err = game.RejectDraw(*chessboard)
+			kind = DRAW_ACCEPTED_EVENT
 		}
 
-		if err != nil {
+		if err := game.ResolveDraw(*chessboard, accept); err != nil {
 			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
 			return
 		}
 
+		player, _ := game.GetColorOfBoard(*chessboard)
+		gh.publishEvent(game, kind, EventData{Player: player.String(), DrawMethod: drawMethod.String()})
+
 		render.Render(w, r, NewSuccessResponse())
 	}
 }
@@ -279,7 +426,7 @@ func (gh *GameHandler) GameState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if game.Game.Outcome() == chess.NoOutcome {
+	if game.GetOutcome() == NO_OUTCOME {
 		render.Render(w, r, NewGameStateResponse(*game))
 	} else {
 		render.Render(w, r, NewWonGameStateResponse(*game))
@@ -298,3 +445,126 @@ func (gh *GameHandler) LegalMoves(w http.ResponseWriter, r *http.Request) {
 
 	render.Render(w, r, NewLegalMovesResponse(*game))
 }
+
+func (gh *GameHandler) Events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok := ctx.Value("game").(*ChessGame)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	// Subscribe before reading the backlog so nothing published in between is lost
+	sub := gh.server.Events.SubscribeGame(game.Id)
+	backlog, err := gh.server.Events.FetchEventsSince(game.Id, apievents.ParseSince(r))
+
+	if err != nil {
+		sub.Close()
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	apievents.ServeEventStream(w, r, sub, backlog)
+}
+
+func (gh *GameHandler) EventsSince(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	seq, ok2 := ctx.Value("seq").(int)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	events, err := gh.server.Events.FetchEventsSince(game.Id, uint64(seq))
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, apievents.NewEventsResponse(events))
+}
+
+// The action has already been committed by the time an event is published,
+// so a failure here is logged rather than reported to the client
+func (gh *GameHandler) publishEvent(game *ChessGame, kind EventKind, data EventData) {
+	if err := game.PublishEvent(kind, data); err != nil {
+		gh.server.Log.Error("publishing " + string(kind) + " event: " + err.Error())
+	}
+}
+
+func (gh *GameHandler) ExportPGN(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok := ctx.Value("game").(*ChessGame)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	pgn, err := game.ExportPGN()
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"game-"+strconv.FormatUint(game.Id, 10)+".pgn\"")
+	w.Write([]byte(pgn))
+}
+
+// Import a finished game played elsewhere, sent as PGN in the request body.
+// The board in the URL played the given color in it.
+func (gh *GameHandler) ImportPGN(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok1 := ctx.Value("board").(*Chessboard)
+	colorStr, ok2 := ctx.Value("color").(string)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	color, err := NewPlayerColor(colorStr)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	game, err := gh.server.Games.ImportPGN(*board, color, http.MaxBytesReader(w, r.Body, maxPgnSize))
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewWonGameStateResponse(*game))
+}
+
+// Fetching a game leaves a fallen flag to the flag scheduler, but the players act on the adjudicated game
+func AdjudicateFlag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		game, ok := r.Context().Value("game").(*ChessGame)
+
+		if !ok {
+			render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+			return
+		}
+
+		if _, err := game.CheckFlag(); err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
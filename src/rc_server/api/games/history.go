@@ -0,0 +1,125 @@
+package games
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	. "remotechess/src/rc_server/api"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Parse a game history search from the query string into the "filter" context value.
+// Supported parameters:
+//
+//	color     white or black, the color the searched player had
+//	opponent  user ID of the other player
+//	result    win, loss, draw or ongoing
+//	method    how the game ended, e.g. checkmate or timeout
+//	variant   standard, chess960, king_of_the_hill or three_check
+//	from, to  dates (2006-01-02) or RFC 3339 times bounding when the game was created
+//	limit     games per page, at most 100
+//	offset    games to skip
+func CtxGameFilterFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseGameFilter(r)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "filter", filter)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func parseGameFilter(r *http.Request) (GameFilter, error) {
+	query := r.URL.Query()
+	filter := GameFilter{}
+	var err error
+
+	if query.Get("color") != "" {
+		filter.Color.PlayerColor, err = NewPlayerColor(query.Get("color"))
+
+		if err != nil {
+			return filter, err
+		}
+
+		filter.Color.Valid = true
+	}
+
+	if query.Get("opponent") != "" {
+		opponent, err := queryInt(query.Get("opponent"), "Opponent")
+
+		if err != nil {
+			return filter, err
+		}
+
+		filter.Opponent = sql.NullInt64{Int64: int64(opponent), Valid: true}
+	}
+
+	if query.Get("result") != "" {
+		if filter.Result, err = ParseHistoryResult(query.Get("result")); err != nil {
+			return filter, err
+		}
+	}
+
+	if query.Get("method") != "" {
+		method := GameMethodFromString(strings.ToUpper(query.Get("method")))
+
+		if method == NO_METHOD {
+			return filter, sv.NewInvalidInputError("Method")
+		}
+
+		filter.Method = &method
+	}
+
+	if query.Get("variant") != "" {
+		variant, err := GameVariantFromString(query.Get("variant"))
+
+		if err != nil {
+			return filter, err
+		}
+
+		filter.Variant = &variant
+	}
+
+	if filter.From, err = queryTime(query.Get("from"), "From"); err != nil {
+		return filter, err
+	}
+
+	if filter.To, err = queryTime(query.Get("to"), "To"); err != nil {
+		return filter, err
+	}
+
+	if filter.Limit, err = queryInt(query.Get("limit"), "Limit"); err != nil {
+		return filter, err
+	}
+
+	if filter.Offset, err = queryInt(query.Get("offset"), "Offset"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func queryTime(value string, displayName string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return sql.NullTime{Time: t, Valid: true}, nil
+		}
+	}
+
+	return sql.NullTime{}, sv.NewInvalidInputError(displayName)
+}
@@ -0,0 +1,55 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// The steps the board of the player to move has to carry out to reproduce the opponent's last move
+func (gh *GameHandler) MirrorInstructions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	instructions := []BoardInstruction{}
+
+	if game.AwaitingMirror() && game.GetCurrentMover().OnboardId == board.OnboardId {
+		instructions = game.MirrorInstructions()
+	}
+
+	render.Render(w, r, NewMirrorResponse(instructions))
+}
+
+func (gh *GameHandler) ConfirmMirrored(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	if err := game.ConfirmMirrored(*board); err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	player, _ := game.GetColorOfBoard(*board)
+	gh.publishEvent(game, MOVE_MIRRORED_EVENT, EventData{Player: player.String()})
+
+	render.Render(w, r, NewGameStateResponse(*game))
+}
@@ -2,10 +2,15 @@ package games
 
 import (
 	"fmt"
+	"net/http"
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/engine"
 	. "remotechess/src/rc_server/service/games"
 	"strings"
+	"time"
 
+	"github.com/go-chi/render"
 	"github.com/notnil/chess"
 )
 
@@ -20,17 +25,51 @@ type ResponseMove struct {
 	Castle      string `json:"castle"`
 }
 
+type ResponseTimeControl struct {
+	Kind        string `json:"kind"`
+	BaseMs      int64  `json:"baseMs"`
+	IncrementMs int64  `json:"incrementMs"`
+	DaysPerMove int    `json:"daysPerMove"`
+}
+
+type ResponseClock struct {
+	TimeControl ResponseTimeControl `json:"timeControl"`
+	WhiteMs     int64               `json:"whiteMs"`
+	BlackMs     int64               `json:"blackMs"`
+	Running     bool                `json:"running"`
+	FlagsAt     int64               `json:"flagsAt"`
+}
+
 type GameStateResponse struct {
 	GenericResponse
 	boardPretty    string
-	Id             uint64       `json:"id"`
-	Pieces         string       `json:"pieces"`
-	Turn           string       `json:"turn"`
-	LastMove       ResponseMove `json:"lastMove"`
-	InCheck        bool         `json:"check"`
-	GameOver       bool         `json:"gameOver"`
-	OfferedDraw    string       `json:"offeredDraw"`
-	OfferingPlayer string       `json:"offeringPlayer"`
+	Id             uint64          `json:"id"`
+	Version        int64           `json:"version"` // Sent back with the next move
+	Pieces         string          `json:"pieces"`
+	Turn           string          `json:"turn"`
+	LastMove       ResponseMove    `json:"lastMove"`
+	InCheck        bool            `json:"check"`
+	GameOver       bool            `json:"gameOver"`
+	OfferedDraw    string          `json:"offeredDraw"`
+	OfferingPlayer string          `json:"offeringPlayer"`
+	Clock          ResponseClock   `json:"clock"`
+	Archived       bool            `json:"archived"`
+	StartFen       string          `json:"startFen,omitempty"`
+	Variant        string          `json:"variant"`
+	Rated          bool            `json:"rated"`
+	Visibility     string          `json:"visibility"`
+	BroadcastDelay int64           `json:"broadcastDelayMs,omitempty"`
+	Takebacks      bool            `json:"takebacks"`
+	TakebackPlies  int             `json:"takebackPlies,omitempty"`
+	TakebackPlayer string          `json:"takebackPlayer,omitempty"`
+	AwaitingMirror bool            `json:"awaitingMirror"` // The player to move must confirm the opponent's move is on their board
+	Checks         *ResponseChecks `json:"checks,omitempty"`
+}
+
+// Only reported for Three-check games
+type ResponseChecks struct {
+	White int `json:"white"`
+	Black int `json:"black"`
 }
 
 type WonGameStateResponse struct {
@@ -39,17 +78,36 @@ type WonGameStateResponse struct {
 	Method  string `json:"method"`
 }
 
+// A 409 for a move chosen on an outdated game, with the game as it is now
+type StaleGameResponse struct {
+	*ErrResponse
+	Game interface{} `json:"game"`
+}
+
+func NewStaleGameResponse(err error, cg ChessGame) *StaleGameResponse {
+	sgr := StaleGameResponse{ErrResponse: NewErrResponseFromServiceErr(err, 409, ERROR_NOT_OBSCURED)}
+
+	if cg.GetOutcome() == NO_OUTCOME {
+		sgr.Game = NewGameStateResponse(cg)
+	} else {
+		sgr.Game = NewWonGameStateResponse(cg)
+	}
+
+	return &sgr
+}
+
 type LegalMovesResponse struct {
 	GenericResponse
 	Moves []ResponseMove `json:"moves"`
 }
 
-func newResponseMove(move *chess.Move) ResponseMove {
+// The move as played from pos
+func newResponseMove(pos *chess.Position, move *chess.Move) ResponseMove {
 	castle := ""
 
-	if move.HasTag(chess.KingSideCastle) {
+	if side, ok := CastlingSide(pos, move); ok && side == chess.KingSide {
 		castle = "K"
-	} else if move.HasTag(chess.QueenSideCastle) {
+	} else if ok {
 		castle = "Q"
 	}
 
@@ -67,25 +125,79 @@ func newResponseMove(move *chess.Move) ResponseMove {
 	return rm
 }
 
+func NewResponseTimeControl(tc TimeControl) ResponseTimeControl {
+	return ResponseTimeControl{
+		Kind:        tc.Kind.String(),
+		BaseMs:      tc.Base.Milliseconds(),
+		IncrementMs: tc.Increment.Milliseconds(),
+		DaysPerMove: tc.DaysPerMove,
+	}
+}
+
+func newResponseClock(cg ChessGame) ResponseClock {
+	rc := ResponseClock{
+		TimeControl: NewResponseTimeControl(cg.Clock.TimeControl),
+		WhiteMs:     cg.GetRemainingTime(PLAYER_WHITE).Milliseconds(),
+		BlackMs:     cg.GetRemainingTime(PLAYER_BLACK).Milliseconds(),
+		Running:     cg.ClockRunning(),
+	}
+
+	if rc.Running {
+		rc.FlagsAt = cg.Clock.FlagsAt(cg.GetTurn()).UnixMilli()
+	}
+
+	return rc
+}
+
+func (rc *ResponseClock) String() string {
+	if rc.TimeControl.Kind == UNTIMED.String() {
+		return rc.TimeControl.Kind
+	}
+
+	white := time.Duration(rc.WhiteMs) * time.Millisecond
+	black := time.Duration(rc.BlackMs) * time.Millisecond
+
+	return fmt.Sprintf("%s White: %s Black: %s Running: %t", rc.TimeControl.Kind, white, black, rc.Running)
+}
+
 func NewGameStateResponse(cg ChessGame) *GameStateResponse {
 	var gsr GameStateResponse
 
 	gsr.GenericResponse = *NewSuccessResponse()
 	gsr.Id = cg.Id
+	gsr.Version = cg.Version
 	gsr.boardPretty = cg.PrintBoard()
 	gsr.Pieces = strings.Split(cg.GetFEN(), " ")[0]
 	gsr.Turn = cg.GetTurn().String()
 
-	lastMove := cg.GetMove(-1)
-
-	if lastMove != nil {
-		gsr.LastMove = newResponseMove(lastMove)
-		gsr.InCheck = lastMove.HasTag(chess.Check)
+	if plies := len(cg.Moves()); plies > 0 {
+		gsr.LastMove = newResponseMove(cg.Positions()[plies-1], cg.GetMove(-1))
 	}
 
+	gsr.InCheck = cg.InCheck()
+
 	gsr.GameOver = cg.GetOutcome() != NO_OUTCOME
 	gsr.OfferedDraw = cg.OfferedDraw.String()
 	gsr.OfferingPlayer = cg.OfferingPlayer.String()
+	gsr.Clock = newResponseClock(cg)
+	gsr.Archived = cg.Archived
+	gsr.StartFen = cg.StartFen
+	gsr.Variant = cg.Variant.String()
+	gsr.Rated = cg.Rated
+	gsr.Visibility = cg.Visibility.String()
+	gsr.BroadcastDelay = cg.BroadcastDelay.Milliseconds()
+	gsr.Takebacks = cg.Takebacks
+	gsr.AwaitingMirror = cg.AwaitingMirror()
+
+	if cg.TakebackPlies != 0 {
+		gsr.TakebackPlies = cg.TakebackPlies
+		gsr.TakebackPlayer = cg.TakebackPlayer.String()
+	}
+
+	if cg.Variant == THREE_CHECK_VARIANT {
+		white, black := cg.CountChecks()
+		gsr.Checks = &ResponseChecks{White: white, Black: black}
+	}
 
 	return &gsr
 }
@@ -109,7 +221,7 @@ func NewLegalMovesResponse(cg ChessGame) *LegalMovesResponse {
 	lmr.Success = true
 
 	for _, move := range cg.GetLegalMoves() {
-		lmr.Moves = append(lmr.Moves, newResponseMove(move))
+		lmr.Moves = append(lmr.Moves, newResponseMove(cg.Game.Position(), move))
 	}
 
 	return &lmr
@@ -127,7 +239,329 @@ func (gsr *GameStateResponse) String() string {
 		"In Check:\t\t%t\n" +
 		"Game Over:\t\t%t\n" +
 		"Offered Draw:\t%s\n" +
-		"Offering Player:\t%s\n"
+		"Offering Player:\t%s\n" +
+		"Clock:\t\t\t%s\n" +
+		"Variant:\t\t%s\n"
+
+	return fmt.Sprintf(format, gsr.boardPretty, gsr.Pieces, gsr.Turn, gsr.LastMove.String(), gsr.InCheck, gsr.GameOver, gsr.OfferedDraw, gsr.OfferingPlayer, gsr.Clock.String(), gsr.Variant)
+}
+
+type ResponseGameSummary struct {
+	Id          uint64 `json:"id"`
+	WhiteBoard  uint64 `json:"whiteBoard,omitempty"`
+	BlackBoard  uint64 `json:"blackBoard,omitempty"`
+	WhiteUserId uint64 `json:"whiteUserId,omitempty"`
+	BlackUserId uint64 `json:"blackUserId,omitempty"`
+	White       string `json:"white"`
+	Black       string `json:"black"`
+	Outcome     string `json:"outcome"`
+	Method      string `json:"method"`
+	Variant     string `json:"variant"`
+	Rated       bool   `json:"rated"`
+	TimeControl string `json:"timeControl"`
+	MoveCount   int    `json:"moveCount"`
+	CreatedAt   int64  `json:"createdAt"`
+	EndedAt     int64  `json:"endedAt,omitempty"`
+}
+
+type GameHistoryResponse struct {
+	GenericResponse
+	Games  []ResponseGameSummary `json:"games"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+func NewGameHistoryResponse(page GameHistoryPage) *GameHistoryResponse {
+	resp := GameHistoryResponse{
+		GenericResponse: *NewSuccessResponse(),
+		Games:           make([]ResponseGameSummary, len(page.Games)),
+		Total:           page.Total,
+		Limit:           page.Limit,
+		Offset:          page.Offset,
+	}
+
+	for i, gs := range page.Games {
+		summary := ResponseGameSummary{
+			Id:          gs.Id,
+			WhiteBoard:  uint64(gs.WhiteBoard.Int64),
+			BlackBoard:  uint64(gs.BlackBoard.Int64),
+			WhiteUserId: uint64(gs.WhiteUserId.Int64),
+			BlackUserId: uint64(gs.BlackUserId.Int64),
+			White:       gs.WhiteName.String,
+			Black:       gs.BlackName.String,
+			Outcome:     gs.Outcome.ToStore(),
+			Method:      gs.Method.String(),
+			Variant:     gs.Variant.String(),
+			Rated:       gs.Rated,
+			TimeControl: gs.TimeControl.String(),
+			MoveCount:   gs.MoveCount,
+			CreatedAt:   gs.CreatedAt.UnixMilli(),
+		}
+
+		if gs.EndedAt.Valid {
+			summary.EndedAt = gs.EndedAt.Time.UnixMilli()
+		}
+
+		resp.Games[i] = summary
+	}
+
+	return &resp
+}
+
+type ResponseSpectator struct {
+	UserId   uint64 `json:"userId,omitempty"`
+	Username string `json:"username,omitempty"`
+	Since    int64  `json:"since"`
+}
+
+type SpectatorsResponse struct {
+	GenericResponse
+	Count      int                 `json:"count"`
+	Spectators []ResponseSpectator `json:"spectators"`
+}
+
+// Anonymous spectators are counted but not named
+func NewSpectatorsResponse(spectators []Spectator) *SpectatorsResponse {
+	resp := SpectatorsResponse{GenericResponse: *NewSuccessResponse(), Count: len(spectators), Spectators: []ResponseSpectator{}}
+
+	for _, s := range spectators {
+		resp.Spectators = append(resp.Spectators, ResponseSpectator{s.UserId, s.Username, s.Since.UnixMilli()})
+	}
+
+	return &resp
+}
+
+type ResponseChatMessage struct {
+	Id       uint64 `json:"id"`
+	Player   string `json:"player"`
+	PresetId int    `json:"presetId,omitempty"`
+	Message  string `json:"message"`
+	Time     int64  `json:"time"`
+}
+
+type ChatMessageResponse struct {
+	GenericResponse
+	ResponseChatMessage
+}
+
+type ChatResponse struct {
+	GenericResponse
+	Messages []ResponseChatMessage `json:"messages"`
+}
+
+type ResponseChatPreset struct {
+	Id   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+type ChatPresetsResponse struct {
+	GenericResponse
+	Presets []ResponseChatPreset `json:"presets"`
+}
+
+func newResponseChatMessage(msg ChatMessage) ResponseChatMessage {
+	return ResponseChatMessage{msg.Id, msg.Player.String(), int(msg.PresetId.Int64), msg.Body, msg.CreatedAt.UnixMilli()}
+}
+
+func NewChatMessageResponse(msg ChatMessage) *ChatMessageResponse {
+	return &ChatMessageResponse{*NewSuccessResponse(), newResponseChatMessage(msg)}
+}
+
+func NewChatResponse(messages []ChatMessage) *ChatResponse {
+	resp := ChatResponse{GenericResponse: *NewSuccessResponse(), Messages: []ResponseChatMessage{}}
+
+	for _, msg := range messages {
+		resp.Messages = append(resp.Messages, newResponseChatMessage(msg))
+	}
+
+	return &resp
+}
+
+func NewChatPresetsResponse(presets []ChatPreset) *ChatPresetsResponse {
+	resp := ChatPresetsResponse{GenericResponse: *NewSuccessResponse(), Presets: []ResponseChatPreset{}}
+
+	for _, p := range presets {
+		resp.Presets = append(resp.Presets, ResponseChatPreset{p.Id, p.Text})
+	}
+
+	return &resp
+}
+
+type ResponseScore struct {
+	Centipawns int `json:"cp"`
+	Mate       int `json:"mate,omitempty"`
+}
+
+type EvaluationResponse struct {
+	GenericResponse
+	BestMove string        `json:"bestMove"`
+	Score    ResponseScore `json:"score"`
+	Depth    int           `json:"depth"`
+	Pv       []string      `json:"pv"`
+}
+
+type ResponseMoveAnalysis struct {
+	Ply            int           `json:"ply"`
+	Move           string        `json:"move"`
+	Player         string        `json:"player"`
+	Score          ResponseScore `json:"score"`
+	BestMove       string        `json:"bestMove"`
+	Loss           int           `json:"loss"`
+	Classification string        `json:"classification"`
+}
+
+type ResponseAnalysisSummary struct {
+	Inaccuracies int `json:"inaccuracies"`
+	Mistakes     int `json:"mistakes"`
+	Blunders     int `json:"blunders"`
+	AverageLoss  int `json:"averageLoss"`
+}
+
+type GameAnalysisResponse struct {
+	GenericResponse
+	White ResponseAnalysisSummary `json:"white"`
+	Black ResponseAnalysisSummary `json:"black"`
+	Moves []ResponseMoveAnalysis  `json:"moves"`
+}
+
+// Sent with 202 Accepted while the game is being analysed
+type AnalysisPendingResponse struct {
+	GenericResponse
+	Pending bool `json:"pending"`
+}
+
+func NewAnalysisPendingResponse() *AnalysisPendingResponse {
+	return &AnalysisPendingResponse{*NewSuccessResponse(), true}
+}
+
+func (this *AnalysisPendingResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, http.StatusAccepted)
+	return nil
+}
+
+func NewEvaluationResponse(analysis engine.Analysis) *EvaluationResponse {
+	pv := analysis.Pv
+
+	if pv == nil {
+		pv = []string{}
+	}
+
+	return &EvaluationResponse{*NewSuccessResponse(), analysis.BestMove, ResponseScore(analysis.Score), analysis.Depth, pv}
+}
+
+func NewGameAnalysisResponse(analysis GameAnalysis) *GameAnalysisResponse {
+	resp := GameAnalysisResponse{
+		GenericResponse: *NewSuccessResponse(),
+		White:           ResponseAnalysisSummary(analysis.White),
+		Black:           ResponseAnalysisSummary(analysis.Black),
+		Moves:           []ResponseMoveAnalysis{},
+	}
+
+	for _, m := range analysis.Moves {
+		resp.Moves = append(resp.Moves, ResponseMoveAnalysis{m.Ply, m.Move, m.Player.String(), ResponseScore(m.Score), m.BestMove, m.Loss, m.Classification.String()})
+	}
+
+	return &resp
+}
+
+type ResponseBotLevel struct {
+	Level       int    `json:"level"`
+	Description string `json:"description"`
+}
+
+type BotLevelsResponse struct {
+	GenericResponse
+	Levels []ResponseBotLevel `json:"levels"`
+}
+
+func NewBotLevelsResponse(levels []BotLevel) *BotLevelsResponse {
+	resp := BotLevelsResponse{GenericResponse: *NewSuccessResponse(), Levels: []ResponseBotLevel{}}
+
+	for _, l := range levels {
+		resp.Levels = append(resp.Levels, ResponseBotLevel{l.Level, l.Description})
+	}
+
+	return &resp
+}
+
+type ResponseBoardInstruction struct {
+	Action      string `json:"action"`
+	Piece       string `json:"piece,omitempty"` // Empty when lifting a piece the board cannot identify
+	Color       string `json:"color,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Description string `json:"description"`
+}
+
+type SyncResponse struct {
+	GenericResponse
+	InSync       bool                       `json:"inSync"`
+	WrongSquares []string                   `json:"wrongSquares"`
+	Instructions []ResponseBoardInstruction `json:"instructions"`
+}
+
+func newResponseBoardInstruction(in BoardInstruction) ResponseBoardInstruction {
+	resp := ResponseBoardInstruction{Action: string(in.Action), Description: in.String()}
+
+	if in.Piece != chess.NoPiece {
+		resp.Piece = CPieceToString(in.Piece.Type())
+		resp.Color = strings.ToUpper(in.Piece.Color().Name())
+	}
+
+	if in.From != chess.NoSquare {
+		resp.From = in.From.String()
+	}
+
+	if in.To != chess.NoSquare {
+		resp.To = in.To.String()
+	}
+
+	return resp
+}
+
+func NewSyncResponse(report SyncReport) *SyncResponse {
+	resp := SyncResponse{
+		GenericResponse: *NewSuccessResponse(),
+		InSync:          report.InSync,
+		WrongSquares:    []string{},
+		Instructions:    []ResponseBoardInstruction{},
+	}
+
+	for _, sq := range report.WrongSquares {
+		resp.WrongSquares = append(resp.WrongSquares, sq.String())
+	}
+
+	for _, in := range report.Instructions {
+		resp.Instructions = append(resp.Instructions, newResponseBoardInstruction(in))
+	}
+
+	return &resp
+}
+
+type InferenceResponse struct {
+	GenericResponse
+	Status     string   `json:"status"`
+	Move       string   `json:"move,omitempty"` // The move that was played, when the status is MOVE_READY
+	Candidates []string `json:"candidates,omitempty"`
+	Fen        string   `json:"fen"`
+}
+
+func NewInferenceResponse(inference Inference, fen string) *InferenceResponse {
+	return &InferenceResponse{*NewSuccessResponse(), inference.Status.String(), inference.Move, inference.Candidates, fen}
+}
+
+type MirrorResponse struct {
+	GenericResponse
+	Instructions []ResponseBoardInstruction `json:"instructions"` // Empty when there is nothing to mirror
+}
+
+func NewMirrorResponse(instructions []BoardInstruction) *MirrorResponse {
+	resp := MirrorResponse{GenericResponse: *NewSuccessResponse(), Instructions: []ResponseBoardInstruction{}}
+
+	for _, in := range instructions {
+		resp.Instructions = append(resp.Instructions, newResponseBoardInstruction(in))
+	}
 
-	return fmt.Sprintf(format, gsr.boardPretty, gsr.Pieces, gsr.Turn, gsr.LastMove.String(), gsr.InCheck, gsr.GameOver, gsr.OfferedDraw, gsr.OfferingPlayer)
+	return &resp
 }
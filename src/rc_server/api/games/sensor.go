@@ -0,0 +1,90 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Report a piece being lifted off or placed on a square. As soon as the events add up to a single
+// legal move it is played just as if the board had sent it to /move.
+func (gh *GameHandler) SensorEvent(action BoardAction) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		game, ok1 := ctx.Value("game").(*ChessGame)
+		board, ok2 := ctx.Value("board").(*Chessboard)
+		squareStr, ok3 := ctx.Value("square").(string)
+
+		if !ok1 || !ok2 || !ok3 {
+			render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+			return
+		}
+
+		square, err := ParseSquare(squareStr)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		inference, err := game.RecordSensorEvent(*board, SensorEvent{Action: action, Square: square})
+
+		gh.renderInference(w, r, game, board, inference, err)
+	}
+}
+
+func (gh *GameHandler) ChoosePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+	pieceStr, ok3 := ctx.Value("piece").(string)
+
+	if !ok1 || !ok2 || !ok3 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	piece, err := ParsePromotionPiece(pieceStr)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	inference, err := game.ChoosePromotion(*board, piece)
+
+	gh.renderInference(w, r, game, board, inference, err)
+}
+
+func (gh *GameHandler) ResetSensor(w http.ResponseWriter, r *http.Request) {
+	game, ok := r.Context().Value("game").(*ChessGame)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	game.ResetSensorEvents()
+
+	render.Render(w, r, NewSuccessResponse())
+}
+
+// Play the inferred move if there is one and tell the board where things stand
+func (gh *GameHandler) renderInference(w http.ResponseWriter, r *http.Request, game *ChessGame, board *Chessboard, inference Inference, err error) {
+	if err == nil && inference.Status == MOVE_READY {
+		err = gh.playMove(game, board, inference.Move, MoveGuard{})
+	}
+
+	if err != nil {
+		gh.renderMoveError(w, r, game, err)
+		return
+	}
+
+	render.Render(w, r, NewInferenceResponse(inference, game.GetFEN()))
+}
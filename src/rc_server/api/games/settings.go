@@ -0,0 +1,181 @@
+package games
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	. "remotechess/src/rc_server/api"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Parse the optional settings of a new game from the query string into the "settings" context value.
+// Supported parameters:
+//
+//	timeControl  untimed (default), fischer, bronstein, simple_delay or correspondence
+//	base         starting time in seconds
+//	increment    increment or delay in seconds
+//	days         days per move for correspondence games
+//	fen          a position to start from instead of the standard one
+//	variant      standard (default), chess960, king_of_the_hill or three_check
+//	chess960     the Chess960 starting position number, random if left out. Castling is sent as the king moving onto its rook.
+//	rated        true to have the game count towards the players' ratings, casual by default
+//	takebacks    whether the players may ask to take moves back, by default only in casual games
+//	visibility   who may watch: public (default), friends or private
+//	delay        seconds spectators are kept behind the live game
+func CtxGameSettingsFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings, err := parseGameSettings(r)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "settings", settings)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func parseGameSettings(r *http.Request) (GameSettings, error) {
+	query := r.URL.Query()
+	settings := MakeGameSettingsDefault()
+
+	if query.Get("timeControl") != "" {
+		kind, err := TimeControlKindFromString(query.Get("timeControl"))
+
+		if err != nil {
+			return settings, err
+		}
+
+		base, err := queryInt(query.Get("base"), "Base time")
+
+		if err != nil {
+			return settings, err
+		}
+
+		increment, err := queryInt(query.Get("increment"), "Increment")
+
+		if err != nil {
+			return settings, err
+		}
+
+		days, err := queryInt(query.Get("days"), "Days per move")
+
+		if err != nil {
+			return settings, err
+		}
+
+		settings.TimeControl, err = NewTimeControl(kind, time.Duration(base)*time.Second, time.Duration(increment)*time.Second, days)
+
+		if err != nil {
+			return settings, err
+		}
+	}
+
+	if query.Get("fen") != "" {
+		fen, err := NormalizeStartFen(query.Get("fen"))
+
+		if err != nil {
+			return settings, err
+		}
+
+		settings.StartFen = fen
+	}
+
+	if query.Get("variant") != "" {
+		variant, err := GameVariantFromString(query.Get("variant"))
+
+		if err != nil {
+			return settings, err
+		}
+
+		settings.Variant = variant
+	}
+
+	if query.Get("chess960") != "" {
+		if settings.Variant != CHESS960 {
+			return settings, sv.NewGenericError("A Chess960 position can only be chosen for Chess960 games", 400, sv.NOT_SENSITIVE)
+		}
+
+		position, err := queryInt(query.Get("chess960"), "Chess960 position")
+
+		if err != nil {
+			return settings, err
+		}
+
+		if _, err = Chess960Fen(position); err != nil {
+			return settings, err
+		}
+
+		settings.Chess960Position = position
+	}
+
+	if settings.Variant == CHESS960 && settings.StartFen != "" {
+		return settings, sv.NewGenericError("Chess960 games start from a numbered position rather than a FEN", 400, sv.NOT_SENSITIVE)
+	}
+
+	if query.Get("rated") != "" {
+		rated, err := strconv.ParseBool(query.Get("rated"))
+
+		if err != nil {
+			return settings, sv.NewInvalidInputError("Rated")
+		}
+
+		settings.Rated = rated
+	}
+
+	settings.Takebacks = !settings.Rated
+
+	if query.Get("takebacks") != "" {
+		takebacks, err := strconv.ParseBool(query.Get("takebacks"))
+
+		if err != nil {
+			return settings, sv.NewInvalidInputError("Takebacks")
+		}
+
+		settings.Takebacks = takebacks
+	}
+
+	if query.Get("visibility") != "" {
+		visibility, err := GameVisibilityFromString(query.Get("visibility"))
+
+		if err != nil {
+			return settings, err
+		}
+
+		settings.Visibility = visibility
+	}
+
+	delay, err := queryInt(query.Get("delay"), "Delay")
+
+	if err != nil {
+		return settings, err
+	}
+
+	settings.BroadcastDelay = time.Duration(delay) * time.Second
+
+	if settings.BroadcastDelay < 0 || settings.BroadcastDelay > MAX_BROADCAST_DELAY {
+		return settings, sv.NewInvalidInputError("Delay")
+	}
+
+	return settings, nil
+}
+
+func queryInt(value string, displayName string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, sv.NewInvalidInputError(displayName)
+	}
+
+	return i, nil
+}
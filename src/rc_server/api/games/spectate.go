@@ -0,0 +1,89 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	apievents "remotechess/src/rc_server/api/events"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+
+	"github.com/go-chi/render"
+)
+
+// Read-only live feed of the game's moves and clocks, held back by the game's broadcast delay.
+// Everyone connected here is listed as a spectator until they disconnect.
+func (gh *GameHandler) Spectate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok := ctx.Value("game").(*ChessGame)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	viewer, _ := ctx.Value("authUser").(*UserCore)
+
+	sub := gh.server.Events.SubscribeGame(game.Id)
+	backlog, err := gh.server.Events.FetchEventsSince(game.Id, apievents.ParseSince(r))
+
+	if err != nil {
+		sub.Close()
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	delay := game.BroadcastDelay
+
+	// The players already know where the pieces are
+	if viewer != nil && game.IsPlayedBy(*viewer) {
+		delay = 0
+	}
+
+	leave := game.AddSpectator(viewer)
+	defer leave()
+
+	apievents.ServeDelayedEventStream(w, r, sub, backlog, delay)
+}
+
+func (gh *GameHandler) Spectators(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok := ctx.Value("game").(*ChessGame)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	render.Render(w, r, NewSpectatorsResponse(game.FetchSpectators()))
+}
+
+func (gh *GameHandler) SetVisibility(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	visibilityStr, ok2 := ctx.Value("visibility").(string)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	visibility, err := GameVisibilityFromString(visibilityStr)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	err = game.SetVisibility(visibility)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewSuccessResponse())
+}
@@ -0,0 +1,56 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Compare what a physical board senses with the game and tell it how to put things right.
+// The board sends one of:
+//
+//	occupancy  bitmap of occupied squares, bit 0 is a1 and bit 63 is h8, decimal or 0x-prefixed hex
+//	placement  the piece placement field of a FEN
+func (gh *GameHandler) SyncBoard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	board, ok2 := ctx.Value("board").(*Chessboard)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	query := r.URL.Query()
+
+	var sensed SensedPosition
+	var err error
+
+	if query.Get("placement") != "" {
+		sensed, err = ParsePlacement(query.Get("placement"))
+	} else if query.Get("occupancy") != "" {
+		sensed, err = ParseOccupancy(query.Get("occupancy"))
+	} else {
+		err = sv.NewGenericError("Either occupancy or placement is required", 400, sv.NOT_SENSITIVE)
+	}
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	report, err := game.SyncPosition(*board, sensed)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewSyncResponse(report))
+}
@@ -0,0 +1,84 @@
+package games
+
+import (
+	"net/http"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+
+	"github.com/go-chi/render"
+)
+
+// Ask to take back the last move (1 ply) or the last move and the opponent's reply (2 plies).
+// The computer accepts every takeback straight away.
+func (gh *GameHandler) RequestTakeback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	game, ok1 := ctx.Value("game").(*ChessGame)
+	chessboard, ok2 := ctx.Value("board").(*Chessboard)
+	plies, ok3 := ctx.Value("plies").(int)
+
+	if !ok1 || !ok2 || !ok3 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := game.RequestTakeback(*chessboard, plies)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	player, _ := game.GetColorOfBoard(*chessboard)
+	gh.publishEvent(game, TAKEBACK_REQUESTED_EVENT, EventData{Player: player.String(), Plies: plies})
+
+	if opponent := game.GetBoardOfPlayer(player.Other()); opponent.IsBot() {
+		if err = game.AcceptTakeback(*opponent); err != nil {
+			gh.renderMoveError(w, r, game, err)
+			return
+		}
+
+		gh.publishEvent(game, TAKEBACK_ACCEPTED_EVENT, EventData{Player: player.Other().String(), Plies: plies, Version: game.Version})
+	}
+
+	render.Render(w, r, NewGameStateResponse(*game))
+}
+
+func (gh *GameHandler) ResolveTakeback(accept bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		game, ok1 := ctx.Value("game").(*ChessGame)
+		chessboard, ok2 := ctx.Value("board").(*Chessboard)
+
+		if !ok1 || !ok2 {
+			render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+			return
+		}
+
+		var err error
+		var kind EventKind
+		plies := game.TakebackPlies
+
+		if accept {
+			err = game.AcceptTakeback(*chessboard)
+			kind = TAKEBACK_ACCEPTED_EVENT
+		} else {
+			err = game.DeclineTakeback(*chessboard)
+			kind = TAKEBACK_DECLINED_EVENT
+		}
+
+		if err != nil {
+			gh.renderMoveError(w, r, game, err)
+			return
+		}
+
+		player, _ := game.GetColorOfBoard(*chessboard)
+		gh.publishEvent(game, kind, EventData{Player: player.String(), Plies: plies, Version: game.Version})
+
+		render.Render(w, r, NewGameStateResponse(*game))
+	}
+}
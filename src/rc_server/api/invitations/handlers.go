@@ -14,12 +14,13 @@ import (
 	"github.com/go-chi/render"
 
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
 	. "remotechess/src/rc_server/api/games"
 	"remotechess/src/rc_server/api/utility"
 	. "remotechess/src/rc_server/servercore"
 	. "remotechess/src/rc_server/service/chessboards"
 	service "remotechess/src/rc_server/service/common"
-	. "remotechess/src/rc_server/service/invitations"
+	. "remotechess/src/rc_server/service/games"
 	. "remotechess/src/rc_server/service/usercore"
 )
 
@@ -35,18 +36,22 @@ func (ih *InvitationHandler) Router() func(chi.Router) {
 	return func(router chi.Router) {
 		router.Group(func(g chi.Router) {
 			g.Use(utility.CtxFetchFromUrl("userId", "User ID", "user", func(x uint64) (interface{}, error) {
-				return FetchUserCore(x)
+				return ih.server.Users.FetchUserCore(x)
 			}))
 
+			g.Use(RequireSelf("user"))
+
 			g.Get("/pending/{userId}", ih.GetPendingInvites)
 		})
 
 		router.Group(func(g chi.Router) {
 			g.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "chessboard", func(x uint64) (interface{}, error) {
-				return FetchChessboard(x)
+				return ih.server.Boards.FetchChessboard(x)
 			}))
 
-			g.Get("/createcode/{boardId}", ih.CreateInvite)
+			g.Use(RequireBoardAccess(ih.server.Boards, "chessboard"))
+
+			g.With(CtxGameSettingsFromQuery).Get("/createcode/{boardId}", ih.CreateInvite)
 
 			g.Group(func(g chi.Router) {
 				g.Use(utility.CtxIntFromURL("inviteCode", "Invite Code"))
@@ -56,30 +61,33 @@ func (ih *InvitationHandler) Router() func(chi.Router) {
 
 			g.Group(func(g chi.Router) {
 				g.Use(utility.CtxFetchFromUrl("userId", "User ID", "user", func(x uint64) (interface{}, error) {
-					return FetchUserCore(x)
+					return ih.server.Users.FetchUserCore(x)
 				}))
 
-				g.Get("/send/f/{boardId}/t/{userId}", ih.SendInvite)
+				g.With(CtxGameSettingsFromQuery).Get("/send/f/{boardId}/t/{userId}", ih.SendInvite)
 				g.Get("/cancelinvite/f/{boardId}/t/{userId}", ih.CancelSentInvite)
 			})
 		})
 
 		router.Group(func(g chi.Router) {
+			g.Use(RequireUser)
 			g.Use(utility.CtxIntFromURL("inviteCode", "Invite Code"))
 
 			g.Get("/cancelcode/{inviteCode}", ih.CancelCodeInvite)
 		})
 
 		router.Group(func(g chi.Router) {
+			g.Use(RequireUser)
 			g.Use(utility.CtxIntFromURL("inviteId", "Invite ID"))
 
 			g.Get("/reject/{inviteId}", ih.RejectInvite)
 
 			g.Group(func(g chi.Router) {
 				g.Use(utility.CtxFetchFromUrl("recipientBid", "Recipient Board ID", "recipient", func(x uint64) (interface{}, error) {
-					return FetchChessboard(x)
+					return ih.server.Boards.FetchChessboard(x)
 				}))
 
+				g.Use(RequireBoardAccess(ih.server.Boards, "recipient"))
 				g.Use(utility.CtxStringFromURL("recipientColor", "Recipient Color", false))
 
 				g.Get("/accept/{inviteId}/r/{recipientBid}/{recipientColor}", ih.AcceptInvite)
@@ -91,14 +99,15 @@ func (ih *InvitationHandler) Router() func(chi.Router) {
 func (ih *InvitationHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	board, ok := ctx.Value("chessboard").(*Chessboard)
+	board, ok1 := ctx.Value("chessboard").(*Chessboard)
+	settings, ok2 := ctx.Value("settings").(GameSettings)
 
-	if !ok {
+	if !ok1 || !ok2 {
 		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
 		return
 	}
 
-	inviteCode, err := CreateCodeInvite(*board)
+	inviteCode, err := ih.server.Invitations.CreateCodeInvite(*board, settings)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -111,14 +120,15 @@ func (ih *InvitationHandler) CreateInvite(w http.ResponseWriter, r *http.Request
 func (ih *InvitationHandler) CancelCodeInvite(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	inviteCode, ok := ctx.Value("inviteCode").(int)
+	inviteCode, ok1 := ctx.Value("inviteCode").(int)
+	user, ok2 := ctx.Value("authUser").(*UserCore)
 
-	if !ok {
+	if !ok1 || !ok2 {
 		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
 		return
 	}
 
-	err := CancelCodeInvite(inviteCode)
+	err := ih.server.Invitations.CancelCodeInvite(*user, inviteCode)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -139,7 +149,7 @@ func (ih *InvitationHandler) JoinCodeInvite(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	game, err := JoinCodeInvite(recipient, inviteCode)
+	game, err := ih.server.Invitations.JoinCodeInvite(recipient, inviteCode)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -154,13 +164,14 @@ func (ih *InvitationHandler) SendInvite(w http.ResponseWriter, r *http.Request)
 
 	board, ok1 := ctx.Value("chessboard").(*Chessboard)
 	user, ok2 := ctx.Value("user").(*UserCore)
+	settings, ok3 := ctx.Value("settings").(GameSettings)
 
-	if !ok1 || !ok2 {
+	if !ok1 || !ok2 || !ok3 {
 		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
 		return
 	}
 
-	err := SendInvite(*board, *user)
+	err := ih.server.Invitations.SendInvite(*board, *user, settings)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -181,7 +192,7 @@ func (ih *InvitationHandler) CancelSentInvite(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err := CancelInvite(*board, *user)
+	err := ih.server.Invitations.CancelInvite(*board, *user)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -201,7 +212,7 @@ func (ih *InvitationHandler) GetPendingInvites(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	invites, err := GetPendingInvites(*user)
+	invites, err := ih.server.Invitations.GetPendingInvites(*user)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -216,6 +227,11 @@ func (ih *InvitationHandler) GetPendingInvites(w http.ResponseWriter, r *http.Re
 		invite.InviteId = int(o.Id)
 		invite.SenderId = o.Sender.Id
 		invite.Username = o.Sender.Username
+		invite.TimeControl = NewResponseTimeControl(o.Settings.TimeControl)
+		invite.StartFen = o.Settings.StartFen
+		invite.Variant = o.Settings.Variant.String()
+		invite.Rated = o.Settings.Rated
+		invite.Takebacks = o.Settings.Takebacks
 
 		if o.YourColor != nil {
 			invite.YourColor = o.YourColor.String()
@@ -248,7 +264,7 @@ func (ih *InvitationHandler) AcceptInvite(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	game, err := AcceptInvite(recipient, uint64(inviteId), recipientColor)
+	game, err := ih.server.Invitations.AcceptInvite(recipient, uint64(inviteId), recipientColor)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -261,14 +277,15 @@ func (ih *InvitationHandler) AcceptInvite(w http.ResponseWriter, r *http.Request
 func (ih *InvitationHandler) RejectInvite(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	inviteId, ok := ctx.Value("inviteId").(int)
+	inviteId, ok1 := ctx.Value("inviteId").(int)
+	user, ok2 := ctx.Value("authUser").(*UserCore)
 
-	if !ok {
+	if !ok1 || !ok2 {
 		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
 		return
 	}
 
-	err := RejectInvite(uint64(inviteId))
+	err := ih.server.Invitations.RejectInvite(*user, uint64(inviteId))
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
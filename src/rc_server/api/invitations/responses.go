@@ -2,13 +2,19 @@ package invitations
 
 import (
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/games"
 )
 
 type ResponseInvite struct {
-	InviteId  int    `json:"inviteId"`
-	SenderId  uint64 `json:"senderId"`
-	Username  string `json:"senderUsername"`
-	YourColor string `json:"yourColor"`
+	InviteId    int                 `json:"inviteId"`
+	SenderId    uint64              `json:"senderId"`
+	Username    string              `json:"senderUsername"`
+	YourColor   string              `json:"yourColor"`
+	TimeControl ResponseTimeControl `json:"timeControl"`
+	StartFen    string              `json:"startFen,omitempty"`
+	Variant     string              `json:"variant"`
+	Rated       bool                `json:"rated"`
+	Takebacks   bool                `json:"takebacks"`
 }
 
 type GetPendingInvitesResponse struct {
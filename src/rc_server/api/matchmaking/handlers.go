@@ -0,0 +1,109 @@
+package matchmaking
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
+	. "remotechess/src/rc_server/api/games"
+	"remotechess/src/rc_server/api/utility"
+	. "remotechess/src/rc_server/servercore"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/matchmaking"
+)
+
+type MatchmakingHandler struct {
+	server *ServerCore
+}
+
+func NewMatchmakingHandler(s *ServerCore) MatchmakingHandler {
+	return MatchmakingHandler{s}
+}
+
+func (mh *MatchmakingHandler) Router(router chi.Router) {
+	router.Use(utility.RequireFeature(mh.server.Config.Features.Matchmaking, "Matchmaking"))
+
+	router.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "chessboard", func(x uint64) (interface{}, error) {
+		return mh.server.Boards.FetchChessboard(x)
+	}))
+
+	router.Use(RequireBoardAccess(mh.server.Boards, "chessboard"))
+
+	// Accepts the game settings query parameters, plus ratingRange for how far from the
+	// board's own rating opponents may be to begin with
+	router.With(CtxGameSettingsFromQuery).Get("/join", mh.Join)
+	router.Get("/leave", mh.Leave)
+	router.Get("/status", mh.Status)
+}
+
+func (mh *MatchmakingHandler) Join(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok1 := ctx.Value("chessboard").(*Chessboard)
+	settings, ok2 := ctx.Value("settings").(GameSettings)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	preferences := Preferences{Settings: settings}
+
+	if rangeStr := r.URL.Query().Get("ratingRange"); rangeStr != "" {
+		ratingRange, err := strconv.ParseFloat(rangeStr, 64)
+
+		if err != nil {
+			render.Render(w, r, NewErrResponse("Invalid Rating Range", 400, false))
+			return
+		}
+
+		preferences.RatingRange = ratingRange
+	}
+
+	status, err := mh.server.Matchmaking.JoinQueue(*board, preferences)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewQueueStatusResponse(status))
+}
+
+func (mh *MatchmakingHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := mh.server.Matchmaking.LeaveQueue(*board)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewSuccessResponse())
+}
+
+func (mh *MatchmakingHandler) Status(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	board, ok := ctx.Value("chessboard").(*Chessboard)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	render.Render(w, r, NewQueueStatusResponse(mh.server.Matchmaking.FetchQueueStatus(*board)))
+}
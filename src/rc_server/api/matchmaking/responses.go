@@ -0,0 +1,31 @@
+package matchmaking
+
+import (
+	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/service/matchmaking"
+)
+
+type QueueStatusResponse struct {
+	GenericResponse
+	State       string  `json:"state"`
+	JoinedAt    int64   `json:"joinedAt,omitempty"`
+	Rating      float64 `json:"rating,omitempty"`
+	RatingRange float64 `json:"ratingRange,omitempty"`
+	GameId      uint64  `json:"gameId,omitempty"`
+}
+
+func NewQueueStatusResponse(status QueueStatus) *QueueStatusResponse {
+	resp := QueueStatusResponse{
+		GenericResponse: *NewSuccessResponse(),
+		State:           status.State.String(),
+		Rating:          status.Rating,
+		RatingRange:     status.RatingRange,
+		GameId:          status.GameId,
+	}
+
+	if status.State == WAITING {
+		resp.JoinedAt = status.JoinedAt.UnixMilli()
+	}
+
+	return &resp
+}
@@ -15,9 +15,12 @@ import (
 	"github.com/go-chi/render"
 
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
+	"remotechess/src/rc_server/api/games"
 	"remotechess/src/rc_server/api/utility"
 	. "remotechess/src/rc_server/servercore"
 	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
 	. "remotechess/src/rc_server/service/usercore"
 )
 
@@ -32,31 +35,53 @@ func NewUserCoreHandler(s *ServerCore) UserCoreHandler {
 func (uch *UserCoreHandler) Router() func(chi.Router) {
 	return func(router chi.Router) {
 		router.Use(utility.CtxFetchFromUrl("userId", "User ID", "user", func(x uint64) (interface{}, error) {
-			return FetchUserCore(x)
+			return uch.server.Users.FetchUserCore(x)
 		}))
 
 		router.Get("/", uch.Get)
 
+		// The history lists private and friends-only games too
+		router.With(RequireSelf("user"), games.CtxGameFilterFromQuery).Get("/games", uch.GetGames)
+
+		router.Group(func(g chi.Router) {
+			g.Use(utility.CtxStringFromURL("pool", "Rating Pool", false))
+			g.Get("/ratings/{pool}", uch.GetRatingHistory)
+		})
+
 		router.Group(func(g chi.Router) {
 			g.Use(utility.CtxFetchFromUrl("boardId", "Board ID", "chessboard", func(x uint64) (interface{}, error) {
-				return FetchChessboard(x)
+				return uch.server.Boards.FetchChessboard(x)
 			}))
 
+			g.Use(RequireSelf("user"))
+
+			// Claiming a board needs its device secret, proving the user has it in hand
+			g.Use(RequireBoardAccess(uch.server.Boards, "chessboard"))
+
 			g.Get("/registerboard/{boardId}", uch.RegisterBoard)
 		})
 
+		router.Group(func(g chi.Router) {
+			g.Use(RequireSelf("user"))
+
+			g.Get("/notifications", uch.GetNotifications)
+			g.With(utility.CtxIntFromURL("notificationId", "Notification ID")).Get("/notifications/{notificationId}/read", uch.MarkNotificationRead)
+		})
+
 		router.Group(func(g chi.Router) {
 			g.Use(render.SetContentType(render.ContentTypePlainText))
 			g.Get("/print", uch.GetPretty)
 		})
 
 		router.Route("/friends", func(fr chi.Router) {
+			fr.Use(RequireSelf("user"))
+
 			fr.Get("/", uch.GetFriends(false))
 			fr.Get("/pending", uch.GetFriends(true))
 
 			fr.Route("/{friendId}", func(fr2 chi.Router) {
 				fr2.Use(utility.CtxFetchFromUrl("friendId", "Friend ID", "friend", func(x uint64) (interface{}, error) {
-					return FetchUserCore(x)
+					return uch.server.Users.FetchUserCore(x)
 				}))
 
 				fr2.Get("/send", uch.SendFriendRequest)
@@ -78,13 +103,132 @@ func (uh *UserCoreHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ratings, err := uh.server.Users.FetchRatings(user)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	responseRatings := make([]ResponseRating, len(ratings))
+
+	for i, rating := range ratings {
+		responseRatings[i] = ResponseRating{rating.Pool.String(), rating.Rating, rating.Deviation, rating.Volatility, rating.Games}
+	}
+
 	render.Render(w, r, &GetUserCoreResponse{
 		GenericResponse: *NewSuccessResponse(),
 		Id:              user.Id,
-		Username:        user.Username},
+		Username:        user.Username,
+		Ratings:         responseRatings},
 	)
 }
 
+func (uch *UserCoreHandler) GetGames(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok1 := ctx.Value("user").(*UserCore)
+	filter, ok2 := ctx.Value("filter").(GameFilter)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	page, err := uch.server.Games.SearchUserGames(*user, filter)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, games.NewGameHistoryResponse(*page))
+}
+
+func (uch *UserCoreHandler) GetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok1 := ctx.Value("user").(*UserCore)
+	poolStr, ok2 := ctx.Value("pool").(string)
+
+	if !ok1 || !ok2 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	pool, err := RatingPoolFromString(poolStr)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	history, err := uch.server.Users.FetchRatingHistory(user, pool)
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	response := GetRatingHistoryResponse{GenericResponse: *NewSuccessResponse(), Pool: pool.String()}
+	response.History = make([]ResponseRatingHistoryEntry, len(history))
+
+	for i, h := range history {
+		response.History[i] = ResponseRatingHistoryEntry{h.GameId, h.Rating, h.Deviation, h.Volatility, h.CreatedAt.UnixMilli()}
+	}
+
+	render.Render(w, r, &response)
+}
+
+// The user's notifications, newest first. Only unread ones with ?unread=true.
+func (uch *UserCoreHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok := ctx.Value("user").(*UserCore)
+
+	if !ok {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	notifications, err := uch.server.Users.FetchNotifications(user, r.URL.Query().Get("unread") == "true")
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	response := GetNotificationsResponse{GenericResponse: *NewSuccessResponse()}
+	response.Notifications = make([]ResponseNotification, len(notifications))
+
+	for i, n := range notifications {
+		response.Notifications[i] = ResponseNotification{n.Id, n.Kind.String(), n.BoardId, n.GameId, n.Deadline.UnixMilli(), n.CreatedAt.UnixMilli(), n.Read}
+	}
+
+	render.Render(w, r, &response)
+}
+
+func (uch *UserCoreHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok1 := ctx.Value("user").(*UserCore)
+	notificationId, ok2 := ctx.Value("notificationId").(int)
+
+	if !ok1 || !ok2 || notificationId < 0 {
+		render.Render(w, r, NewErrResponse(http.StatusText(422), 422, true))
+		return
+	}
+
+	err := uch.server.Users.MarkNotificationRead(user, uint64(notificationId))
+
+	if err != nil {
+		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
+		return
+	}
+
+	render.Render(w, r, NewSuccessResponse())
+}
+
 func (uh *UserCoreHandler) GetPretty(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -112,7 +256,7 @@ func (uch *UserCoreHandler) RegisterBoard(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err := board.AssignFirstOwner(*user)
+	err := uch.server.Boards.AssignFirstOwner(board, *user)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -133,7 +277,7 @@ func (uch *UserCoreHandler) SendFriendRequest(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err := user.SendFriendRequest(*friend)
+	err := uch.server.Users.SendFriendRequest(user, *friend)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -154,7 +298,7 @@ func (uch *UserCoreHandler) GetFriends(pending bool) func(w http.ResponseWriter,
 			return
 		}
 
-		pendingRequestsUserCores, err := user.GetFriends(pending)
+		pendingRequestsUserCores, err := uch.server.Users.GetFriends(user, pending)
 
 		if err != nil {
 			render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -182,7 +326,7 @@ func (uch *UserCoreHandler) AcceptFriendRequest(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err := user.AcceptFriendRequest(*friend)
+	err := uch.server.Users.AcceptFriendRequest(user, *friend)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -203,7 +347,7 @@ func (uch *UserCoreHandler) RemoveFriend(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := user.RemoveFriend(*friend)
+	err := uch.server.Users.RemoveFriend(user, *friend)
 
 	if err != nil {
 		render.Render(w, r, NewErrResponseFromServiceErr(err, HTTP_STATUS_DEFAULT, ERROR_DEFAULT_OBSCURED))
@@ -6,8 +6,31 @@ import (
 
 type GetUserCoreResponse struct {
 	GenericResponse
-	Id       uint64 `json:"id"`
-	Username string `json:"username"`
+	Id       uint64           `json:"id"`
+	Username string           `json:"username"`
+	Ratings  []ResponseRating `json:"ratings"`
+}
+
+type ResponseRating struct {
+	Pool       string  `json:"pool"`
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+	Games      int     `json:"games"`
+}
+
+type ResponseRatingHistoryEntry struct {
+	GameId     uint64  `json:"gameId"`
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+	Time       int64   `json:"time"`
+}
+
+type GetRatingHistoryResponse struct {
+	GenericResponse
+	Pool    string                       `json:"pool"`
+	History []ResponseRatingHistoryEntry `json:"history"`
 }
 
 type ResponseFriend struct {
@@ -15,6 +38,21 @@ type ResponseFriend struct {
 	Username string `json:"username"`
 }
 
+type ResponseNotification struct {
+	Id        uint64 `json:"id"`
+	Kind      string `json:"kind"`
+	BoardId   uint64 `json:"boardId"`
+	GameId    uint64 `json:"gameId"`
+	Deadline  int64  `json:"deadline"`
+	CreatedAt int64  `json:"createdAt"`
+	Read      bool   `json:"read"`
+}
+
+type GetNotificationsResponse struct {
+	GenericResponse
+	Notifications []ResponseNotification `json:"notifications"`
+}
+
 type GetFriendsResponse struct {
 	GenericResponse
 	Friends []ResponseFriend `json:"friends"`
@@ -0,0 +1,72 @@
+package utility
+
+import (
+	"net/http"
+	"strings"
+
+	. "remotechess/src/rc_server/api"
+	"remotechess/src/rc_server/logging"
+
+	"github.com/go-chi/render"
+)
+
+// Give everything behind this the server's logger, so errors rendered for the request end up in its log
+func WithLogger(lg *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), lg)))
+		})
+	}
+}
+
+// Answer 404 for everything behind this when the feature is switched off in the config
+func RequireFeature(enabled bool, displayName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled {
+				render.Render(w, r, NewErrResponse(displayName+" is disabled on this server", 404, false))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Let browsers on the given origins call the API. "*" allows any origin.
+// Requests from other origins are still served, the browser just refuses to hand over the response.
+func Cors(origins []string, headers ...string) func(http.Handler) http.Handler {
+	allowed := map[string]bool{}
+
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+
+	allowHeaders := strings.Join(append([]string{"Authorization", "Content-Type", "Last-Event-ID"}, headers...), ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			if origin == "" || (!allowed["*"] && !allowed[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+			// Preflight
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"remotechess/src/rc_server/logging"
+)
+
+// Everything the server can be set up with. It is read once at startup and handed around in ServerCore.
+type Config struct {
+	Database       DatabaseConfig       `yaml:"database"`
+	Server         ServerConfig         `yaml:"server"`
+	Log            LogConfig            `yaml:"log"`
+	Engine         EngineConfig         `yaml:"engine"`
+	Clock          ClockConfig          `yaml:"clock"`
+	Correspondence CorrespondenceConfig `yaml:"correspondence"`
+	Moves          MoveConfig           `yaml:"moves"`
+	Features       FeatureConfig        `yaml:"features"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"` // 0 for no limit
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // 0 to keep connections forever
+}
+
+type ServerConfig struct {
+	ListenAddress string   `yaml:"listen_address"`
+	TLSCertFile   string   `yaml:"tls_cert_file"` // Plain HTTP unless both are set
+	TLSKeyFile    string   `yaml:"tls_key_file"`
+	CORSOrigins   []string `yaml:"cors_origins"` // "*" allows any origin
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // DEBUG, INFO, WARN or ERROR
+}
+
+type EngineConfig struct {
+	Path    string `yaml:"path"` // Any UCI engine, the server only needs it for hints and analysis
+	Workers int    `yaml:"workers"`
+}
+
+type ClockConfig struct {
+	FlagSweepInterval time.Duration `yaml:"flag_sweep_interval"` // How often live games are checked for a flag fall their timer missed
+}
+
+type CorrespondenceConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval"` // How often deadlines are adjudicated and reminders queued
+}
+
+type MoveConfig struct {
+	RequireVersion bool `yaml:"require_version"` // Refuse moves sent without ?version=, once every board sends it
+}
+
+// Parts of the server that can be switched off. A disabled feature answers 404.
+type FeatureConfig struct {
+	Registration bool `yaml:"registration"`
+	Matchmaking  bool `yaml:"matchmaking"`
+	Bots         bool `yaml:"bots"`
+	Analysis     bool `yaml:"analysis"`
+	Chat         bool `yaml:"chat"`
+	PgnImport    bool `yaml:"pgn_import"`
+	Spectating   bool `yaml:"spectating"`
+}
+
+// A config that only lacks the database DSN
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Server: ServerConfig{
+			ListenAddress: ":3000",
+		},
+		Log: LogConfig{
+			Level: "INFO",
+		},
+		Engine: EngineConfig{
+			Path:    "stockfish",
+			Workers: 2,
+		},
+		Clock: ClockConfig{
+			FlagSweepInterval: 30 * time.Second,
+		},
+		Correspondence: CorrespondenceConfig{
+			CheckInterval: time.Minute,
+		},
+		Features: FeatureConfig{
+			Registration: true,
+			Matchmaking:  true,
+			Bots:         true,
+			Analysis:     true,
+			Chat:         true,
+			PgnImport:    true,
+			Spectating:   true,
+		},
+	}
+}
+
+// Every problem found in a config, so they can all be fixed in one go
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Read the YAML file at path over the defaults, apply the REMOTECHESS_* environment overrides
+// and validate the result. An empty path uses the defaults and the environment only.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	problems := cfg.applyEnvironment(os.LookupEnv)
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return cfg, &ConfigError{problems}
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) readFile(path string) error {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Errorf("could not read the configuration: %w", err)
+	}
+
+	defer f.Close()
+
+	// A misspelt key would otherwise silently leave its default in place
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (cfg *Config) validate() []string {
+	problems := []string{}
+
+	if cfg.Database.DSN == "" {
+		problems = append(problems, "database.dsn is required (or set "+envPrefix+"DATABASE_DSN)")
+	}
+
+	if cfg.Database.MaxOpenConns < 0 {
+		problems = append(problems, "database.max_open_conns cannot be negative")
+	}
+
+	if cfg.Database.MaxIdleConns < 0 {
+		problems = append(problems, "database.max_idle_conns cannot be negative")
+	} else if cfg.Database.MaxOpenConns > 0 && cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		problems = append(problems, fmt.Sprintf("database.max_idle_conns (%d) cannot exceed database.max_open_conns (%d)",
+			cfg.Database.MaxIdleConns, cfg.Database.MaxOpenConns))
+	}
+
+	if cfg.Database.ConnMaxLifetime < 0 {
+		problems = append(problems, "database.conn_max_lifetime cannot be negative")
+	}
+
+	if cfg.Server.ListenAddress == "" {
+		problems = append(problems, "server.listen_address is required")
+	}
+
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		problems = append(problems, "server.tls_cert_file and server.tls_key_file must be set together")
+	}
+
+	for _, file := range []string{cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile} {
+		if file == "" {
+			continue
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, "cannot read TLS file "+file+": "+err.Error())
+		}
+	}
+
+	for _, origin := range cfg.Server.CORSOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			problems = append(problems, fmt.Sprintf("server.cors_origins entry %q must be \"*\" or start with http:// or https://", origin))
+		}
+	}
+
+	if _, ok := logging.ParseLevel(cfg.Log.Level); !ok {
+		problems = append(problems, fmt.Sprintf("log.level must be DEBUG, INFO, WARN or ERROR, not %q", cfg.Log.Level))
+	}
+
+	// Bots fall back to the built-in engine, analysis has none
+	if cfg.Features.Analysis && cfg.Engine.Path == "" {
+		problems = append(problems, "engine.path is required while analysis is enabled")
+	}
+
+	if cfg.Engine.Workers < 1 {
+		problems = append(problems, "engine.workers must be at least 1")
+	}
+
+	if cfg.Clock.FlagSweepInterval <= 0 {
+		problems = append(problems, "clock.flag_sweep_interval must be positive")
+	}
+
+	if cfg.Correspondence.CheckInterval <= 0 {
+		problems = append(problems, "correspondence.check_interval must be positive")
+	}
+
+	return problems
+}
+
+// The level log.level names, once the config is valid
+func (cfg Config) LogLevel() logging.Level {
+	l, _ := logging.ParseLevel(cfg.Log.Level)
+	return l
+}
+
+func (cfg Config) TLSEnabled() bool {
+	return cfg.Server.TLSCertFile != ""
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every setting can be overridden by an environment variable named after its key,
+// e.g. database.dsn by REMOTECHESS_DATABASE_DSN
+const envPrefix = "REMOTECHESS_"
+
+type envSetter func(value string) error
+
+func (cfg *Config) envOverrides() map[string]envSetter {
+	return map[string]envSetter{
+		"DATABASE_DSN":               setString(&cfg.Database.DSN),
+		"DATABASE_MAX_OPEN_CONNS":    setInt(&cfg.Database.MaxOpenConns),
+		"DATABASE_MAX_IDLE_CONNS":    setInt(&cfg.Database.MaxIdleConns),
+		"DATABASE_CONN_MAX_LIFETIME": setDuration(&cfg.Database.ConnMaxLifetime),
+
+		"SERVER_LISTEN_ADDRESS": setString(&cfg.Server.ListenAddress),
+		"SERVER_TLS_CERT_FILE":  setString(&cfg.Server.TLSCertFile),
+		"SERVER_TLS_KEY_FILE":   setString(&cfg.Server.TLSKeyFile),
+		"SERVER_CORS_ORIGINS":   setList(&cfg.Server.CORSOrigins),
+
+		"LOG_LEVEL": setString(&cfg.Log.Level),
+
+		"ENGINE_PATH":    setString(&cfg.Engine.Path),
+		"ENGINE_WORKERS": setInt(&cfg.Engine.Workers),
+
+		"CLOCK_FLAG_SWEEP_INTERVAL": setDuration(&cfg.Clock.FlagSweepInterval),
+
+		"CORRESPONDENCE_CHECK_INTERVAL": setDuration(&cfg.Correspondence.CheckInterval),
+
+		"MOVES_REQUIRE_VERSION": setBool(&cfg.Moves.RequireVersion),
+
+		"FEATURES_REGISTRATION": setBool(&cfg.Features.Registration),
+		"FEATURES_MATCHMAKING":  setBool(&cfg.Features.Matchmaking),
+		"FEATURES_BOTS":         setBool(&cfg.Features.Bots),
+		"FEATURES_ANALYSIS":     setBool(&cfg.Features.Analysis),
+		"FEATURES_CHAT":         setBool(&cfg.Features.Chat),
+		"FEATURES_PGN_IMPORT":   setBool(&cfg.Features.PgnImport),
+		"FEATURES_SPECTATING":   setBool(&cfg.Features.Spectating),
+	}
+}
+
+// Apply the overrides lookup finds and return a problem for every value that does not parse
+func (cfg *Config) applyEnvironment(lookup func(string) (string, bool)) []string {
+	overrides := cfg.envOverrides()
+	names := []string{}
+
+	for name := range overrides {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	problems := []string{}
+
+	for _, name := range names {
+		value, ok := lookup(envPrefix + name)
+
+		if !ok {
+			continue
+		}
+
+		if err := overrides[name](value); err != nil {
+			problems = append(problems, envPrefix+name+": "+err.Error())
+		}
+	}
+
+	return problems
+}
+
+func setString(dst *string) envSetter {
+	return func(value string) error {
+		*dst = value
+		return nil
+	}
+}
+
+func setInt(dst *int) envSetter {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+
+		if err != nil {
+			return err
+		}
+
+		*dst = n
+		return nil
+	}
+}
+
+func setBool(dst *bool) envSetter {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		*dst = b
+		return nil
+	}
+}
+
+func setDuration(dst *time.Duration) envSetter {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+
+		if err != nil {
+			return err
+		}
+
+		*dst = d
+		return nil
+	}
+}
+
+// Comma separated, an empty value clears the list
+func setList(dst *[]string) envSetter {
+	return func(value string) error {
+		*dst = nil
+
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*dst = append(*dst, item)
+			}
+		}
+
+		return nil
+	}
+}
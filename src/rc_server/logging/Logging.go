@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"strings"
+)
+
+type Level int32
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+var (
+	levelToStr = map[Level]string{
+		DEBUG: "DEBUG",
+		INFO:  "INFO",
+		WARN:  "WARN",
+		ERROR: "ERROR",
+	}
+
+	strToLevel = map[string]Level{
+		"DEBUG": DEBUG,
+		"INFO":  INFO,
+		"WARN":  WARN,
+		"ERROR": ERROR,
+	}
+)
+
+func (l Level) String() string {
+	return levelToStr[l]
+}
+
+func ParseLevel(s string) (Level, bool) {
+	l, ok := strToLevel[strings.ToUpper(s)]
+	return l, ok
+}
+
+// Writes every message at or above its level. A nil Logger logs at INFO.
+type Logger struct {
+	minLevel Level
+}
+
+func New(minLevel Level) *Logger {
+	return &Logger{minLevel: minLevel}
+}
+
+func (lg *Logger) Enabled(l Level) bool {
+	if lg == nil {
+		return l >= INFO
+	}
+
+	return l >= lg.minLevel
+}
+
+func (lg *Logger) log(l Level, msg string) {
+	if lg.Enabled(l) {
+		println(l.String() + " - " + msg)
+	}
+}
+
+func (lg *Logger) Debug(msg string) { lg.log(DEBUG, msg) }
+func (lg *Logger) Info(msg string)  { lg.log(INFO, msg) }
+func (lg *Logger) Warn(msg string)  { lg.log(WARN, msg) }
+func (lg *Logger) Error(msg string) { lg.log(ERROR, msg) }
+
+type loggerKey struct{}
+
+// A context carrying lg, for code that only sees the request
+func NewContext(ctx context.Context, lg *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, lg)
+}
+
+// The Logger ctx carries, or nil, which still logs at INFO
+func FromContext(ctx context.Context) *Logger {
+	lg, _ := ctx.Value(loggerKey{}).(*Logger)
+	return lg
+}
@@ -1,10 +1,21 @@
 package rcdb
 
-import "database/sql"
+import (
+	"database/sql"
 
-func ConnectToDb() *sql.DB {
-	connStr := "host=localhost user=postgres dbname=remotechess sslmode=disable password=admin"
-	db, _ := sql.Open("postgres", connStr)
+	"remotechess/src/rc_server/config"
+)
 
-	return db
+func ConnectToDb(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	return db, nil
 }
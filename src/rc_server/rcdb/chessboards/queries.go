@@ -6,25 +6,56 @@ const (
 	SELECT_BOARD ChessboardQuery = iota
 	REGISTER_BOARD
 	ASSIGN_FIRST_OWNER
-	UPDATE_CURRENT_GAME
-	UPDATE_CURRENT_GAME_MULTI
+	UPDATE_FOCUSED_GAME
+	FOCUS_NEW_GAME
+	FOCUS_GAME
+	SELECT_BOARD_SECRET
+	SET_BOARD_SECRET
+	CREATE_BOT_BOARD
+	LOCK_BOARDS
 )
 
 func GetChessboardQuery(q ChessboardQuery) string {
 	switch q {
 	case SELECT_BOARD:
-		return `SELECT onboard_id, fk_owner, fk_cur_game FROM chessboards WHERE onboard_id = $1`
+		return `SELECT onboard_id, fk_owner, fk_focused_game, bot_level FROM chessboards WHERE onboard_id = $1`
 	case REGISTER_BOARD:
-		return `INSERT INTO chessboards (onboard_id) VALUES ($1) RETURNING onboard_id, fk_owner`
+		return `INSERT INTO chessboards (onboard_id, secret_hash, secret_issued_at) VALUES ($1, $2, NOW()) RETURNING onboard_id, fk_owner`
 	case ASSIGN_FIRST_OWNER:
 		return `UPDATE chessboards
 				SET fk_owner = $1
 				WHERE onboard_id = $2
 					AND fk_owner IS NULL`
-	case UPDATE_CURRENT_GAME:
-		return `UPDATE chessboards SET fk_cur_game = $2 where onboard_id = $1`
-	case UPDATE_CURRENT_GAME_MULTI:
-		return `UPDATE chessboards SET fk_cur_game = $1 WHERE onboard_id = $2 OR onboard_id = $3`
+	case UPDATE_FOCUSED_GAME:
+		return `UPDATE chessboards SET fk_focused_game = $2 where onboard_id = $1`
+	case FOCUS_NEW_GAME:
+		// Game $1 between boards $2 and $3. When $4 is true only boards not focused on an ongoing game switch to it.
+		return `UPDATE chessboards
+				SET fk_focused_game = $1
+				WHERE
+						(onboard_id = $2 OR onboard_id = $3)
+					AND (
+						   NOT $4
+						OR fk_focused_game IS NULL
+						OR NOT EXISTS (SELECT 1 FROM games WHERE games.id = chessboards.fk_focused_game AND games.outcome = 'NONE'))
+				RETURNING onboard_id`
+	case FOCUS_GAME:
+		// Only a game the board plays in
+		return `UPDATE chessboards
+				SET fk_focused_game = $2
+				WHERE
+						onboard_id = $1
+					AND EXISTS (SELECT 1 FROM games WHERE games.id = $2 AND (games.fk_white = $1 OR games.fk_black = $1))`
+	case SELECT_BOARD_SECRET:
+		return `SELECT secret_hash FROM chessboards WHERE onboard_id = $1`
+	case SET_BOARD_SECRET:
+		return `UPDATE chessboards SET secret_hash = $2, secret_issued_at = NOW() WHERE onboard_id = $1`
+	case CREATE_BOT_BOARD:
+		// Bot boards have no secret, so nothing can authenticate as one
+		return `INSERT INTO chessboards (onboard_id, bot_level) VALUES ($1, $2) ON CONFLICT (onboard_id) DO NOTHING`
+	case LOCK_BOARDS:
+		// Rows are locked in the order they are returned
+		return `SELECT onboard_id FROM chessboards WHERE onboard_id = ANY($1) ORDER BY onboard_id FOR UPDATE`
 	}
 
 	panic("Invalid query select")
@@ -0,0 +1,25 @@
+package events
+
+type EventQuery int
+
+const (
+	CREATE_EVENT EventQuery = iota
+	GET_EVENTS_SINCE
+)
+
+func GetEventQuery(q EventQuery) string {
+	switch q {
+	case CREATE_EVENT:
+		// The game's counter row is locked until the event is stored, so every event gets its own seq
+		return `WITH next AS (
+					UPDATE games SET event_seq = event_seq + 1 WHERE id = $1 RETURNING event_seq
+				)
+				INSERT INTO game_events (fk_game, seq, kind, data)
+				SELECT $1, event_seq, $2, $3 FROM next
+				RETURNING seq, created_at`
+	case GET_EVENTS_SINCE:
+		return `SELECT seq, kind, data, created_at FROM game_events WHERE fk_game = $1 AND seq > $2 ORDER BY seq ASC`
+	}
+
+	panic("Invalid query select")
+}
@@ -11,20 +11,60 @@ const (
 	GET_LAST_MOVE
 	DELETE_LAST_MOVE
 	UPDATE_DRAW
+	ADJUDICATE_GAME
+	IMPORT_GAME
+	MARK_RATINGS_APPLIED
+	SEARCH_GAMES
+	UPDATE_VISIBILITY
+	CREATE_CHAT_MESSAGE
+	GET_CHAT_MESSAGES
+	MUTE_CHAT
+	UNMUTE_CHAT
+	IS_CHAT_MUTED
+	UPDATE_MOVE_ANALYSIS
+	GET_MOVE_ANALYSIS
+	UPDATE_TAKEBACK
+	CONFIRM_MIRRORED
+	RESET_MIRRORED
+	SELECT_OVERDUE_CORRESPONDENCE
+	SELECT_FLAGGED_LIVE
+	SELECT_AWAITING_BOT
+	QUEUE_DEADLINE_REMINDERS
+	SELECT_BOARD_ONGOING_GAMES
+	COUNT_BOARD_ONGOING_GAMES
+	SELECT_MOVE_REQUEST
+	CREATE_MOVE_REQUEST
+	PURGE_MOVE_REQUESTS
 )
 
 func GetGameQuery(q GameQuery) string {
 	switch q {
 	case SELECT_GAME:
-		return `SELECT id, fk_white, fk_black, fen, current_move, outcome, method, offered_draw, offering_player FROM games WHERE id = $1`
+		return `SELECT
+					id, fk_white, fk_black, fen, current_move, outcome, method, offered_draw, offering_player,
+					tc_kind, tc_base_ms, tc_increment_ms, tc_days_per_move, white_time_ms, black_time_ms, turn_started_at,
+					created_at, archived, pgn_tags, start_fen, variant, rated, visibility, broadcast_delay_ms,
+					takebacks, takeback_plies, takeback_player, mirrored_ply, version, plies
+				FROM games WHERE id = $1`
 	case CREATE_GAME:
-		return `INSERT INTO games (fk_white, fk_black, fen) VALUES ($1, $2, $3) RETURNING id`
+		return `INSERT INTO games (
+					fk_white, fk_black, fen,
+					tc_kind, tc_base_ms, tc_increment_ms, tc_days_per_move, white_time_ms, black_time_ms, turn_started_at,
+					start_fen, variant, rated, visibility, broadcast_delay_ms, takebacks
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`
 	case UPDATE_GAME:
-		return `UPDATE games SET fen = $2, current_move = $3, outcome = $4, method = $5 WHERE id = $1`
+		// Only while the game is still at version $9
+		return `UPDATE games
+				SET
+					fen = $2, current_move = $3, outcome = $4, method = $5,
+					white_time_ms = $6, black_time_ms = $7, turn_started_at = $8, plies = $10,
+					ended_at = CASE WHEN $4 = 'NONE' THEN NULL ELSE COALESCE(ended_at, NOW()) END,
+					version = version + 1
+				WHERE id = $1 AND version = $9`
 	case CREATE_MOVE:
-		return `INSERT INTO moves (fk_game, player, cell_from, cell_to, piece, tags) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		return `INSERT INTO moves (fk_game, player, cell_from, cell_to, piece, tags, promotion) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 	case GET_MOVES:
-		return `SELECT cell_from, cell_to FROM moves WHERE fk_game = $1 ORDER BY move_num ASC`
+		return `SELECT cell_from, cell_to, COALESCE(promotion, '') FROM moves WHERE fk_game = $1 ORDER BY move_num ASC`
 	case GET_LAST_MOVE:
 		return `SELECT DISTINCT ON(fk_game) fk_game, move_num, player, cell_from, cell_to, piece, tags FROM moves ORDER BY fk_game, move_num DESC`
 	case DELETE_LAST_MOVE:
@@ -34,6 +74,143 @@ func GetGameQuery(q GameQuery) string {
 					move_num = (SELECT MAX(move_num) FROM moves WHERE fk_game = $1)`
 	case UPDATE_DRAW:
 		return `UPDATE games SET offered_draw = $2, offering_player = $3 WHERE id = $1`
+	case UPDATE_TAKEBACK:
+		return `UPDATE games SET takeback_plies = $2, takeback_player = $3 WHERE id = $1`
+	case CONFIRM_MIRRORED:
+		return `UPDATE games SET mirrored_ply = $2 WHERE id = $1 AND mirrored_ply < $2`
+	case RESET_MIRRORED:
+		return `UPDATE games SET mirrored_ply = $2 WHERE id = $1`
+	case ADJUDICATE_GAME:
+		return `UPDATE games
+				SET outcome = $2, method = $3, white_time_ms = $4, black_time_ms = $5, ended_at = NOW(), version = version + 1
+				WHERE id = $1 AND outcome = 'NONE'`
+	case IMPORT_GAME:
+		return `INSERT INTO games (fk_white, fk_black, fen, current_move, outcome, method, archived, pgn_tags, start_fen, plies, ended_at)
+				VALUES ($1, $2, $3, $4, $5, $6, true, $7, $8, $9, NOW())
+				RETURNING id, created_at`
+	case MARK_RATINGS_APPLIED:
+		return `UPDATE games
+				SET ratings_applied = true
+				WHERE id = $1 AND rated AND NOT ratings_applied AND outcome <> 'NONE'`
+	case UPDATE_VISIBILITY:
+		return `UPDATE games SET visibility = $2 WHERE id = $1`
+	case CREATE_CHAT_MESSAGE:
+		return `INSERT INTO game_chat (fk_game, player, preset_id, body) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	case GET_CHAT_MESSAGES:
+		// Everything player $2 may read: their own messages, and their opponent's unless sent while muted
+		return `SELECT chat.id, chat.player, chat.preset_id, chat.body, chat.created_at
+				FROM game_chat chat
+				LEFT JOIN chat_mutes mute ON mute.fk_game = chat.fk_game AND mute.player = $2
+				WHERE
+						chat.fk_game = $1
+					AND (chat.player = $2 OR mute.muted_at IS NULL OR chat.created_at < mute.muted_at)
+				ORDER BY chat.id ASC`
+	case MUTE_CHAT:
+		return `INSERT INTO chat_mutes (fk_game, player) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	case UNMUTE_CHAT:
+		return `DELETE FROM chat_mutes WHERE fk_game = $1 AND player = $2`
+	case IS_CHAT_MUTED:
+		return `SELECT EXISTS (SELECT 1 FROM chat_mutes WHERE fk_game = $1 AND player = $2)`
+	case UPDATE_MOVE_ANALYSIS:
+		// The move is picked by its ply, counting from 0
+		return `UPDATE moves
+				SET eval_cp = $3, eval_mate = $4, best_move = $5, cp_loss = $6, classification = $7
+				WHERE id = (SELECT id FROM moves WHERE fk_game = $1 ORDER BY move_num ASC OFFSET $2 LIMIT 1)`
+	case GET_MOVE_ANALYSIS:
+		return `SELECT cell_from, cell_to, COALESCE(promotion, ''), player, eval_cp, eval_mate, best_move, cp_loss, classification
+				FROM moves WHERE fk_game = $1 ORDER BY move_num ASC`
+	case SELECT_OVERDUE_CORRESPONDENCE:
+		return `SELECT id FROM games
+				WHERE
+						tc_kind = 'CORRESPONDENCE' AND outcome = 'NONE' AND NOT archived
+					AND turn_started_at + tc_days_per_move * INTERVAL '1 day' <= NOW()`
+	case SELECT_FLAGGED_LIVE:
+		// Live clocks only run once both sides have moved, and SIMPLE_DELAY waits out the delay first
+		return `SELECT id FROM games
+				WHERE
+						tc_kind IN ('FISCHER', 'BRONSTEIN', 'SIMPLE_DELAY') AND outcome = 'NONE' AND NOT archived
+					AND plies >= 2
+					AND turn_started_at
+						+ (CASE WHEN current_move = 'WHITE' THEN white_time_ms ELSE black_time_ms END
+							+ CASE WHEN tc_kind = 'SIMPLE_DELAY' THEN tc_increment_ms ELSE 0 END) * INTERVAL '1 millisecond'
+						<= NOW()`
+	case SELECT_AWAITING_BOT:
+		return `SELECT games.id FROM games
+				INNER JOIN chessboards board
+					ON board.onboard_id = CASE WHEN games.current_move = 'WHITE' THEN games.fk_white ELSE games.fk_black END
+				WHERE games.outcome = 'NONE' AND NOT games.archived AND board.bot_level IS NOT NULL`
+	case QUEUE_DEADLINE_REMINDERS:
+		// Reminder $1 for every correspondence game whose mover has less than $2 milliseconds left.
+		// Games whose whole move period is that short never get it, and each deadline gets it once.
+		return `INSERT INTO notifications (fk_user, fk_board, fk_game, kind, deadline)
+				SELECT board.fk_owner, board.onboard_id, games.id, $1, games.turn_started_at + games.tc_days_per_move * INTERVAL '1 day'
+				FROM games
+				INNER JOIN chessboards board
+					ON board.onboard_id = CASE WHEN games.current_move = 'WHITE' THEN games.fk_white ELSE games.fk_black END
+				WHERE
+						games.tc_kind = 'CORRESPONDENCE' AND games.outcome = 'NONE' AND NOT games.archived
+					AND board.fk_owner IS NOT NULL
+					AND games.tc_days_per_move * INTERVAL '1 day' > $2 * INTERVAL '1 millisecond'
+					AND games.turn_started_at + games.tc_days_per_move * INTERVAL '1 day' - $2 * INTERVAL '1 millisecond' <= NOW()
+					AND games.turn_started_at + games.tc_days_per_move * INTERVAL '1 day' > NOW()
+				ON CONFLICT (fk_game, kind, deadline) DO NOTHING
+				RETURNING id, fk_user, fk_board, fk_game, kind, deadline, created_at`
+	case SELECT_BOARD_ONGOING_GAMES:
+		return `SELECT id FROM games
+				WHERE (fk_white = $1 OR fk_black = $1) AND outcome = 'NONE' AND NOT archived
+				ORDER BY created_at ASC, id ASC`
+	case COUNT_BOARD_ONGOING_GAMES:
+		return `SELECT
+					COUNT(*) FILTER (WHERE tc_kind = 'CORRESPONDENCE'),
+					COUNT(*) FILTER (WHERE tc_kind <> 'CORRESPONDENCE')
+				FROM games
+				WHERE (fk_white = $1 OR fk_black = $1) AND outcome = 'NONE' AND NOT archived`
+	case SELECT_MOVE_REQUEST:
+		// Keys older than $3 milliseconds have expired
+		return `SELECT fk_game, move FROM move_requests
+				WHERE fk_board = $1 AND idempotency_key = $2 AND created_at > NOW() - $3 * INTERVAL '1 millisecond'`
+	case CREATE_MOVE_REQUEST:
+		// An expired key may be used again
+		return `INSERT INTO move_requests (fk_board, idempotency_key, fk_game, move) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (fk_board, idempotency_key) DO UPDATE SET fk_game = $3, move = $4, created_at = NOW()`
+	case PURGE_MOVE_REQUESTS:
+		return `DELETE FROM move_requests WHERE fk_board = $1 AND created_at <= NOW() - $2 * INTERVAL '1 millisecond'`
+	case SEARCH_GAMES:
+		// Games played by user $1 or by board $2, from the point of view of that player.
+		// Every filter is skipped when its parameter is NULL.
+		return `SELECT
+					id, fk_white, fk_black, white_user_id, white_name, black_user_id, black_name,
+					outcome, method, variant, rated, tc_kind, plies, created_at, ended_at,
+					COUNT(*) OVER() AS total
+				FROM (
+					SELECT
+						games.*,
+						whiteUser.id AS white_user_id,
+						COALESCE(whiteUser.username, games.pgn_tags->>'White', 'Computer level ' || whiteBoard.bot_level) AS white_name,
+						blackUser.id AS black_user_id,
+						COALESCE(blackUser.username, games.pgn_tags->>'Black', 'Computer level ' || blackBoard.bot_level) AS black_name,
+						CASE WHEN whiteUser.id = $1 OR games.fk_white = $2 THEN 'WHITE' ELSE 'BLACK' END AS side
+					FROM games
+					LEFT JOIN chessboards whiteBoard ON whiteBoard.onboard_id = games.fk_white
+					LEFT JOIN users whiteUser ON whiteUser.id = whiteBoard.fk_owner
+					LEFT JOIN chessboards blackBoard ON blackBoard.onboard_id = games.fk_black
+					LEFT JOIN users blackUser ON blackUser.id = blackBoard.fk_owner
+					WHERE whiteUser.id = $1 OR blackUser.id = $1 OR games.fk_white = $2 OR games.fk_black = $2
+				) played
+				WHERE
+						($3::text IS NULL OR side = $3::text)
+					AND ($4::bigint IS NULL OR (CASE WHEN side = 'WHITE' THEN black_user_id ELSE white_user_id END) = $4::bigint)
+					AND ($5::text IS NULL
+						OR ($5::text = 'ONGOING' AND outcome = 'NONE')
+						OR ($5::text = 'DRAW' AND outcome = 'DRAW')
+						OR ($5::text = 'WIN' AND outcome::text = side || '_WON')
+						OR ($5::text = 'LOSS' AND outcome IN ('WHITE_WON', 'BLACK_WON') AND outcome::text <> side || '_WON'))
+					AND ($6::text IS NULL OR method::text = $6::text)
+					AND ($7::text IS NULL OR variant::text = $7::text)
+					AND ($8::timestamptz IS NULL OR created_at >= $8::timestamptz)
+					AND ($9::timestamptz IS NULL OR created_at < $9::timestamptz)
+				ORDER BY created_at DESC, id DESC
+				LIMIT $10 OFFSET $11`
 	}
 
 	panic("Invalid query select")
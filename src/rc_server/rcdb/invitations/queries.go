@@ -14,6 +14,7 @@ const (
 	CANCEL_CODE_INVITE
 	DELETE_INVITE
 	CLEAR_INVITES
+	SET_CODE_INVITE_SETTINGS
 )
 
 func GetInvitationQuery(q InvitationQuery) string {
@@ -21,9 +22,12 @@ func GetInvitationQuery(q InvitationQuery) string {
 	case CREATE_INVITE_WITH_CODE:
 		return `SELECT "CreateInviteWithCode"($1, $2) as invite_code`
 	case CANCEL_CODE_INVITE:
-		return `DELETE FROM game_invites WHERE invite_code = $1`
+		return `DELETE FROM game_invites 
+				WHERE invite_code = $1 AND fk_sender IN (SELECT onboard_id FROM chessboards WHERE fk_owner = $2)`
+	case SET_CODE_INVITE_SETTINGS:
+		return `UPDATE game_invites SET settings = $2 WHERE invite_code = $1`
 	case SEND_INVITE:
-		return `INSERT INTO game_invites (fk_sender, fk_recipient, recipient_color) VALUES ($1, $2, $3) RETURNING id`
+		return `INSERT INTO game_invites (fk_sender, fk_recipient, recipient_color, settings) VALUES ($1, $2, $3, $4) RETURNING id`
 	case CANCEL_SENT_INVITE:
 		return `DELETE FROM game_invites WHERE fk_sender = $1 AND fk_recipient = $2`
 	case GET_PENDING_INVITES:
@@ -31,7 +35,8 @@ func GetInvitationQuery(q InvitationQuery) string {
 					game_invites.id,
 					users.id,
 					users.username,
-					recipient_color
+					recipient_color,
+					game_invites.settings
 				FROM game_invites 
 				LEFT JOIN chessboards 
 					ON game_invites.fk_sender = chessboards.onboard_id 
@@ -42,26 +47,29 @@ func GetInvitationQuery(q InvitationQuery) string {
 		return `SELECT
 					sender.onboard_id     as sender_onboard_id,
 					sender.fk_owner       as sender_fk_owner,
-					sender.fk_cur_game    as sender_fk_cur_game
+					sender.fk_focused_game as sender_fk_focused_game,
+					game_invites.settings
 				FROM game_invites
 				INNER JOIN chessboards sender ON sender.onboard_id = game_invites.fk_sender
 				INNER JOIN chessboards recipientBoard on recipientBoard.onboard_id = $2
 				INNER JOIN users recipientUser on recipientBoard.fk_owner = recipientUser.id
 				WHERE 
 						game_invites.id = $1
+					AND game_invites.fk_recipient = recipientUser.id
 					AND game_invites.declined = 'false'
 					AND (recipient_color IS NULL OR recipient_color = $3)`
 	case GET_CODE_INVITE_SENDER_BOARD:
 		return `SELECT
 					sender.onboard_id     as sender_onboard_id,
 					sender.fk_owner       as sender_fk_owner,
-					sender.fk_cur_game    as sender_fk_cur_game,
-					game_invites.recipient_color
+					sender.fk_focused_game as sender_fk_focused_game,
+					game_invites.recipient_color,
+					game_invites.settings
 				FROM game_invites
 				INNER JOIN chessboards sender ON sender.onboard_id = game_invites.fk_sender
 				WHERE invite_code = $1`
 	case REJECT_INVITE:
-		return `UPDATE game_invites SET declined = 'true' WHERE id = $1`
+		return `UPDATE game_invites SET declined = 'true' WHERE id = $1 AND fk_recipient = $2`
 	case DELETE_INVITE:
 		return `DELETE FROM game_invites WHERE id = $1`
 	case CLEAR_INVITES:
@@ -0,0 +1,23 @@
+package matchmaking
+
+type MatchmakingQuery int
+
+const (
+	GET_COLOR_BALANCE MatchmakingQuery = iota
+)
+
+func GetMatchmakingQuery(q MatchmakingQuery) string {
+	switch q {
+	case GET_COLOR_BALANCE:
+		return `SELECT
+					COUNT(*) FILTER (WHERE fk_white = $1) - COUNT(*) FILTER (WHERE fk_black = $1)
+				FROM (
+					SELECT fk_white, fk_black FROM games
+					WHERE fk_white = $1 OR fk_black = $1
+					ORDER BY created_at DESC
+					LIMIT 20
+				) recent`
+	}
+
+	panic("Invalid query select")
+}
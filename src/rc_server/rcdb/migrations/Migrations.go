@@ -0,0 +1,418 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every schema version is a pair of files sql/NNNN_name.up.sql and sql/NNNN_name.down.sql.
+// Each runs in its own transaction together with its row in schema_migrations.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+
+	up   string
+	down string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool // Applied to the database but not part of this build
+}
+
+// The migrations built into the binary, oldest first
+func All() ([]Migration, error) {
+	entries, err := files.ReadDir("sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		file := entry.Name()
+
+		var up bool
+		var base string
+
+		if strings.HasSuffix(file, ".up.sql") {
+			up, base = true, strings.TrimSuffix(file, ".up.sql")
+		} else if strings.HasSuffix(file, ".down.sql") {
+			up, base = false, strings.TrimSuffix(file, ".down.sql")
+		} else {
+			return nil, fmt.Errorf("migration %s is neither an .up.sql nor a .down.sql file", file)
+		}
+
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+
+		if len(parts) != 2 || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s is not named NNNN_name", file)
+		}
+
+		body, err := files.ReadFile("sql/" + file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m.Name, parts[1], version)
+		}
+
+		if up {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := []Migration{}
+
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", m)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Apply every migration the database is missing and return them
+func Up(db *sql.DB) ([]Migration, error) {
+	all, err := All()
+
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+
+	err = withLock(db, func(ctx context.Context, conn *sql.Conn, applied map[int]MigrationStatus) error {
+		if len(applied) == 0 {
+			if err := checkNotUnmigrated(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			if err := run(ctx, conn, m, m.up, GetMigrationQuery(RECORD_MIGRATION), m.Version, m.Name); err != nil {
+				return err
+			}
+
+			done = append(done, m)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Revert the newest steps applied migrations and return them, newest first
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+	all, err := All()
+
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int]Migration{}
+
+	for _, m := range all {
+		known[m.Version] = m
+	}
+
+	done := []Migration{}
+
+	err = withLock(db, func(ctx context.Context, conn *sql.Conn, applied map[int]MigrationStatus) error {
+		versions := []int{}
+
+		for v := range applied {
+			versions = append(versions, v)
+		}
+
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			m, ok := known[versions[i]]
+
+			if !ok {
+				return fmt.Errorf("migration %04d_%s is not part of this build and cannot be reverted by it", versions[i], applied[versions[i]].Name)
+			}
+
+			if err := run(ctx, conn, m, m.down, GetMigrationQuery(FORGET_MIGRATION), m.Version); err != nil {
+				return err
+			}
+
+			done = append(done, m)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Adopt a database whose schema was set up before migrations existed: record every migration up to
+// and including version as applied without running it, so `migrate up` carries on from there.
+// Only for databases that have no migrations recorded yet.
+func Baseline(db *sql.DB, version int) ([]Migration, error) {
+	all, err := All()
+
+	if err != nil {
+		return nil, err
+	}
+
+	known := false
+
+	for _, m := range all {
+		known = known || m.Version == version
+	}
+
+	if !known {
+		return nil, fmt.Errorf("migration %04d is not part of this build", version)
+	}
+
+	done := []Migration{}
+
+	err = withLock(db, func(ctx context.Context, conn *sql.Conn, applied map[int]MigrationStatus) error {
+		if len(applied) > 0 {
+			return fmt.Errorf("database already has migrations recorded, use `migrate up` instead")
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback()
+
+		for _, m := range all {
+			if m.Version > version {
+				break
+			}
+
+			if _, err := tx.ExecContext(ctx, GetMigrationQuery(RECORD_MIGRATION), m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %s: %w", m, err)
+			}
+
+			done = append(done, m)
+		}
+
+		return tx.Commit()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// Every migration of this build and every one applied to the database, by version
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	all, err := All()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	applied := map[int]MigrationStatus{}
+
+	var exists bool
+
+	if err := db.QueryRowContext(ctx, GetMigrationQuery(MIGRATIONS_TABLE_EXISTS)).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	// A database that was never migrated has nothing applied, and looking must not change that
+	if exists {
+		if applied, err = fetchApplied(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := []MigrationStatus{}
+
+	for _, m := range all {
+		s, ok := applied[m.Version]
+
+		if !ok {
+			s = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+
+		statuses = append(statuses, s)
+		delete(applied, m.Version)
+	}
+
+	for _, s := range applied {
+		s.Unknown = true
+		statuses = append(statuses, s)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// An error naming the missing migrations when the database schema is behind this build.
+// A database migrated further by a newer build is accepted, those migrations only add to the schema.
+func CheckCurrent(db *sql.DB) error {
+	statuses, err := Status(db)
+
+	if err != nil {
+		return fmt.Errorf("could not read the schema version: %w", err)
+	}
+
+	pending := []string{}
+
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, fmt.Sprintf("%04d_%s", s.Version, s.Name))
+		}
+	}
+
+	if len(pending) == len(statuses) {
+		if err := checkNotUnmigrated(context.Background(), db); err != nil {
+			return err
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind, run `migrate up` to apply %s", strings.Join(pending, ", "))
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// An error pointing at `migrate baseline` if a database without recorded migrations already has tables.
+// Running the first migration there would only fail on types and tables that already exist.
+func checkNotUnmigrated(ctx context.Context, q querier) error {
+	var exists bool
+
+	if err := q.QueryRowContext(ctx, GetMigrationQuery(UNMIGRATED_SCHEMA_EXISTS)).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("database has a schema from before migrations, run `migrate baseline <version>` " +
+			"with the newest migration it already matches, then `migrate up`")
+	}
+
+	return nil
+}
+
+func fetchApplied(ctx context.Context, q querier) (map[int]MigrationStatus, error) {
+	rows, err := q.QueryContext(ctx, GetMigrationQuery(GET_APPLIED_MIGRATIONS))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]MigrationStatus{}
+
+	for rows.Next() {
+		s := MigrationStatus{Applied: true}
+
+		if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[s.Version] = s
+	}
+
+	return applied, rows.Err()
+}
+
+// Run fn holding the migration lock on a single connection, with the migrations applied so far
+func withLock(db *sql.DB, fn func(ctx context.Context, conn *sql.Conn, applied map[int]MigrationStatus) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	// The advisory lock belongs to the session, so it has to be taken and released on the same connection
+	if _, err := conn.ExecContext(ctx, GetMigrationQuery(LOCK_MIGRATIONS), migrationLockKey); err != nil {
+		return err
+	}
+
+	defer conn.ExecContext(ctx, GetMigrationQuery(UNLOCK_MIGRATIONS), migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, GetMigrationQuery(CREATE_MIGRATIONS_TABLE)); err != nil {
+		return err
+	}
+
+	applied, err := fetchApplied(ctx, conn)
+
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, conn, applied)
+}
+
+// Run a migration's script and its bookkeeping query in one transaction
+func run(ctx context.Context, conn *sql.Conn, m Migration, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %s: %w", m, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %s: %w", m, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package migrations
+
+type MigrationQuery int
+
+const (
+	CREATE_MIGRATIONS_TABLE MigrationQuery = iota
+	LOCK_MIGRATIONS
+	UNLOCK_MIGRATIONS
+	GET_APPLIED_MIGRATIONS
+	RECORD_MIGRATION
+	FORGET_MIGRATION
+	MIGRATIONS_TABLE_EXISTS
+	UNMIGRATED_SCHEMA_EXISTS
+)
+
+// Arbitrary key of the advisory lock that keeps two servers from migrating at once
+const migrationLockKey = 7310411
+
+func GetMigrationQuery(q MigrationQuery) string {
+	switch q {
+	case CREATE_MIGRATIONS_TABLE:
+		return `CREATE TABLE IF NOT EXISTS schema_migrations (
+					version    integer PRIMARY KEY,
+					name       text NOT NULL,
+					applied_at timestamptz NOT NULL DEFAULT NOW()
+				)`
+	case LOCK_MIGRATIONS:
+		return `SELECT pg_advisory_lock($1)`
+	case UNLOCK_MIGRATIONS:
+		return `SELECT pg_advisory_unlock($1)`
+	case GET_APPLIED_MIGRATIONS:
+		return `SELECT version, name, applied_at FROM schema_migrations ORDER BY version ASC`
+	case RECORD_MIGRATION:
+		return `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+	case FORGET_MIGRATION:
+		return `DELETE FROM schema_migrations WHERE version = $1`
+	case MIGRATIONS_TABLE_EXISTS:
+		return `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	case UNMIGRATED_SCHEMA_EXISTS:
+		// Every deployment from before migrations has the games table
+		return `SELECT to_regclass('games') IS NOT NULL`
+	}
+
+	panic("Invalid query select")
+}
@@ -0,0 +1,14 @@
+DROP FUNCTION "CreateInviteWithCode"(bigint, player_color);
+
+DROP TABLE game_invites;
+DROP TABLE friends;
+DROP TABLE moves;
+ALTER TABLE chessboards DROP COLUMN fk_cur_game;
+DROP TABLE games;
+DROP TABLE chessboards;
+DROP TABLE users;
+
+DROP TYPE chess_piece;
+DROP TYPE game_method;
+DROP TYPE game_outcome;
+DROP TYPE player_color;
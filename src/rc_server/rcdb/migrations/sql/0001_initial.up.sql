@@ -0,0 +1,91 @@
+-- The schema the server was first written against
+
+CREATE TYPE player_color AS ENUM ('WHITE', 'BLACK');
+CREATE TYPE game_outcome AS ENUM ('NONE', 'WHITE_WON', 'BLACK_WON', 'DRAW');
+CREATE TYPE game_method AS ENUM (
+	'NONE',
+	'CHECKMATE',
+	'RESIGNATION',
+	'DRAW_AGREEMENT',
+	'STALEMATE',
+	'THREEFOLD_REPETITION',
+	'FIVEFOLD_REPETITION',
+	'50_MOVES',
+	'75_MOVES',
+	'INSUFFICIENT_MATERIAL'
+);
+CREATE TYPE chess_piece AS ENUM ('KING', 'QUEEN', 'ROOK', 'KNIGHT', 'BISHOP', 'PAWN');
+
+CREATE TABLE users (
+	id       bigserial PRIMARY KEY,
+	email    text NOT NULL,
+	username text NOT NULL
+);
+
+CREATE TABLE chessboards (
+	onboard_id bigint PRIMARY KEY,
+	fk_owner   bigint REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE TABLE games (
+	id              bigserial PRIMARY KEY,
+	fk_white        bigint NOT NULL REFERENCES chessboards (onboard_id),
+	fk_black        bigint NOT NULL REFERENCES chessboards (onboard_id),
+	fen             text NOT NULL,
+	current_move    player_color NOT NULL DEFAULT 'WHITE',
+	outcome         game_outcome NOT NULL DEFAULT 'NONE',
+	method          game_method NOT NULL DEFAULT 'NONE',
+	offered_draw    game_method NOT NULL DEFAULT 'NONE',
+	offering_player player_color NOT NULL DEFAULT 'WHITE'
+);
+
+-- The game shown on the board
+ALTER TABLE chessboards ADD COLUMN fk_cur_game bigint REFERENCES games (id) ON DELETE SET NULL;
+
+CREATE TABLE moves (
+	id        bigserial PRIMARY KEY,
+	fk_game   bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	move_num  bigserial NOT NULL, -- Only orders the moves of a game
+	player    player_color NOT NULL,
+	cell_from text NOT NULL,
+	cell_to   text NOT NULL,
+	piece     chess_piece NOT NULL,
+	tags      integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE friends (
+	fk_friend_left  bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- Who asked
+	fk_friend_right bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	pending         boolean NOT NULL DEFAULT true,
+	PRIMARY KEY (fk_friend_left, fk_friend_right),
+	CHECK (fk_friend_left <> fk_friend_right)
+);
+
+-- Invites either name a recipient or are joined with their code
+CREATE TABLE game_invites (
+	id              bigserial PRIMARY KEY,
+	fk_sender       bigint NOT NULL REFERENCES chessboards (onboard_id) ON DELETE CASCADE,
+	fk_recipient    bigint REFERENCES users (id) ON DELETE CASCADE,
+	recipient_color player_color,
+	invite_code     integer UNIQUE,
+	declined        boolean NOT NULL DEFAULT false,
+	UNIQUE (fk_sender, fk_recipient)
+);
+
+-- Store a code invite under a fresh random 6 digit code and return the code
+CREATE FUNCTION "CreateInviteWithCode"(sender bigint, color player_color) RETURNS integer AS $$
+DECLARE
+	code integer;
+BEGIN
+	LOOP
+		code := 100000 + floor(random() * 900000)::integer;
+
+		BEGIN
+			INSERT INTO game_invites (fk_sender, recipient_color, invite_code) VALUES (sender, color, code);
+			RETURN code;
+		EXCEPTION WHEN unique_violation THEN
+			-- The code is taken, draw another
+		END;
+	END LOOP;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,16 @@
+DROP TABLE game_events;
+
+ALTER TABLE game_invites DROP COLUMN settings;
+
+ALTER TABLE games
+	DROP COLUMN tc_kind,
+	DROP COLUMN tc_base_ms,
+	DROP COLUMN tc_increment_ms,
+	DROP COLUMN tc_days_per_move,
+	DROP COLUMN white_time_ms,
+	DROP COLUMN black_time_ms,
+	DROP COLUMN turn_started_at;
+
+DROP TYPE time_control_kind;
+
+-- PostgreSQL cannot drop enum values, so game_method keeps TIMEOUT and TIMEOUT_VS_INSUFFICIENT_MATERIAL
@@ -0,0 +1,27 @@
+-- Adding enum values inside the migration's transaction needs PostgreSQL 12 or later
+ALTER TYPE game_method ADD VALUE 'TIMEOUT';
+ALTER TYPE game_method ADD VALUE 'TIMEOUT_VS_INSUFFICIENT_MATERIAL';
+
+CREATE TYPE time_control_kind AS ENUM ('UNTIMED', 'FISCHER', 'BRONSTEIN', 'SIMPLE_DELAY', 'CORRESPONDENCE');
+
+ALTER TABLE games
+	ADD COLUMN tc_kind          time_control_kind NOT NULL DEFAULT 'UNTIMED',
+	ADD COLUMN tc_base_ms       bigint NOT NULL DEFAULT 0,
+	ADD COLUMN tc_increment_ms  bigint NOT NULL DEFAULT 0,
+	ADD COLUMN tc_days_per_move integer NOT NULL DEFAULT 0,
+	ADD COLUMN white_time_ms    bigint NOT NULL DEFAULT 0,
+	ADD COLUMN black_time_ms    bigint NOT NULL DEFAULT 0,
+	ADD COLUMN turn_started_at  timestamptz NOT NULL DEFAULT NOW();
+
+ALTER TABLE game_invites ADD COLUMN settings jsonb;
+
+-- Numbered per game so clients can resume from the last event they saw
+CREATE TABLE game_events (
+	id         bigserial PRIMARY KEY,
+	fk_game    bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	seq        bigint NOT NULL,
+	kind       text NOT NULL,
+	data       jsonb,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	UNIQUE (fk_game, seq)
+);
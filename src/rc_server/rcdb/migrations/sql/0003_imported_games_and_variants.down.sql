@@ -0,0 +1,16 @@
+ALTER TABLE moves DROP COLUMN promotion;
+
+DELETE FROM games WHERE fk_white IS NULL OR fk_black IS NULL;
+
+ALTER TABLE games
+	ALTER COLUMN fk_white SET NOT NULL,
+	ALTER COLUMN fk_black SET NOT NULL,
+	DROP COLUMN created_at,
+	DROP COLUMN archived,
+	DROP COLUMN pgn_tags,
+	DROP COLUMN start_fen,
+	DROP COLUMN variant;
+
+DROP TYPE game_variant;
+
+-- PostgreSQL cannot drop enum values, so game_method keeps KING_OF_THE_HILL and THREE_CHECK
@@ -0,0 +1,16 @@
+ALTER TYPE game_method ADD VALUE 'KING_OF_THE_HILL';
+ALTER TYPE game_method ADD VALUE 'THREE_CHECK';
+
+CREATE TYPE game_variant AS ENUM ('STANDARD', 'CHESS960', 'KING_OF_THE_HILL', 'THREE_CHECK');
+
+-- Imported games are archived and may have been played by people without a board
+ALTER TABLE games
+	ALTER COLUMN fk_white DROP NOT NULL,
+	ALTER COLUMN fk_black DROP NOT NULL,
+	ADD COLUMN created_at timestamptz NOT NULL DEFAULT NOW(),
+	ADD COLUMN archived   boolean NOT NULL DEFAULT false,
+	ADD COLUMN pgn_tags   jsonb,
+	ADD COLUMN start_fen  text, -- NULL for the standard starting position
+	ADD COLUMN variant    game_variant NOT NULL DEFAULT 'STANDARD';
+
+ALTER TABLE moves ADD COLUMN promotion text;
@@ -0,0 +1,10 @@
+ALTER TABLE chessboards
+	DROP COLUMN secret_hash,
+	DROP COLUMN secret_issued_at;
+
+DROP TABLE sessions;
+
+DROP INDEX users_email_key;
+DROP INDEX users_username_key;
+
+ALTER TABLE users DROP COLUMN password;
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN password text; -- bcrypt hash
+
+CREATE UNIQUE INDEX users_username_key ON users (LOWER(username));
+CREATE UNIQUE INDEX users_email_key ON users (LOWER(email));
+
+CREATE TABLE sessions (
+	token_hash bytea PRIMARY KEY,
+	fk_user    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	expires_at timestamptz NOT NULL
+);
+
+-- A NULL secret is revoked and nothing can authenticate as the board
+ALTER TABLE chessboards
+	ADD COLUMN secret_hash      bytea,
+	ADD COLUMN secret_issued_at timestamptz;
@@ -0,0 +1,8 @@
+DROP TABLE rating_history;
+DROP TABLE ratings;
+
+ALTER TABLE games
+	DROP COLUMN rated,
+	DROP COLUMN ratings_applied;
+
+DROP TYPE rating_pool;
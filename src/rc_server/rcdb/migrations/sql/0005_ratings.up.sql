@@ -0,0 +1,27 @@
+CREATE TYPE rating_pool AS ENUM ('BULLET', 'BLITZ', 'RAPID', 'CLASSICAL', 'CORRESPONDENCE');
+
+ALTER TABLE games
+	ADD COLUMN rated           boolean NOT NULL DEFAULT false,
+	ADD COLUMN ratings_applied boolean NOT NULL DEFAULT false;
+
+CREATE TABLE ratings (
+	fk_user    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	pool       rating_pool NOT NULL,
+	rating     double precision NOT NULL,
+	deviation  double precision NOT NULL,
+	volatility double precision NOT NULL,
+	games      integer NOT NULL DEFAULT 0,
+	updated_at timestamptz NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (fk_user, pool)
+);
+
+CREATE TABLE rating_history (
+	id         bigserial PRIMARY KEY,
+	fk_user    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	pool       rating_pool NOT NULL,
+	fk_game    bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	rating     double precision NOT NULL,
+	deviation  double precision NOT NULL,
+	volatility double precision NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
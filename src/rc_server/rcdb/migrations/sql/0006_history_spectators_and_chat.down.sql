@@ -0,0 +1,13 @@
+DROP TABLE chat_mutes;
+DROP TABLE game_chat;
+
+DROP INDEX moves_game_idx;
+DROP INDEX games_black_created_idx;
+DROP INDEX games_white_created_idx;
+
+ALTER TABLE games
+	DROP COLUMN ended_at,
+	DROP COLUMN visibility,
+	DROP COLUMN broadcast_delay_ms;
+
+DROP TYPE game_visibility;
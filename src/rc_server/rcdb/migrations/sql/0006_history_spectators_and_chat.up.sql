@@ -0,0 +1,27 @@
+CREATE TYPE game_visibility AS ENUM ('PUBLIC', 'FRIENDS', 'PRIVATE');
+
+ALTER TABLE games
+	ADD COLUMN ended_at           timestamptz,
+	ADD COLUMN visibility         game_visibility NOT NULL DEFAULT 'PUBLIC',
+	ADD COLUMN broadcast_delay_ms bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX games_white_created_idx ON games (fk_white, created_at);
+CREATE INDEX games_black_created_idx ON games (fk_black, created_at);
+CREATE INDEX moves_game_idx ON moves (fk_game);
+
+CREATE TABLE game_chat (
+	id         bigserial PRIMARY KEY,
+	fk_game    bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	player     player_color NOT NULL,
+	preset_id  integer,
+	body       text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- The player hides what their opponent says from muted_at on
+CREATE TABLE chat_mutes (
+	fk_game  bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	player   player_color NOT NULL,
+	muted_at timestamptz NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (fk_game, player)
+);
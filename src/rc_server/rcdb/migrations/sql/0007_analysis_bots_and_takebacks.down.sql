@@ -0,0 +1,15 @@
+ALTER TABLE games
+	DROP COLUMN takebacks,
+	DROP COLUMN takeback_plies,
+	DROP COLUMN takeback_player;
+
+ALTER TABLE chessboards DROP COLUMN bot_level;
+
+ALTER TABLE moves
+	DROP COLUMN eval_cp,
+	DROP COLUMN eval_mate,
+	DROP COLUMN best_move,
+	DROP COLUMN cp_loss,
+	DROP COLUMN classification;
+
+DROP TYPE move_classification;
@@ -0,0 +1,17 @@
+CREATE TYPE move_classification AS ENUM ('GOOD', 'INACCURACY', 'MISTAKE', 'BLUNDER');
+
+-- All NULL until the game is analysed
+ALTER TABLE moves
+	ADD COLUMN eval_cp        integer,
+	ADD COLUMN eval_mate      integer,
+	ADD COLUMN best_move      text,
+	ADD COLUMN cp_loss        integer,
+	ADD COLUMN classification move_classification;
+
+-- Computer opponents are boards without owner or secret
+ALTER TABLE chessboards ADD COLUMN bot_level integer;
+
+ALTER TABLE games
+	ADD COLUMN takebacks       boolean NOT NULL DEFAULT true,
+	ADD COLUMN takeback_plies  smallint NOT NULL DEFAULT 0, -- 0 when none is requested
+	ADD COLUMN takeback_player player_color NOT NULL DEFAULT 'WHITE';
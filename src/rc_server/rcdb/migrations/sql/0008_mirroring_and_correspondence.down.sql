@@ -0,0 +1,6 @@
+DROP INDEX games_correspondence_deadline_idx;
+
+DROP TABLE notifications;
+DROP TYPE notification_kind;
+
+ALTER TABLE games DROP COLUMN mirrored_ply;
@@ -0,0 +1,21 @@
+-- How many moves the player to move has confirmed reproducing on their board
+ALTER TABLE games ADD COLUMN mirrored_ply integer NOT NULL DEFAULT 0;
+
+CREATE TYPE notification_kind AS ENUM ('DEADLINE_DAY', 'DEADLINE_HOUR');
+
+CREATE TABLE notifications (
+	id         bigserial PRIMARY KEY,
+	fk_user    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	fk_board   bigint NOT NULL REFERENCES chessboards (onboard_id) ON DELETE CASCADE,
+	fk_game    bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	kind       notification_kind NOT NULL,
+	deadline   timestamptz NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT NOW(),
+	read_at    timestamptz,
+	UNIQUE (fk_game, kind, deadline)
+);
+
+CREATE INDEX notifications_user_idx ON notifications (fk_user, id);
+
+CREATE INDEX games_correspondence_deadline_idx ON games (turn_started_at)
+	WHERE tc_kind = 'CORRESPONDENCE' AND outcome = 'NONE';
@@ -0,0 +1,6 @@
+DROP INDEX friends_pair_key;
+
+DROP INDEX games_black_ongoing_idx;
+DROP INDEX games_white_ongoing_idx;
+
+ALTER TABLE chessboards RENAME COLUMN fk_focused_game TO fk_cur_game;
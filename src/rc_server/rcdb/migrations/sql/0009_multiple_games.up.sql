@@ -0,0 +1,8 @@
+-- Boards play several games at once and show one of them
+ALTER TABLE chessboards RENAME COLUMN fk_cur_game TO fk_focused_game;
+
+CREATE INDEX games_white_ongoing_idx ON games (fk_white) WHERE outcome = 'NONE';
+CREATE INDEX games_black_ongoing_idx ON games (fk_black) WHERE outcome = 'NONE';
+
+-- Two users are friends at most once, whoever asked
+CREATE UNIQUE INDEX friends_pair_key ON friends (LEAST(fk_friend_left, fk_friend_right), GREATEST(fk_friend_left, fk_friend_right));
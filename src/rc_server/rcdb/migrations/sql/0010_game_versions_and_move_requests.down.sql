@@ -0,0 +1,3 @@
+DROP TABLE move_requests;
+
+ALTER TABLE games DROP COLUMN version;
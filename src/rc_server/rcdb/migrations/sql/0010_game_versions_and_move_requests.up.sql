@@ -0,0 +1,12 @@
+-- Goes up by one with every stored change of position or result, so a move chosen on an older state can be refused
+ALTER TABLE games ADD COLUMN version bigint NOT NULL DEFAULT 0;
+
+-- Moves played under an idempotency key, so a board retrying a request it got no answer to does not play twice
+CREATE TABLE move_requests (
+	fk_board        bigint NOT NULL REFERENCES chessboards (onboard_id) ON DELETE CASCADE,
+	idempotency_key text NOT NULL,
+	fk_game         bigint NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	move            text NOT NULL,
+	created_at      timestamptz NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (fk_board, idempotency_key)
+);
@@ -0,0 +1,7 @@
+-- The seq of the newest event of each game. Taking the next one is a single row update, so concurrent
+-- publishers wait for each other instead of picking the same MAX(seq) + 1.
+ALTER TABLE games ADD COLUMN event_seq bigint NOT NULL DEFAULT 0;
+
+UPDATE games SET event_seq = latest.seq
+FROM (SELECT fk_game, MAX(seq) AS seq FROM game_events GROUP BY fk_game) latest
+WHERE games.id = latest.fk_game;
@@ -0,0 +1,6 @@
+-- How many moves each game has, so the flag sweep does not count the moves of every live game
+ALTER TABLE games ADD COLUMN plies integer NOT NULL DEFAULT 0;
+
+UPDATE games SET plies = played.plies
+FROM (SELECT fk_game, COUNT(*) AS plies FROM moves GROUP BY fk_game) played
+WHERE games.id = played.fk_game;
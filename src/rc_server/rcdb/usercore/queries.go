@@ -8,6 +8,19 @@ const (
 	GET_FRIENDS
 	ACCEPT_FRIEND_REQUEST
 	REMOVE_FRIEND
+	ARE_FRIENDS
+	REGISTER_USER
+	SELECT_USER_LOGIN
+	CREATE_SESSION
+	SELECT_SESSION_USER
+	DELETE_SESSION
+	SELECT_RATINGS
+	SELECT_RATING_FOR_UPDATE
+	UPSERT_RATING
+	CREATE_RATING_HISTORY
+	SELECT_RATING_HISTORY
+	SELECT_NOTIFICATIONS
+	MARK_NOTIFICATION_READ
 )
 
 func GetUserCoreQuery(q UserQuery) string {
@@ -44,6 +57,52 @@ func GetUserCoreQuery(q UserQuery) string {
 				WHERE
 					   (fk_friend_left = $1 AND fk_friend_right = $2)
 					OR (fk_friend_left = $2 AND fk_friend_right = $1)`
+	case ARE_FRIENDS:
+		return `SELECT EXISTS (
+					SELECT 1 FROM friends
+					WHERE
+						pending = false AND (
+							   (fk_friend_left = $1 AND fk_friend_right = $2)
+							OR (fk_friend_left = $2 AND fk_friend_right = $1))
+				)`
+	case REGISTER_USER:
+		return `INSERT INTO users (email, username, password) VALUES ($1, $2, $3) RETURNING id`
+	case SELECT_USER_LOGIN:
+		return `SELECT id, email, username, password FROM users WHERE LOWER(username) = LOWER($1) OR LOWER(email) = LOWER($1)`
+	case CREATE_SESSION:
+		return `INSERT INTO sessions (token_hash, fk_user, expires_at) VALUES ($1, $2, $3)`
+	case SELECT_SESSION_USER:
+		return `SELECT users.id, users.email, users.username
+				FROM sessions
+				INNER JOIN users ON users.id = sessions.fk_user
+				WHERE sessions.token_hash = $1 AND sessions.expires_at > NOW()`
+	case DELETE_SESSION:
+		return `DELETE FROM sessions WHERE token_hash = $1`
+	case SELECT_RATINGS:
+		return `SELECT pool, rating, deviation, volatility, games FROM ratings WHERE fk_user = $1 ORDER BY pool ASC`
+	case SELECT_RATING_FOR_UPDATE:
+		return `SELECT rating, deviation, volatility, games FROM ratings WHERE fk_user = $1 AND pool = $2 FOR UPDATE`
+	case UPSERT_RATING:
+		return `INSERT INTO ratings (fk_user, pool, rating, deviation, volatility, games)
+				VALUES ($1, $2, $3, $4, $5, 1)
+				ON CONFLICT (fk_user, pool) DO UPDATE
+				SET rating = $3, deviation = $4, volatility = $5, games = ratings.games + 1, updated_at = NOW()`
+	case CREATE_RATING_HISTORY:
+		return `INSERT INTO rating_history (fk_user, pool, fk_game, rating, deviation, volatility) VALUES ($1, $2, $3, $4, $5, $6)`
+	case SELECT_RATING_HISTORY:
+		return `SELECT fk_game, rating, deviation, volatility, created_at
+				FROM rating_history
+				WHERE fk_user = $1 AND pool = $2
+				ORDER BY created_at ASC, id ASC`
+	case SELECT_NOTIFICATIONS:
+		// Newest first, only unread ones when $2 is true
+		return `SELECT id, fk_user, fk_board, fk_game, kind, deadline, created_at, read_at IS NOT NULL
+				FROM notifications
+				WHERE fk_user = $1 AND (NOT $2 OR read_at IS NULL)
+				ORDER BY id DESC
+				LIMIT $3`
+	case MARK_NOTIFICATION_READ:
+		return `UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND fk_user = $2`
 	}
 
 	panic("Invalid query select")
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	. "remotechess/src/rc_server/api"
+	. "remotechess/src/rc_server/api/auth"
 	. "remotechess/src/rc_server/api/chessboards"
 	. "remotechess/src/rc_server/api/games"
 	. "remotechess/src/rc_server/api/invitations"
+	. "remotechess/src/rc_server/api/matchmaking"
 	. "remotechess/src/rc_server/api/usercore"
-	"remotechess/src/rc_server/rcdb"
+	"remotechess/src/rc_server/api/utility"
 	. "remotechess/src/rc_server/servercore"
-	sv "remotechess/src/rc_server/service"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -31,15 +32,23 @@ func ContentResponder(w http.ResponseWriter, r *http.Request, v interface{}) {
 			render.PlainText(w, r, errResp.Detail)
 		} else if stringer, ok := v.(fmt.Stringer); ok {
 			render.PlainText(w, r, stringer.String())
+		} else {
+			// No plain text form, e.g. a stale move's current game, which is still better sent than dropped
+			render.DefaultResponder(w, r, v)
 		}
 	default:
 		render.DefaultResponder(w, r, v)
 	}
 }
 
-func InitServer() {
-	sv.Db = rcdb.ConnectToDb()
+func InitServer(server *ServerCore) {
+	cfg := server.Config
+
 	render.Respond = ContentResponder
+
+	server.Games.StartCorrespondenceScheduler(cfg.Correspondence.CheckInterval)
+	server.Games.StartBotScheduler()
+	server.Games.StartFlagScheduler(cfg.Clock.FlagSweepInterval)
 }
 
 func Routes(server *ServerCore) {
@@ -47,13 +56,21 @@ func Routes(server *ServerCore) {
 	cbh := NewChessboardHandler(server)
 	gh := NewGameHandler(server)
 	ih := NewInvitationHandler(server)
+	ah := NewAuthHandler(server)
+	mh := NewMatchmakingHandler(server)
 
 	server.Router.Route("/api", func(r chi.Router) {
+		r.Use(utility.Cors(server.Config.Server.CORSOrigins, BoardSecretHeader, IdempotencyKeyHeader))
 		r.Use(render.SetContentType(render.ContentTypeJSON))
+		r.Use(utility.WithLogger(server.Log))
+		r.Use(Authenticate(server.Users))
+
+		r.Route("/auth", ah.Router)
 
 		r.Route("/usercore/{userId}", uch.Router())
 		r.Route("/chessboard/{boardId}", cbh.Router)
 		r.Route("/game", gh.Router)
 		r.Route("/invites", ih.Router())
+		r.Route("/matchmaking/{boardId}", mh.Router)
 	})
 }
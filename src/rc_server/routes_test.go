@@ -0,0 +1,381 @@
+package rc_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"remotechess/src/rc_server/api/auth"
+	"remotechess/src/rc_server/config"
+	. "remotechess/src/rc_server/servercore"
+	"remotechess/src/rc_server/storage/memory"
+
+	"github.com/go-chi/render"
+)
+
+// A server on the in-memory repositories, without the background schedulers
+type testServer struct {
+	t      *testing.T
+	server ServerCore
+}
+
+type testBoard struct {
+	id     uint64
+	secret string
+}
+
+type testUser struct {
+	id    uint64
+	token string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	render.Respond = ContentResponder
+
+	ts := &testServer{t: t, server: NewServerCore(config.Default(), memory.NewRepositories())}
+	Routes(&ts.server)
+
+	return ts
+}
+
+// Send a request with the given headers and decode the JSON answer into out, if it is not nil
+func (ts *testServer) do(method string, path string, body string, headers map[string]string, out interface{}) int {
+	ts.t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.server.Router.ServeHTTP(rec, req)
+
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			ts.t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+func (ts *testServer) expect(want int, method string, path string, headers map[string]string, out interface{}) {
+	ts.t.Helper()
+
+	if got := ts.do(method, path, "", headers, out); got != want {
+		ts.t.Fatalf("%s %s: status %d, want %d", method, path, got, want)
+	}
+}
+
+func (ts *testServer) registerBoard(id uint64) testBoard {
+	ts.t.Helper()
+
+	var resp struct{ Secret string }
+	ts.expect(200, "GET", fmt.Sprintf("/api/chessboard/%d/register", id), nil, &resp)
+
+	return testBoard{id, resp.Secret}
+}
+
+func (ts *testServer) registerUser(name string) testUser {
+	ts.t.Helper()
+
+	body := fmt.Sprintf(`{"email": "%s@example.com", "username": "%s", "password": "correct horse"}`, name, name)
+
+	if code := ts.do("POST", "/api/auth/register", body, nil, nil); code != 200 {
+		ts.t.Fatalf("registering %s: status %d", name, code)
+	}
+
+	var session struct {
+		Token  string
+		UserId uint64
+	}
+
+	if code := ts.do("POST", "/api/auth/login", fmt.Sprintf(`{"login": "%s", "password": "correct horse"}`, name), nil, &session); code != 200 {
+		ts.t.Fatalf("logging in %s: status %d", name, code)
+	}
+
+	return testUser{session.UserId, session.Token}
+}
+
+func (b testBoard) headers() map[string]string {
+	return map[string]string{auth.BoardSecretHeader: b.secret}
+}
+
+func (u testUser) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + u.token}
+}
+
+type testGameState struct {
+	Id            uint64
+	Version       int64
+	Turn          string
+	TakebackPlies int
+}
+
+func (ts *testServer) currentGame(b testBoard) testGameState {
+	ts.t.Helper()
+
+	var state testGameState
+	ts.expect(200, "GET", fmt.Sprintf("/api/chessboard/%d/currentgame", b.id), b.headers(), &state)
+
+	return state
+}
+
+func (ts *testServer) gameState(gameId uint64, b testBoard) testGameState {
+	ts.t.Helper()
+
+	var state testGameState
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/%d/gamestate", gameId), b.headers(), &state)
+
+	return state
+}
+
+func (ts *testServer) move(gameId uint64, b testBoard, move string, headers map[string]string) int {
+	ts.t.Helper()
+
+	h := b.headers()
+
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	return ts.do("GET", fmt.Sprintf("/api/game/%d/move/%d/%s", gameId, b.id, move), "", h, nil)
+}
+
+// Confirm the opponent's last move is on the board, which has to happen before replying to it
+func (ts *testServer) mirror(gameId uint64, b testBoard) {
+	ts.t.Helper()
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/%d/mirror/%d/confirm", gameId, b.id), b.headers(), nil)
+}
+
+// Have the user claim the board, which takes both the user's session and the board's secret
+func (ts *testServer) claimBoard(u testUser, b testBoard) {
+	ts.t.Helper()
+
+	headers := u.headers()
+	headers[auth.BoardSecretHeader] = b.secret
+
+	ts.expect(200, "GET", fmt.Sprintf("/api/usercore/%d/registerboard/%d", u.id, b.id), headers, nil)
+}
+
+// Two boards of the same owner with a game between them, the first playing White
+func (ts *testServer) startGame() (uint64, testBoard, testBoard) {
+	ts.t.Helper()
+
+	owner := ts.registerUser("owner")
+	white, black := ts.registerBoard(101), ts.registerBoard(102)
+	ts.claimBoard(owner, white)
+	ts.claimBoard(owner, black)
+
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/create/w/%d/b/%d", white.id, black.id), owner.headers(), nil)
+
+	game := ts.currentGame(white)
+
+	if ts.currentGame(black).Id != game.Id {
+		ts.t.Fatalf("the boards show different games")
+	}
+
+	return game.Id, white, black
+}
+
+func TestCreateGame(t *testing.T) {
+	ts := newTestServer(t)
+	white, black := ts.registerBoard(101), ts.registerBoard(102)
+	path := fmt.Sprintf("/api/game/create/w/%d/b/%d", white.id, black.id)
+
+	// Either secret only proves control of its own board
+	ts.expect(401, "GET", path, white.headers(), nil)
+	ts.expect(401, "GET", path, nil, nil)
+
+	owner := ts.registerUser("owner")
+	ts.claimBoard(owner, white)
+	ts.claimBoard(owner, black)
+
+	ts.expect(200, "GET", path, owner.headers(), nil)
+
+	state := ts.currentGame(white)
+
+	if state.Turn != "WHITE" || state.Version != 0 {
+		t.Fatalf("new game: turn %s, version %d", state.Turn, state.Version)
+	}
+
+	if ts.currentGame(black).Id != state.Id {
+		t.Fatalf("the boards show different games")
+	}
+
+	// Both boards are busy now
+	ts.expect(409, "GET", path, owner.headers(), nil)
+}
+
+func TestMove(t *testing.T) {
+	ts := newTestServer(t)
+	gameId, white, black := ts.startGame()
+
+	if code := ts.move(gameId, black, "e7e5", nil); code != 405 {
+		t.Fatalf("moving out of turn: status %d, want 405", code)
+	}
+
+	if code := ts.move(gameId, white, "e2e5", nil); code != 405 {
+		t.Fatalf("illegal move: status %d, want 405", code)
+	}
+
+	if code := ts.move(gameId, white, "e2e4", nil); code != 200 {
+		t.Fatalf("e2e4: status %d", code)
+	}
+
+	ts.mirror(gameId, black)
+
+	if code := ts.move(gameId, black, "e7e5", nil); code != 200 {
+		t.Fatalf("e7e5: status %d", code)
+	}
+
+	// Chosen before Black replied
+	if code := ts.do("GET", fmt.Sprintf("/api/game/%d/move/%d/g1f3?version=1", gameId, white.id), "", white.headers(), nil); code != 409 {
+		t.Fatalf("move at an old version: status %d, want 409", code)
+	}
+
+	if state := ts.gameState(gameId, white); state.Turn != "WHITE" || state.Version != 2 {
+		t.Fatalf("after two moves: turn %s, version %d", state.Turn, state.Version)
+	}
+}
+
+func TestIdempotentMoveRetry(t *testing.T) {
+	ts := newTestServer(t)
+	gameId, white, black := ts.startGame()
+	key := map[string]string{"Idempotency-Key": "first-move"}
+
+	for i := 0; i < 2; i++ {
+		if code := ts.move(gameId, white, "e2e4", key); code != 200 {
+			t.Fatalf("attempt %d: status %d", i+1, code)
+		}
+	}
+
+	if state := ts.gameState(gameId, white); state.Turn != "BLACK" || state.Version != 1 {
+		t.Fatalf("after a retried move: turn %s, version %d", state.Turn, state.Version)
+	}
+
+	// The key belongs to e2e4, it cannot be reused for another move
+	if code := ts.move(gameId, white, "d2d4", key); code != 422 {
+		t.Fatalf("reusing the key: status %d, want 422", code)
+	}
+
+	ts.mirror(gameId, black)
+	ts.move(gameId, black, "e7e5", nil)
+
+	// A retry that arrives after the opponent replied still reports success without playing anything
+	if code := ts.move(gameId, white, "e2e4", key); code != 200 {
+		t.Fatalf("late retry: status %d", code)
+	}
+
+	if state := ts.gameState(gameId, white); state.Version != 2 {
+		t.Fatalf("after a late retry: version %d, want 2", state.Version)
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	ts := newTestServer(t)
+	gameId, white, black := ts.startGame()
+
+	ts.move(gameId, white, "e2e4", nil)
+	ts.mirror(gameId, black)
+	ts.move(gameId, black, "e7e5", nil)
+
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/%d/takeback/%d/request/1", gameId, black.id), black.headers(), nil)
+
+	if state := ts.gameState(gameId, white); state.TakebackPlies != 1 {
+		t.Fatalf("pending takeback: %d plies, want 1", state.TakebackPlies)
+	}
+
+	// Only the opponent can answer it
+	ts.expect(409, "GET", fmt.Sprintf("/api/game/%d/takeback/%d/accept", gameId, black.id), black.headers(), nil)
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/%d/takeback/%d/accept", gameId, white.id), white.headers(), nil)
+
+	if state := ts.gameState(gameId, white); state.Turn != "BLACK" || state.TakebackPlies != 0 {
+		t.Fatalf("after the takeback: turn %s, %d plies pending", state.Turn, state.TakebackPlies)
+	}
+
+	if code := ts.move(gameId, black, "c7c5", nil); code != 200 {
+		t.Fatalf("replaying the taken back move: status %d", code)
+	}
+}
+
+func TestAcceptInvite(t *testing.T) {
+	ts := newTestServer(t)
+	alice, bob := ts.registerUser("alice"), ts.registerUser("bobby")
+	aliceBoard, bobBoard := ts.registerBoard(201), ts.registerBoard(202)
+	ts.claimBoard(alice, aliceBoard)
+	ts.claimBoard(bob, bobBoard)
+
+	ts.expect(200, "GET", fmt.Sprintf("/api/invites/send/f/%d/t/%d", aliceBoard.id, bob.id), aliceBoard.headers(), nil)
+
+	var pending struct {
+		Invites []struct {
+			InviteId  int
+			YourColor string
+		}
+	}
+
+	ts.expect(200, "GET", fmt.Sprintf("/api/invites/pending/%d", bob.id), bob.headers(), &pending)
+
+	if len(pending.Invites) != 1 || pending.Invites[0].YourColor != "BLACK" {
+		t.Fatalf("pending invites: %+v", pending.Invites)
+	}
+
+	accept := fmt.Sprintf("/api/invites/accept/%d/r/%d/black", pending.Invites[0].InviteId, bobBoard.id)
+
+	// Alice cannot answer for Bob's board
+	ts.expect(403, "GET", accept, alice.headers(), nil)
+
+	var game testGameState
+	ts.expect(200, "GET", accept, bob.headers(), &game)
+
+	if ts.currentGame(aliceBoard).Id != game.Id || ts.currentGame(bobBoard).Id != game.Id {
+		t.Fatalf("the boards do not show the accepted game %d", game.Id)
+	}
+
+	if code := ts.move(game.Id, aliceBoard, "d2d4", nil); code != 200 {
+		t.Fatalf("the sender's first move: status %d", code)
+	}
+
+	// Accepting starts the game and withdraws the invite
+	ts.expect(404, "GET", accept, bob.headers(), nil)
+}
+
+// The sync instructions give the position away, only the boards playing the game may ask for them
+func TestSyncRefusesNonPlayer(t *testing.T) {
+	ts := newTestServer(t)
+	gameId, white, _ := ts.startGame()
+	outsider := ts.registerBoard(301)
+
+	// Every piece on its starting square
+	query := "?occupancy=0xFFFF00000000FFFF"
+
+	ts.expect(403, "GET", fmt.Sprintf("/api/game/%d/sync/%d%s", gameId, outsider.id, query), outsider.headers(), nil)
+
+	var report struct{ InSync bool }
+	ts.expect(200, "GET", fmt.Sprintf("/api/game/%d/sync/%d%s", gameId, white.id, query), white.headers(), &report)
+
+	if !report.InSync {
+		t.Fatalf("the starting position is reported out of sync")
+	}
+}
+
+// Game histories list private games too, so only the user or the board they belong to may read them
+func TestPrivateHistory(t *testing.T) {
+	ts := newTestServer(t)
+	alice, bob := ts.registerUser("alice"), ts.registerUser("bobby")
+	board := ts.registerBoard(201)
+	ts.claimBoard(alice, board)
+
+	ts.expect(403, "GET", fmt.Sprintf("/api/usercore/%d/games", alice.id), bob.headers(), nil)
+	ts.expect(200, "GET", fmt.Sprintf("/api/usercore/%d/games", alice.id), alice.headers(), nil)
+
+	ts.expect(403, "GET", fmt.Sprintf("/api/chessboard/%d/games", board.id), bob.headers(), nil)
+	ts.expect(401, "GET", fmt.Sprintf("/api/chessboard/%d/games", board.id), nil, nil)
+	ts.expect(200, "GET", fmt.Sprintf("/api/chessboard/%d/games", board.id), alice.headers(), nil)
+	ts.expect(200, "GET", fmt.Sprintf("/api/chessboard/%d/games", board.id), board.headers(), nil)
+}
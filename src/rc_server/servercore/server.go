@@ -1,22 +1,51 @@
 package servercore
 
 import (
-	// "database/sql"
-	// "remotechess/src/rc_server/db"
+	"remotechess/src/rc_server/config"
+	"remotechess/src/rc_server/logging"
+	"remotechess/src/rc_server/service/chessboards"
+	"remotechess/src/rc_server/service/engine"
+	"remotechess/src/rc_server/service/events"
+	"remotechess/src/rc_server/service/games"
+	"remotechess/src/rc_server/service/invitations"
+	"remotechess/src/rc_server/service/matchmaking"
+	"remotechess/src/rc_server/service/usercore"
+	"remotechess/src/rc_server/storage"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type ServerCore struct {
-	//Database *sql.DB
-	Router *chi.Mux
+	Router       *chi.Mux
+	Config       config.Config
+	Repositories storage.Repositories
+	Log          *logging.Logger
+	Engine       *engine.Pool
+
+	Users       *usercore.UserService
+	Boards      *chessboards.BoardService
+	Events      *events.EventService
+	Games       *games.GameService
+	Invitations *invitations.InvitationService
+	Matchmaking *matchmaking.Matchmaker
 }
 
-func NewServerCore() ServerCore {
+// The services store everything in repos and are set up from cfg. Engines are only started once an analysis needs one.
+func NewServerCore(cfg config.Config, repos storage.Repositories) ServerCore {
 	var s ServerCore
 
 	s.Router = chi.NewRouter()
-	//s.Database = db.ConnectToDb()
+	s.Config = cfg
+	s.Repositories = repos
+	s.Log = logging.New(cfg.LogLevel())
+	s.Engine = engine.NewPool(cfg.Engine.Path, cfg.Engine.Workers)
+
+	s.Users = usercore.NewUserService(repos.Users, repos.Friends, repos.Ratings, repos.Notifications)
+	s.Boards = chessboards.NewBoardService(repos.Chessboards)
+	s.Events = events.NewEventService(repos.Events)
+	s.Games = games.NewGameService(repos.Transactor, repos.Games, repos.Moves, repos.Chat, s.Boards, s.Users, s.Events, s.Engine, s.Log)
+	s.Invitations = invitations.NewInvitationService(repos.Transactor, repos.Invitations, s.Boards, s.Games)
+	s.Matchmaking = matchmaking.NewMatchmaker(s.Games, s.Boards, s.Users, s.Log)
 
 	return s
 }
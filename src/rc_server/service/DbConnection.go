@@ -1,8 +0,0 @@
-package service
-
-import (
-	"database/sql"
-	// . "remotechess/src/rc_server/rcdb"
-)
-
-var Db *sql.DB
@@ -9,6 +9,8 @@ const (
 	NOT_SENSITIVE = false
 )
 
+const conflictFormat = "%s was changed in the meantime"
+
 type ServiceError struct {
 	Detail          string
 	HttpCodeHint    int
@@ -40,6 +42,24 @@ func NewInvalidInputError(detail string) *ServiceError {
 	return &ServiceError{detail, 400, NOT_SENSITIVE, "%s is malformed"}
 }
 
+func NewConflictError(detail string) *ServiceError {
+	return &ServiceError{detail, 409, NOT_SENSITIVE, conflictFormat}
+}
+
 func NewGenericError(detail string, httpCodeHint int, sensitivityHint SensitivityLevel) *ServiceError {
 	return &ServiceError{detail, httpCodeHint, sensitivityHint, "%s"}
 }
+
+// Whether err reports that something looked up does not exist
+func IsDoesNotExist(err error) bool {
+	serviceErr, ok := err.(*ServiceError)
+
+	return ok && serviceErr.HttpCodeHint == 404
+}
+
+// Whether err reports that something was changed by someone else since it was read
+func IsConflict(err error) bool {
+	serviceErr, ok := err.(*ServiceError)
+
+	return ok && serviceErr.format == conflictFormat
+}
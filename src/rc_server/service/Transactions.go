@@ -0,0 +1,46 @@
+package service
+
+import "context"
+
+// Groups repository calls into one transaction. Every repository call made with the context
+// passed to fn takes part in it, and all of them are undone if fn returns an error.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type afterCommitKey struct{}
+
+type afterCommitHooks struct {
+	hooks []func()
+}
+
+// Run fn as one unit of work on t. A call made with the context of another unit of work joins it,
+// so service operations can be composed into a single transaction by passing ctx along.
+func WithinTx(ctx context.Context, t Transactor, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		return t.WithinTx(ctx, fn)
+	}
+
+	pending := &afterCommitHooks{}
+
+	if err := t.WithinTx(context.WithValue(ctx, afterCommitKey{}, pending), fn); err != nil {
+		return err
+	}
+
+	for _, hook := range pending.hooks {
+		hook()
+	}
+
+	return nil
+}
+
+// Run hook once the unit of work ctx belongs to has been committed, or right away outside of one.
+// Anything other requests should only see once it is stored, e.g. events and timers, goes here.
+// Hooks are dropped when the transaction is rolled back.
+func AfterCommit(ctx context.Context, hook func()) {
+	if pending, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		pending.hooks = append(pending.hooks, hook)
+	} else {
+		hook()
+	}
+}
@@ -0,0 +1,35 @@
+package chessboards
+
+import (
+	"context"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+const (
+	MIN_BOT_LEVEL = 1
+	MAX_BOT_LEVEL = 8
+)
+
+// Onboard IDs from here up are reserved for the computer, one virtual board per strength level.
+// Physical boards cannot be registered with them.
+const BOT_ONBOARD_ID_BASE uint64 = 1 << 62
+
+func (cb *Chessboard) IsBot() bool {
+	return cb.BotLevel.Valid
+}
+
+// The virtual board the computer plays on at the given strength, created the first time it is needed
+func (s *BoardService) FetchBotBoard(level int) (*Chessboard, error) {
+	if level < MIN_BOT_LEVEL || level > MAX_BOT_LEVEL {
+		return nil, sv.NewInvalidInputError("Bot level")
+	}
+
+	onboardId := BOT_ONBOARD_ID_BASE + uint64(level)
+
+	if err := s.boards.CreateBot(context.Background(), onboardId, level); err != nil {
+		return nil, err
+	}
+
+	return s.FetchChessboard(onboardId)
+}
@@ -1,91 +1,113 @@
 package chessboards
 
 import (
-	// "database/sql"
-
+	"context"
 	"database/sql"
-	. "remotechess/src/rc_server/rcdb/chessboards"
 
-	//. "remotechess/src/rc_server/rcdb/chessboards"
 	sv "remotechess/src/rc_server/service"
 	. "remotechess/src/rc_server/service/usercore"
-
-	"github.com/lib/pq"
 )
 
 type Chessboard struct {
-	OnboardId uint64
-	OwnerId   sql.NullInt64
-	CurGame   sql.NullInt64
+	OnboardId   uint64
+	OwnerId     sql.NullInt64
+	FocusedGame sql.NullInt64 // The game the board shows, out of all it plays in
+	BotLevel    sql.NullInt64 // Set for the virtual boards the computer plays on
 }
 
-func FetchChessboard(onboardId uint64) (*Chessboard, error) {
-	var cb Chessboard
-
-	row := sv.Db.QueryRow(GetChessboardQuery(SELECT_BOARD), onboardId)
+func (s *BoardService) FetchChessboard(onboardId uint64) (*Chessboard, error) {
+	cb, err := s.boards.Fetch(context.Background(), onboardId)
 
-	if row.Err() != nil {
-		return &cb, sv.NewInternalError("FetchChessboard " + row.Err().Error())
-	}
-
-	err := row.Scan(&cb.OnboardId, &cb.OwnerId, &cb.CurGame)
-
-	if err == sql.ErrNoRows {
-		return &cb, sv.NewDoesNotExistError("Chessboard")
-	} else {
-		return &cb, nil
-	}
+	return &cb, err
 }
 
-func RegisterNewChessboard(onboardId uint64) (Chessboard, error) {
+// Register a new chessboard and issue its device secret. The secret is only ever returned here
+// and by RotateSecret, the board must keep it to authenticate its requests.
+func (s *BoardService) RegisterNewChessboard(onboardId uint64) (Chessboard, string, error) {
 	var cb Chessboard
 
-	row := sv.Db.QueryRow(GetChessboardQuery(REGISTER_BOARD), onboardId)
+	if onboardId >= BOT_ONBOARD_ID_BASE {
+		return cb, "", sv.NewInvalidInputError("Onboard ID")
+	}
 
-	if row.Err() != nil {
-		pqErr, ok := row.Err().(*pq.Error)
+	secret, err := newDeviceSecret()
 
-		if ok {
-			if pqErr.Code == "23505" {
-				return cb, sv.NewAlreadyExistsError("Chessboard")
-			} else {
-				return cb, sv.NewInternalError("RegisterNewChessboard " + row.Err().Error())
-			}
-		} else {
-			return cb, sv.NewInternalError("RegisterNewChessboard " + row.Err().Error())
-		}
+	if err != nil {
+		return cb, "", err
 	}
 
-	err := row.Scan(&cb.OnboardId, &cb.OwnerId)
+	cb, err = s.boards.Register(context.Background(), onboardId, hashSecret(secret))
 
 	if err != nil {
-		return cb, sv.NewInternalError("RegisterNewChessboard " + err.Error())
-	} else {
-		return cb, nil
+		return cb, "", err
 	}
+
+	return cb, secret, nil
 }
 
 // Only works if the chessboard does not previously have an owner
-func (cb *Chessboard) AssignFirstOwner(owner UserCore) error {
-	res, err := sv.Db.Exec(GetChessboardQuery(ASSIGN_FIRST_OWNER), owner.Id, cb.OnboardId)
+func (s *BoardService) AssignFirstOwner(cb *Chessboard, owner UserCore) error {
+	assigned, err := s.boards.AssignFirstOwner(context.Background(), cb.OnboardId, owner.Id)
 
 	if err != nil {
-		return sv.NewInternalError("AssignFirstOwner " + err.Error())
-	} else if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
+		return err
+	} else if !assigned {
 		return sv.NewGenericError("Chessboard already has owner", 405, sv.NOT_SENSITIVE)
 	}
 
 	return nil
 }
 
-func (cb *Chessboard) LeaveGame() error {
-	res, err := sv.Db.Exec(GetChessboardQuery(UPDATE_CURRENT_GAME), cb.OnboardId, nil)
+// Stop showing the focused game. The game itself carries on.
+func (s *BoardService) LeaveGame(cb *Chessboard) error {
+	return s.boards.SetFocusedGame(context.Background(), cb.OnboardId, sql.NullInt64{})
+}
+
+// Switch the board to showing another of its games
+func (s *BoardService) FocusGame(cb *Chessboard, gameId uint64) error {
+	if err := s.boards.FocusGame(context.Background(), cb.OnboardId, gameId); err != nil {
+		return err
+	}
+
+	cb.FocusedGame = sql.NullInt64{Int64: int64(gameId), Valid: true}
+
+	return nil
+}
+
+// Show a game that has just started on the boards playing it. Correspondence games run alongside
+// whatever else the boards are playing, so they only take the focus of idle boards.
+func (s *BoardService) FocusNewGame(ctx context.Context, gameId uint64, white *Chessboard, black *Chessboard, onlyIdle bool) error {
+	focused, err := s.boards.FocusNewGame(ctx, gameId, white.OnboardId, black.OnboardId, onlyIdle)
 
 	if err != nil {
-		return sv.NewInternalError("LeaveGame " + err.Error())
-	} else if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("LeaveGame did not affect 1 row")
+		return err
+	}
+
+	for _, onboardId := range focused {
+		for _, cb := range []*Chessboard{white, black} {
+			if cb.OnboardId == onboardId {
+				cb.FocusedGame = sql.NullInt64{Int64: int64(gameId), Valid: true}
+			}
+		}
 	}
 
 	return nil
 }
+
+// Keep other units of work from starting games on or changing the games of these boards until
+// the one ctx belongs to is over. Only meaningful inside sv.WithinTx.
+//
+// The computer's boards are left out. They play any number of games, which would otherwise all wait on
+// one another, and the human board of each of those games is enough to keep its changes apart.
+func (s *BoardService) LockChessboards(ctx context.Context, onboardIds ...uint64) error {
+	ids := []uint64{}
+
+	// Archived games may have been played against someone without a board here
+	for _, id := range onboardIds {
+		if id != 0 && id < BOT_ONBOARD_ID_BASE {
+			ids = append(ids, id)
+		}
+	}
+
+	return s.boards.Lock(ctx, ids)
+}
@@ -0,0 +1,71 @@
+package chessboards
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// Check a device secret presented by a request claiming to come from this chessboard
+func (s *BoardService) Authenticate(cb *Chessboard, secret string) error {
+	stored, err := s.boards.FetchSecretHash(context.Background(), cb.OnboardId)
+
+	if err != nil {
+		return err
+	}
+
+	if stored == nil {
+		return sv.NewGenericError("Chessboard credentials have been revoked", 401, sv.NOT_SENSITIVE)
+	}
+
+	if subtle.ConstantTimeCompare(stored, hashSecret(secret)) != 1 {
+		return sv.NewGenericError("Invalid chessboard credentials", 401, sv.NOT_SENSITIVE)
+	}
+
+	return nil
+}
+
+// Replace the board's device secret with a new one. The old secret stops working immediately.
+// Used both by boards rotating their own secret and by owners re-keying a board after revoking it.
+func (s *BoardService) RotateSecret(cb *Chessboard) (string, error) {
+	secret, err := newDeviceSecret()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.setSecretHash(cb, hashSecret(secret)); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// Stop the board from authenticating until it is re-keyed
+func (s *BoardService) RevokeSecret(cb *Chessboard) error {
+	return s.setSecretHash(cb, nil)
+}
+
+func (s *BoardService) setSecretHash(cb *Chessboard, hash []byte) error {
+	return s.boards.SetSecretHash(context.Background(), cb.OnboardId, hash)
+}
+
+func newDeviceSecret() (string, error) {
+	raw := make([]byte, 32)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", sv.NewInternalError("newDeviceSecret " + err.Error())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Like session tokens, only hashes of device secrets are stored
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
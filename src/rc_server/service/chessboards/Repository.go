@@ -0,0 +1,37 @@
+package chessboards
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Where chessboards are stored. Missing boards are reported with a DoesNotExist error.
+type ChessboardRepository interface {
+	Fetch(ctx context.Context, onboardId uint64) (Chessboard, error)
+	Register(ctx context.Context, onboardId uint64, secretHash []byte) (Chessboard, error)
+	AssignFirstOwner(ctx context.Context, onboardId uint64, ownerId uint64) (bool, error) // False if the board already had an owner
+	CreateBot(ctx context.Context, onboardId uint64, level int) error                     // Does nothing if the board exists
+
+	FetchSecretHash(ctx context.Context, onboardId uint64) ([]byte, error) // Nil once revoked
+	SetSecretHash(ctx context.Context, onboardId uint64, hash []byte) error
+
+	SetFocusedGame(ctx context.Context, onboardId uint64, gameId sql.NullInt64) error
+	FocusGame(ctx context.Context, onboardId uint64, gameId uint64) error // Only a game the board plays in
+
+	// Focus both boards of a new game on it, or when onlyIdle is set only those not focused on an
+	// ongoing game. Returns the boards that switched.
+	FocusNewGame(ctx context.Context, gameId uint64, whiteId uint64, blackId uint64, onlyIdle bool) ([]uint64, error)
+
+	// Hold the boards until the transaction ctx carries ends. Boards are always locked in the same
+	// order, so two transactions locking overlapping boards wait for each other instead of deadlocking.
+	Lock(ctx context.Context, onboardIds []uint64) error
+}
+
+// Registers boards and keeps track of who owns them and what they show
+type BoardService struct {
+	boards ChessboardRepository
+}
+
+func NewBoardService(boards ChessboardRepository) *BoardService {
+	return &BoardService{boards: boards}
+}
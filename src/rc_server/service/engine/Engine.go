@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+const (
+	MAX_DEPTH     = 30
+	MAX_MOVE_TIME = 10 * time.Second
+
+	DEFAULT_MOVE_TIME = time.Second
+
+	// How long an engine may take to answer anything but a search before it is considered hung
+	handshakeTimeout = 10 * time.Second
+)
+
+// How long the engine may search a single position. A zero Depth means only MoveTime applies.
+type Limits struct {
+	Depth    int
+	MoveTime time.Duration
+}
+
+// An evaluation from White's point of view. Mate is the number of moves until
+// mate, negative if Black mates, and zero when no mate was found.
+type Score struct {
+	Centipawns int
+	Mate       int
+}
+
+type Analysis struct {
+	BestMove string // UCI notation, empty if the side to move has no legal moves
+	Score    Score
+	Depth    int
+	Pv       []string // The line the engine expects, starting with BestMove
+}
+
+// A running UCI engine process. An Engine is not safe for concurrent use, the Pool hands each one out to a single caller.
+type Engine struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+}
+
+func NewLimits(depth int, moveTime time.Duration) (Limits, error) {
+	if depth < 0 || depth > MAX_DEPTH {
+		return Limits{}, sv.NewInvalidInputError("Depth")
+	}
+
+	if moveTime < 0 || moveTime > MAX_MOVE_TIME {
+		return Limits{}, sv.NewInvalidInputError("Move time")
+	}
+
+	if depth == 0 && moveTime == 0 {
+		moveTime = DEFAULT_MOVE_TIME
+	}
+
+	return Limits{Depth: depth, MoveTime: moveTime}, nil
+}
+
+// Start an engine binary and wait until it reports that it speaks UCI
+func StartEngine(path string, args ...string) (*Engine, error) {
+	cmd := exec.Command(path, args...)
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, sv.NewInternalError("StartEngine " + err.Error())
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, sv.NewInternalError("StartEngine " + err.Error())
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, newEngineUnavailableError()
+	}
+
+	e := &Engine{cmd: cmd, stdin: stdin, lines: make(chan string, 64)}
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+
+		for scanner.Scan() {
+			e.lines <- scanner.Text()
+		}
+
+		close(e.lines)
+	}()
+
+	if err := e.send("uci"); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if _, err := e.waitFor("uciok", handshakeTimeout, nil); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if err := e.ready(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Search a position, given as a starting FEN ("" for the standard start) and the UCI moves played from it
+func (e *Engine) Analyse(fen string, moves []string, limits Limits) (*Analysis, error) {
+	position := "position startpos"
+
+	if fen != "" {
+		position = "position fen " + fen
+	}
+
+	if len(moves) > 0 {
+		position += " moves " + strings.Join(moves, " ")
+	}
+
+	goCmd := "go"
+
+	if limits.Depth > 0 {
+		goCmd += " depth " + strconv.Itoa(limits.Depth)
+	}
+
+	if limits.MoveTime > 0 {
+		goCmd += " movetime " + strconv.FormatInt(limits.MoveTime.Milliseconds(), 10)
+	}
+
+	if err := e.send(position); err != nil {
+		return nil, err
+	}
+
+	if err := e.send(goCmd); err != nil {
+		return nil, err
+	}
+
+	analysis := Analysis{}
+
+	// Depth-limited searches have no time limit of their own, so allow plenty
+	timeout := limits.MoveTime + handshakeTimeout
+
+	if limits.Depth > 0 {
+		timeout += MAX_MOVE_TIME * 3
+	}
+
+	line, err := e.waitFor("bestmove", timeout, func(info string) { parseInfo(info, &analysis) })
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fields := strings.Fields(line); len(fields) >= 2 && fields[1] != "(none)" && fields[1] != "0000" {
+		analysis.BestMove = fields[1]
+	}
+
+	// The engine scores from the side to move's point of view
+	if blackToMove(fen, len(moves)) {
+		analysis.Score.Centipawns = -analysis.Score.Centipawns
+		analysis.Score.Mate = -analysis.Score.Mate
+	}
+
+	return &analysis, nil
+}
+
+func (e *Engine) Close() {
+	e.send("quit")
+	e.stdin.Close()
+
+	// Keep the reader from blocking on output nobody will read
+	go func() {
+		for range e.lines {
+		}
+	}()
+
+	done := make(chan struct{})
+
+	go func() {
+		e.cmd.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		e.cmd.Process.Kill()
+	}
+}
+
+func (e *Engine) ready() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+
+	_, err := e.waitFor("readyok", handshakeTimeout, nil)
+
+	return err
+}
+
+func (e *Engine) send(command string) error {
+	if _, err := fmt.Fprintln(e.stdin, command); err != nil {
+		return sv.NewInternalError("Engine " + err.Error())
+	}
+
+	return nil
+}
+
+// Read lines until one starts with token, handing every "info" line before it to onInfo
+func (e *Engine) waitFor(token string, timeout time.Duration, onInfo func(string)) (string, error) {
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case line, open := <-e.lines:
+			if !open {
+				return "", sv.NewInternalError("Engine exited unexpectedly")
+			}
+
+			if strings.HasPrefix(line, token) {
+				return line, nil
+			}
+
+			if onInfo != nil && strings.HasPrefix(line, "info ") {
+				onInfo(line)
+			}
+		case <-deadline:
+			return "", sv.NewInternalError("Engine did not answer in time")
+		}
+	}
+}
+
+// Pick the depth, score and principal variation out of an info line. Later lines overwrite earlier ones.
+func parseInfo(line string, analysis *Analysis) {
+	fields := strings.Fields(line)
+
+	// Bounds are only provisional scores, and multipv lines other than the first are not the best line
+	for i, f := range fields {
+		if f == "lowerbound" || f == "upperbound" {
+			return
+		}
+
+		if f == "multipv" && i+1 < len(fields) && fields[i+1] != "1" {
+			return
+		}
+	}
+
+	for i := 0; i < len(fields)-1; i++ {
+		switch fields[i] {
+		case "depth":
+			analysis.Depth, _ = strconv.Atoi(fields[i+1])
+		case "score":
+			if i+2 >= len(fields) {
+				continue
+			}
+
+			value, _ := strconv.Atoi(fields[i+2])
+
+			if fields[i+1] == "mate" {
+				analysis.Score = Score{Mate: value}
+			} else {
+				analysis.Score = Score{Centipawns: value}
+			}
+		case "pv":
+			analysis.Pv = append([]string{}, fields[i+1:]...)
+			return
+		}
+	}
+}
+
+func blackToMove(fen string, movesPlayed int) bool {
+	blackStarts := false
+
+	if fields := strings.Fields(fen); len(fields) > 1 {
+		blackStarts = fields[1] == "b"
+	}
+
+	return blackStarts != (movesPlayed%2 == 1)
+}
+
+func newEngineUnavailableError() error {
+	return sv.NewGenericError("No chess engine is available", 503, sv.NOT_SENSITIVE)
+}
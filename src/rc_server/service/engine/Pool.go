@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// A fixed number of engine processes shared by every request. Engines are
+// started the first time they are needed and replaced if they misbehave.
+type Pool struct {
+	path  string
+	args  []string
+	slots chan *Engine // A nil entry is a slot whose engine has not been started yet
+}
+
+// Nothing is started until an analysis is requested, so the server runs fine without an engine installed
+func NewPool(path string, workers int, args ...string) *Pool {
+	p := &Pool{path: path, args: args, slots: make(chan *Engine, workers)}
+
+	for i := 0; i < workers; i++ {
+		p.slots <- nil
+	}
+
+	return p
+}
+
+// Analyse a position, waiting for a free engine if they are all busy
+func (p *Pool) Analyse(ctx context.Context, fen string, moves []string, limits Limits) (*Analysis, error) {
+	if cap(p.slots) == 0 {
+		return nil, newEngineUnavailableError()
+	}
+
+	var e *Engine
+
+	select {
+	case e = <-p.slots:
+	case <-ctx.Done():
+		return nil, sv.NewGenericError("Gave up waiting for a chess engine", 503, sv.NOT_SENSITIVE)
+	}
+
+	if e == nil {
+		var err error
+
+		if e, err = StartEngine(p.path, p.args...); err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+	}
+
+	analysis, err := e.Analyse(fen, moves, limits)
+
+	if err != nil {
+		// Its state is unknown after a failure, start a fresh one next time
+		e.Close()
+		p.slots <- nil
+		return nil, err
+	}
+
+	p.slots <- e
+
+	return analysis, nil
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// The stand-in engine from src/stubengine, built once for every test
+var stubPath string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "stubengine")
+
+	if err != nil {
+		panic(err)
+	}
+
+	stubPath = filepath.Join(dir, "stubengine")
+
+	if out, err := exec.Command("go", "build", "-o", stubPath, "remotechess/src/stubengine").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		panic("building the stub engine: " + err.Error() + "\n" + string(out))
+	}
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func expectStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	serviceError, ok := err.(*sv.ServiceError)
+
+	if !ok || serviceError.HttpCodeHint != status {
+		t.Fatalf("got error %v, want a %d", err, status)
+	}
+}
+
+func TestPoolAnalyse(t *testing.T) {
+	p := NewPool(stubPath, 1)
+	limits := Limits{MoveTime: 100 * time.Millisecond}
+
+	// The same engine is handed out again for the second search
+	for _, moves := range [][]string{nil, {"e2e4", "e7e5"}} {
+		analysis, err := p.Analyse(context.Background(), "", moves, limits)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if analysis.BestMove == "" || len(analysis.Pv) == 0 || analysis.Pv[0] != analysis.BestMove || analysis.Depth != 1 {
+			t.Fatalf("after %v: %+v", moves, analysis)
+		}
+	}
+}
+
+func TestPoolAnalyseFromFen(t *testing.T) {
+	p := NewPool(stubPath, 1)
+
+	// Black is mated, so there is nothing to play
+	fen := "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"
+	analysis, err := p.Analyse(context.Background(), fen, nil, Limits{Depth: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if analysis.BestMove != "" {
+		t.Fatalf("best move %q in a mated position", analysis.BestMove)
+	}
+}
+
+func TestPoolWithoutWorkers(t *testing.T) {
+	_, err := NewPool(stubPath, 0).Analyse(context.Background(), "", nil, Limits{Depth: 1})
+	expectStatus(t, err, 503)
+}
+
+func TestPoolMissingEngine(t *testing.T) {
+	p := NewPool(filepath.Join(t.TempDir(), "missing"), 1)
+
+	// The slot is given back after a failed start, so the second call fails the same way rather than waiting
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := p.Analyse(ctx, "", nil, Limits{Depth: 1})
+		cancel()
+
+		expectStatus(t, err, 503)
+	}
+}
+
+func TestPoolGivesUpWaiting(t *testing.T) {
+	p := NewPool(stubPath, 1)
+
+	// Every engine is busy
+	<-p.slots
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := p.Analyse(ctx, "", nil, Limits{Depth: 1})
+	expectStatus(t, err, 503)
+}
+
+func TestParseInfo(t *testing.T) {
+	var analysis Analysis
+
+	parseInfo("info depth 12 seldepth 18 score cp 34 nodes 1000 pv e2e4 e7e5 g1f3", &analysis)
+
+	if analysis.Depth != 12 || analysis.Score != (Score{Centipawns: 34}) || len(analysis.Pv) != 3 {
+		t.Fatalf("%+v", analysis)
+	}
+
+	// Neither replaces the line above
+	parseInfo("info depth 13 score cp 80 lowerbound pv d2d4", &analysis)
+	parseInfo("info depth 13 multipv 2 score cp 10 pv c2c4", &analysis)
+
+	if analysis.Depth != 12 || analysis.Pv[0] != "e2e4" {
+		t.Fatalf("%+v", analysis)
+	}
+
+	parseInfo("info depth 14 score mate -3 pv f2f3", &analysis)
+
+	if analysis.Score != (Score{Mate: -3}) {
+		t.Fatalf("%+v", analysis)
+	}
+}
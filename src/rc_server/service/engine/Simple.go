@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"math/rand"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// The deepest the built-in engine searches, it has no pruning beyond alpha-beta and gets slow quickly
+const MAX_SIMPLE_DEPTH = 3
+
+const mateScore = 100000
+
+var pieceValues = map[chess.PieceType]int{
+	chess.Pawn:   100,
+	chess.Knight: 300,
+	chess.Bishop: 320,
+	chess.Rook:   500,
+	chess.Queen:  900,
+}
+
+// Search a position with the built-in engine, which only counts material. Up to noise centipawns
+// are added at random to each candidate move so weak settings do not always play the same game.
+// Takes the same position arguments as Engine.Analyse and needs no engine process.
+func SimpleAnalyse(fen string, moves []string, depth int, noise int) (*Analysis, error) {
+	if depth < 1 || depth > MAX_SIMPLE_DEPTH {
+		return nil, sv.NewInvalidInputError("Depth")
+	}
+
+	game := chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+
+	if fen != "" {
+		fenOption, err := chess.FEN(fen)
+
+		if err != nil {
+			return nil, sv.NewInvalidInputError("FEN")
+		}
+
+		game = chess.NewGame(chess.UseNotation(chess.UCINotation{}), fenOption)
+	}
+
+	for _, m := range moves {
+		if _, err := game.MoveStr(m); err != nil {
+			return nil, sv.NewInvalidInputError("Move " + m)
+		}
+	}
+
+	pos := game.Position()
+	analysis := Analysis{Depth: depth}
+	best := -mateScore * 2
+
+	for _, m := range pos.ValidMoves() {
+		score := -negamax(pos.Update(m), depth-1, -mateScore*2, mateScore*2)
+
+		if noise > 0 {
+			score += rand.Intn(noise + 1)
+		}
+
+		if score > best {
+			best = score
+			analysis.BestMove = chess.UCINotation{}.Encode(pos, m)
+		}
+	}
+
+	if analysis.BestMove == "" {
+		analysis.Score.Centipawns = evaluate(pos)
+	} else {
+		analysis.Score.Centipawns = best
+		analysis.Pv = []string{analysis.BestMove}
+	}
+
+	// Scores are from the side to move's point of view until here, like an engine's
+	if pos.Turn() == chess.Black {
+		analysis.Score.Centipawns = -analysis.Score.Centipawns
+	}
+
+	return &analysis, nil
+}
+
+// The best score the side to move can force within depth plies
+func negamax(pos *chess.Position, depth int, alpha int, beta int) int {
+	moves := pos.ValidMoves()
+
+	if len(moves) == 0 || depth == 0 {
+		return evaluate(pos)
+	}
+
+	for _, m := range moves {
+		score := -negamax(pos.Update(m), depth-1, -beta, -alpha)
+
+		if score >= beta {
+			return beta
+		}
+
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	return alpha
+}
+
+// Material balance from the side to move's point of view
+func evaluate(pos *chess.Position) int {
+	switch pos.Status() {
+	case chess.Checkmate:
+		return -mateScore
+	case chess.Stalemate:
+		return 0
+	}
+
+	score := 0
+
+	for _, p := range pos.Board().SquareMap() {
+		if p.Color() == pos.Turn() {
+			score += pieceValues[p.Type()]
+		} else {
+			score -= pieceValues[p.Type()]
+		}
+	}
+
+	return score
+}
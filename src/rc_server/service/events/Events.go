@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+type EventKind string
+
+const (
+	MOVE_EVENT          EventKind = "MOVE"
+	DRAW_OFFERED_EVENT  EventKind = "DRAW_OFFERED"
+	DRAW_ACCEPTED_EVENT EventKind = "DRAW_ACCEPTED"
+	DRAW_REJECTED_EVENT EventKind = "DRAW_REJECTED"
+	RESIGNATION_EVENT   EventKind = "RESIGNATION"
+	GAME_OVER_EVENT     EventKind = "GAME_OVER"
+	GAME_STARTED_EVENT  EventKind = "GAME_STARTED"
+	CHAT_EVENT          EventKind = "CHAT"
+
+	TAKEBACK_REQUESTED_EVENT EventKind = "TAKEBACK_REQUESTED"
+	TAKEBACK_ACCEPTED_EVENT  EventKind = "TAKEBACK_ACCEPTED"
+	TAKEBACK_DECLINED_EVENT  EventKind = "TAKEBACK_DECLINED"
+
+	MOVE_MIRRORED_EVENT EventKind = "MOVE_MIRRORED" // The board of the player to move has reproduced the opponent's move
+
+	DEADLINE_REMINDER_EVENT EventKind = "DEADLINE_REMINDER" // Sent only to the board that has to move in a correspondence game
+)
+
+// Payload of an event. Only the fields relevant to the event kind are filled in.
+type EventData struct {
+	Player     string      `json:"player,omitempty"`
+	Move       string      `json:"move,omitempty"`
+	DrawMethod string      `json:"drawMethod,omitempty"`
+	Outcome    string      `json:"outcome,omitempty"`
+	Method     string      `json:"method,omitempty"`
+	Fen        string      `json:"fen,omitempty"`
+	Clock      *EventClock `json:"clock,omitempty"` // Time left after the event, for timed games
+	ChatId     uint64      `json:"chatId,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	PresetId   int         `json:"presetId,omitempty"`
+	Plies      int         `json:"plies,omitempty"` // Moves to take back
+	Reminder   string      `json:"reminder,omitempty"`
+	Deadline   int64       `json:"deadline,omitempty"` // Unix milliseconds
+	Version    int64       `json:"version,omitempty"`  // Of the game after a move or takeback
+}
+
+type EventClock struct {
+	WhiteMs int64 `json:"whiteMs"`
+	BlackMs int64 `json:"blackMs"`
+}
+
+// A single entry in a game's event log. Seq starts at 1 and increases by one for every event
+// in the same game, so a client that remembers the last Seq it saw can ask for what it missed.
+type Event struct {
+	GameId    uint64
+	Seq       uint64
+	Kind      EventKind
+	Data      EventData
+	CreatedAt time.Time
+}
+
+// Persist an event to the game's log and deliver it to everyone subscribed to the game
+// or to one of the boards playing it
+func (s *EventService) Publish(gameId uint64, boards []uint64, kind EventKind, data EventData) (*Event, error) {
+	ev := Event{GameId: gameId, Kind: kind, Data: data}
+
+	if err := s.events.Append(context.Background(), &ev); err != nil {
+		return nil, err
+	}
+
+	s.deliver(ev, boards)
+
+	return &ev, nil
+}
+
+// Return every event of a game with a sequence number greater than since, oldest first
+func (s *EventService) FetchEventsSince(gameId uint64, since uint64) ([]Event, error) {
+	return s.events.ListSince(context.Background(), gameId, since)
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// How many undelivered events a subscriber may fall behind by before it is dropped.
+// A dropped subscriber is expected to reconnect and catch up with FetchEventsSince.
+const subscriptionBuffer = 64
+
+type Subscription struct {
+	// Closed when the subscription is closed or was dropped for falling behind
+	Events chan Event
+	topic  string
+	closed bool
+	hub    *hub
+}
+
+// Subscriptions by topic
+type hub struct {
+	sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func gameTopic(gameId uint64) string {
+	return "game:" + fmt.Sprint(gameId)
+}
+
+func boardTopic(onboardId uint64) string {
+	return "board:" + fmt.Sprint(onboardId)
+}
+
+// Receive every event published for the game from now on
+func (s *EventService) SubscribeGame(gameId uint64) *Subscription {
+	return s.hub.subscribe(gameTopic(gameId))
+}
+
+// Receive every event published for any game the board plays in from now on
+func (s *EventService) SubscribeBoard(onboardId uint64) *Subscription {
+	return s.hub.subscribe(boardTopic(onboardId))
+}
+
+func (h *hub) subscribe(topic string) *Subscription {
+	sub := &Subscription{Events: make(chan Event, subscriptionBuffer), topic: topic, hub: h}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[*Subscription]struct{}{}
+	}
+
+	h.topics[topic][sub] = struct{}{}
+
+	return sub
+}
+
+func (sub *Subscription) Close() {
+	sub.hub.Lock()
+	defer sub.hub.Unlock()
+
+	sub.closeLocked()
+}
+
+func (sub *Subscription) closeLocked() {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.Events)
+
+	delete(sub.hub.topics[sub.topic], sub)
+
+	if len(sub.hub.topics[sub.topic]) == 0 {
+		delete(sub.hub.topics, sub.topic)
+	}
+}
+
+func (s *EventService) deliver(ev Event, boards []uint64) {
+	topics := []string{gameTopic(ev.GameId)}
+
+	for _, b := range boards {
+		topics = append(topics, boardTopic(b))
+	}
+
+	s.hub.deliver(ev, topics)
+}
+
+// Deliver an event to the given boards only. It is not part of the game's log,
+// so it has no sequence number and is not seen by anyone watching the game.
+func (s *EventService) Notify(gameId uint64, boards []uint64, kind EventKind, data EventData) {
+	ev := Event{GameId: gameId, Kind: kind, Data: data, CreatedAt: time.Now()}
+	topics := []string{}
+
+	for _, b := range boards {
+		topics = append(topics, boardTopic(b))
+	}
+
+	s.hub.deliver(ev, topics)
+}
+
+func (h *hub) deliver(ev Event, topics []string) {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, topic := range topics {
+		for sub := range h.topics[topic] {
+			select {
+			case sub.Events <- ev:
+			default:
+				// Never block publishers on a slow reader
+				sub.closeLocked()
+			}
+		}
+	}
+}
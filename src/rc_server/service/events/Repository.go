@@ -0,0 +1,21 @@
+package events
+
+import (
+	"context"
+)
+
+// Where the event logs of games are stored
+type EventRepository interface {
+	Append(ctx context.Context, ev *Event) error // Sets the event's Seq and CreatedAt
+	ListSince(ctx context.Context, gameId uint64, since uint64) ([]Event, error)
+}
+
+// Logs game events and delivers them to the subscribers of this server
+type EventService struct {
+	events EventRepository
+	hub    *hub
+}
+
+func NewEventService(events EventRepository) *EventService {
+	return &EventService{events: events, hub: &hub{topics: map[string]map[*Subscription]struct{}{}}}
+}
@@ -0,0 +1,410 @@
+package games
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/engine"
+)
+
+type MoveClassification int
+
+const (
+	GOOD MoveClassification = iota
+	INACCURACY
+	MISTAKE
+	BLUNDER
+)
+
+const (
+	// Evaluations are capped here before comparing them, so that a won position staying won is not
+	// counted as a loss. A mate counts as the cap.
+	EVAL_CAP = 1000
+
+	INACCURACY_LOSS = 50
+	MISTAKE_LOSS    = 100
+	BLUNDER_LOSS    = 300
+
+	analysisWorkers = 4 // Positions of one game searched at once
+	maxAnalysisJobs = 8 // Games analysed at once
+	analysisTimeout = 10 * time.Minute
+)
+
+var (
+	classificationToStr = map[MoveClassification]string{
+		GOOD:       "GOOD",
+		INACCURACY: "INACCURACY",
+		MISTAKE:    "MISTAKE",
+		BLUNDER:    "BLUNDER",
+	}
+
+	strToClassification = inverseMap(classificationToStr).(map[string]MoveClassification)
+)
+
+// An analysis running in the background. One that failed is kept until its error has been reported.
+type analysisJob struct {
+	err error
+}
+
+// The analyses running in the background, see RequestAnalysis
+type analysisJobSet struct {
+	sync.Mutex
+	jobs    map[uint64]*analysisJob // By game
+	running int
+}
+
+// How one move of a finished game compares to what the engine would have played
+type MoveAnalysis struct {
+	Ply            int // 0 is White's first move
+	Move           string
+	Player         PlayerColor
+	Score          engine.Score // The position after the move, from White's point of view
+	BestMove       string       // What the engine preferred in the position before the move
+	Loss           int          // Centipawns the move gave away compared to BestMove
+	Classification MoveClassification
+}
+
+type AnalysisSummary struct {
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+	AverageLoss  int
+}
+
+type GameAnalysis struct {
+	Moves        []MoveAnalysis
+	White, Black AnalysisSummary
+}
+
+func (c MoveClassification) String() string {
+	return classificationToStr[c]
+}
+
+func (this *MoveClassification) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToClassification[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid MoveClassification enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this MoveClassification) Value() (driver.Value, error) {
+	if val, ok := classificationToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown MoveClassification")
+	}
+}
+
+// The classification of a move that may not have been analysed yet
+type NullableMoveClassification struct {
+	MoveClassification
+	Valid bool
+}
+
+func (this *NullableMoveClassification) Scan(value interface{}) error {
+	if value == nil {
+		this.Valid = false
+		return nil
+	}
+
+	this.Valid = true
+	return this.MoveClassification.Scan(value)
+}
+
+func classifyLoss(loss int) MoveClassification {
+	switch {
+	case loss >= BLUNDER_LOSS:
+		return BLUNDER
+	case loss >= MISTAKE_LOSS:
+		return MISTAKE
+	case loss >= INACCURACY_LOSS:
+		return INACCURACY
+	default:
+		return GOOD
+	}
+}
+
+// The score in centipawns for White, with mates and large advantages capped at EVAL_CAP
+func cappedEval(score engine.Score) int {
+	if score.Mate > 0 {
+		return EVAL_CAP
+	} else if score.Mate < 0 {
+		return -EVAL_CAP
+	}
+
+	if score.Centipawns > EVAL_CAP {
+		return EVAL_CAP
+	} else if score.Centipawns < -EVAL_CAP {
+		return -EVAL_CAP
+	}
+
+	return score.Centipawns
+}
+
+// The variants whose rules a standard UCI engine knows
+func (cg *ChessGame) engineSupportsVariant() error {
+	if cg.Variant == CHESS960 || cg.Variant == KING_OF_THE_HILL_VARIANT || cg.Variant == THREE_CHECK_VARIANT {
+		return sv.NewGenericError("The engine does not support "+variantToPgn[cg.Variant], 400, sv.NOT_SENSITIVE)
+	}
+
+	return nil
+}
+
+// The game's moves in UCI notation
+func (cg *ChessGame) uciMoves() []string {
+	positions := cg.Positions()
+	moves := []string{}
+
+	for i, m := range cg.Moves() {
+		moves = append(moves, chess.UCINotation{}.Encode(positions[i], m))
+	}
+
+	return moves
+}
+
+// Ask the engine for the best move and evaluation of the current position.
+// Players may not consult the engine during rated games.
+func (cg *ChessGame) Evaluate(ctx context.Context, limits engine.Limits) (*engine.Analysis, error) {
+	if cg.GetOutcome() != NO_OUTCOME {
+		return nil, sv.NewGenericError("Game is already over", 409, sv.NOT_SENSITIVE)
+	}
+
+	if cg.Rated {
+		return nil, sv.NewGenericError("Engine help is not allowed in rated games", 403, sv.NOT_SENSITIVE)
+	}
+
+	if err := cg.engineSupportsVariant(); err != nil {
+		return nil, err
+	}
+
+	return cg.svc.engine.Analyse(ctx, cg.StartFen, cg.uciMoves(), limits)
+}
+
+// The stored analysis of a finished game. If there is none yet the game is analysed in the background, once
+// however often it is asked for, and nil is returned until the analysis is stored. A failed analysis is
+// reported to the next call, and the one after that starts it again.
+func (cg *ChessGame) RequestAnalysis(limits engine.Limits) (*GameAnalysis, error) {
+	if cg.GetOutcome() == NO_OUTCOME {
+		return nil, sv.NewGenericError("Only finished games can be analysed", 409, sv.NOT_SENSITIVE)
+	}
+
+	if err := cg.engineSupportsVariant(); err != nil {
+		return nil, err
+	}
+
+	cg.svc.analysisJobs.Lock()
+	defer cg.svc.analysisJobs.Unlock()
+
+	if job, ok := cg.svc.analysisJobs.jobs[cg.Id]; ok {
+		if job.err != nil {
+			delete(cg.svc.analysisJobs.jobs, cg.Id)
+		}
+
+		return nil, job.err
+	}
+
+	analysis, err := cg.FetchAnalysis()
+
+	if err != nil || analysis != nil {
+		return analysis, err
+	}
+
+	if cg.svc.analysisJobs.running >= maxAnalysisJobs {
+		return nil, sv.NewGenericError("Too many games are being analysed, try again later", 503, sv.NOT_SENSITIVE)
+	}
+
+	job := &analysisJob{}
+	cg.svc.analysisJobs.jobs[cg.Id] = job
+	cg.svc.analysisJobs.running++
+
+	go cg.runAnalysis(job, limits)
+
+	return nil, nil
+}
+
+func (cg *ChessGame) runAnalysis(job *analysisJob, limits engine.Limits) {
+	ctx, cancel := context.WithTimeout(context.Background(), analysisTimeout)
+	defer cancel()
+
+	err := cg.analyseGame(ctx, limits)
+
+	cg.svc.analysisJobs.Lock()
+	defer cg.svc.analysisJobs.Unlock()
+
+	cg.svc.analysisJobs.running--
+
+	if err != nil {
+		cg.svc.log.Error("analysing game " + fmt.Sprint(cg.Id) + ": " + err.Error())
+		job.err = err
+		return
+	}
+
+	delete(cg.svc.analysisJobs.jobs, cg.Id)
+}
+
+// Evaluate every position of the game, classify each move by how much it gave away and store the result.
+// Up to analysisWorkers positions are searched at once, on as many engines as the pool has free.
+func (cg *ChessGame) analyseGame(ctx context.Context, limits engine.Limits) error {
+	moves := cg.uciMoves()
+	positions := cg.Positions()
+	evals := make([]*engine.Analysis, len(positions))
+
+	searches := make(chan int, len(positions))
+
+	for i, pos := range positions {
+		// The engine has nothing to search once the game is decided on the board
+		switch pos.Status() {
+		case chess.Checkmate:
+			score := engine.Score{Centipawns: EVAL_CAP}
+
+			if pos.Turn() == chess.White {
+				score.Centipawns = -EVAL_CAP
+			}
+
+			evals[i] = &engine.Analysis{Score: score}
+			continue
+		case chess.Stalemate:
+			evals[i] = &engine.Analysis{}
+			continue
+		}
+
+		searches <- i
+	}
+
+	close(searches)
+
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var firstErr error
+
+	for w := 0; w < analysisWorkers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range searches {
+				analysis, err := cg.svc.engine.Analyse(ctx, cg.StartFen, moves[:i], limits)
+
+				errLock.Lock()
+
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+
+				failed := firstErr != nil
+				evals[i] = analysis
+				errLock.Unlock()
+
+				if failed {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	analysis := make([]MoveAnalysis, len(moves))
+
+	for ply, move := range moves {
+		before, after := cappedEval(evals[ply].Score), cappedEval(evals[ply+1].Score)
+		player := PLAYER_WHITE
+		loss := before - after
+
+		if positions[ply].Turn() == chess.Black {
+			player = PLAYER_BLACK
+			loss = after - before
+		}
+
+		// Searches of neighbouring positions disagree a little even when the best move was played
+		if loss < 0 || move == evals[ply].BestMove {
+			loss = 0
+		}
+
+		analysis[ply] = MoveAnalysis{
+			Ply:            ply,
+			Move:           move,
+			Player:         player,
+			Score:          evals[ply+1].Score,
+			BestMove:       evals[ply].BestMove,
+			Loss:           loss,
+			Classification: classifyLoss(loss),
+		}
+	}
+
+	return cg.saveAnalysis(ctx, analysis)
+}
+
+func (cg *ChessGame) saveAnalysis(ctx context.Context, analysis []MoveAnalysis) error {
+	return sv.WithinTx(ctx, cg.svc.tx, func(ctx context.Context) error {
+		return cg.svc.moves.SaveAnalysis(ctx, cg.Id, analysis)
+	})
+}
+
+// The stored analysis of the game, or nil if it has not been analysed yet
+func (cg *ChessGame) FetchAnalysis() (*GameAnalysis, error) {
+	analysis, err := cg.svc.moves.FetchAnalysis(context.Background(), cg.Id)
+
+	if err != nil || len(analysis) == 0 {
+		return nil, err
+	}
+
+	return summariseAnalysis(analysis), nil
+}
+
+func summariseAnalysis(moves []MoveAnalysis) *GameAnalysis {
+	ga := GameAnalysis{Moves: moves}
+	totalLoss := map[PlayerColor]int{}
+	moveCount := map[PlayerColor]int{}
+
+	for _, m := range moves {
+		summary := &ga.White
+
+		if m.Player == PLAYER_BLACK {
+			summary = &ga.Black
+		}
+
+		switch m.Classification {
+		case INACCURACY:
+			summary.Inaccuracies++
+		case MISTAKE:
+			summary.Mistakes++
+		case BLUNDER:
+			summary.Blunders++
+		}
+
+		totalLoss[m.Player] += m.Loss
+		moveCount[m.Player]++
+	}
+
+	if moveCount[PLAYER_WHITE] > 0 {
+		ga.White.AverageLoss = totalLoss[PLAYER_WHITE] / moveCount[PLAYER_WHITE]
+	}
+
+	if moveCount[PLAYER_BLACK] > 0 {
+		ga.Black.AverageLoss = totalLoss[PLAYER_BLACK] / moveCount[PLAYER_BLACK]
+	}
+
+	return &ga
+}
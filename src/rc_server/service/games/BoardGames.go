@@ -0,0 +1,69 @@
+package games
+
+import (
+	"context"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+// How many unfinished games a board may play at once. Live games need the physical board
+// in front of the player, correspondence games only need it now and then.
+const (
+	MAX_LIVE_GAMES           = 1
+	MAX_CORRESPONDENCE_GAMES = 50
+)
+
+// Refuse a new game with the given time control if the board already plays as many of that kind as it may.
+// The computer's boards play any number of games. CreateChessGameTx checks both boards after locking them,
+// so no game can start on them between the check and the game it is for.
+func (s *GameService) CheckGameLimitsTx(ctx context.Context, cb Chessboard, tc TimeControl) error {
+	if cb.IsBot() {
+		return nil
+	}
+
+	correspondence, live, err := s.games.CountOngoing(ctx, cb.OnboardId)
+
+	if err != nil {
+		return err
+	}
+
+	if tc.Kind == CORRESPONDENCE && correspondence >= MAX_CORRESPONDENCE_GAMES {
+		return sv.NewGenericError("Board already plays the most correspondence games it can", 409, sv.NOT_SENSITIVE)
+	} else if tc.Kind != CORRESPONDENCE && live >= MAX_LIVE_GAMES {
+		return sv.NewGenericError("Board is already in a game", 409, sv.NOT_SENSITIVE)
+	}
+
+	return nil
+}
+
+// Every unfinished game the board plays in, oldest first
+func (s *GameService) FetchOngoingGames(cb Chessboard) ([]*ChessGame, error) {
+	ids, err := s.games.ListOngoing(context.Background(), cb.OnboardId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	games := []*ChessGame{}
+
+	for _, id := range ids {
+		cg, err := s.FetchChessGame(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// The game may have ended since it was listed
+		if cg.GetOutcome() == NO_OUTCOME {
+			games = append(games, cg)
+		}
+	}
+
+	return games, nil
+}
+
+// How many more of its recent games the board played as White than as Black
+func (s *GameService) RecentColorBalance(onboardId uint64) (int, error) {
+	return s.games.ColorBalance(context.Background(), onboardId)
+}
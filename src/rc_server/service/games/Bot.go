@@ -0,0 +1,218 @@
+package games
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/engine"
+	. "remotechess/src/rc_server/service/events"
+)
+
+// How the computer plays at one strength level. The weakest levels use the built-in engine,
+// the rest the UCI engine pool, falling back to the built-in engine if none is available.
+type BotLevel struct {
+	Level       int
+	Description string
+	simpleDepth int
+	simpleNoise int
+	limits      engine.Limits // Zero for levels that only use the built-in engine
+}
+
+// The computer takes at least this long to reply, so a move does not appear before the board has announced the one before it
+const BOT_MIN_THINK_TIME = 500 * time.Millisecond
+
+// How often games waiting for the computer are looked for, to pick up replies lost to a restart or an error
+const botSweepInterval = 30 * time.Second
+
+var BotLevels = []BotLevel{
+	{1, "Beginner", 1, 400, engine.Limits{}},
+	{2, "Novice", 2, 150, engine.Limits{}},
+	{3, "Casual", 3, 50, engine.Limits{}},
+	{4, "Club", 3, 0, engine.Limits{Depth: 4, MoveTime: 100 * time.Millisecond}},
+	{5, "Strong club", 3, 0, engine.Limits{Depth: 8, MoveTime: 250 * time.Millisecond}},
+	{6, "Expert", 3, 0, engine.Limits{Depth: 12, MoveTime: 500 * time.Millisecond}},
+	{7, "Master", 3, 0, engine.Limits{Depth: 18, MoveTime: time.Second}},
+	{8, "Maximum", 3, 0, engine.Limits{MoveTime: 2 * time.Second}},
+}
+
+// The moves of the position the computer is thinking about in each game. A takeback can leave it
+// thinking about a position that is gone, the stale search is dropped when it finishes.
+type botMoveSet struct {
+	sync.Mutex
+	thinking map[uint64]string
+}
+
+func FetchBotLevel(level int) (BotLevel, error) {
+	if level < MIN_BOT_LEVEL || level > MAX_BOT_LEVEL {
+		return BotLevel{}, sv.NewInvalidInputError("Bot level")
+	}
+
+	return BotLevels[level-MIN_BOT_LEVEL], nil
+}
+
+// Start a game between a board and the computer. Games against the computer are never rated, see checkRatable.
+func (s *GameService) CreateBotGame(human *Chessboard, humanColor PlayerColor, level int, settings GameSettings) (*ChessGame, error) {
+	if human.IsBot() {
+		return nil, sv.NewInvalidInputError("Chessboard")
+	}
+
+	if settings.Variant == CHESS960 || settings.Variant == KING_OF_THE_HILL_VARIANT || settings.Variant == THREE_CHECK_VARIANT {
+		return nil, sv.NewGenericError("The computer does not play "+variantToPgn[settings.Variant], 400, sv.NOT_SENSITIVE)
+	}
+
+	bot, err := s.boards.FetchBotBoard(level)
+
+	if err != nil {
+		return nil, err
+	}
+
+	white, black := human, bot
+
+	if humanColor == PLAYER_BLACK {
+		white, black = bot, human
+	}
+
+	return s.CreateChessGame(white, black, settings)
+}
+
+// Have the computer reply in the background if it is its turn
+func (cg *ChessGame) scheduleBotMove() {
+	mover := cg.GetCurrentMover()
+
+	if cg.Archived || cg.GetOutcome() != NO_OUTCOME || !mover.IsBot() {
+		return
+	}
+
+	cg.svc.botMoves.Lock()
+	defer cg.svc.botMoves.Unlock()
+
+	id, level := cg.Id, int(mover.BotLevel.Int64)
+	fen, moves := cg.StartFen, cg.uciMoves()
+	position := strings.Join(moves, " ")
+
+	if thinking, ok := cg.svc.botMoves.thinking[id]; ok && thinking == position {
+		return
+	}
+
+	cg.svc.botMoves.thinking[id] = position
+
+	go func() {
+		defer func() {
+			cg.svc.botMoves.Lock()
+
+			if cg.svc.botMoves.thinking[id] == position {
+				delete(cg.svc.botMoves.thinking, id)
+			}
+
+			cg.svc.botMoves.Unlock()
+		}()
+
+		if err := cg.svc.playBotMove(id, level, fen, moves); err != nil {
+			cg.svc.log.Error("bot move in game " + fmt.Sprint(id) + ": " + err.Error())
+		}
+	}()
+}
+
+// Periodically have the computer reply in every game waiting for it. Replies only live in memory while the
+// computer thinks, so this starts the ones a restart lost and retries the ones that failed.
+func (s *GameService) StartBotScheduler() {
+	go func() {
+		ticker := time.NewTicker(botSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.scheduleBotMoves(); err != nil {
+				s.log.Error("bot moves: " + err.Error())
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+// Have the computer reply in every game waiting for it, picking up replies lost to a restart or an error
+func (s *GameService) scheduleBotMoves() error {
+	ids, err := s.games.ListAwaitingBot(context.Background())
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		cg, err := s.FetchChessGame(id)
+
+		if err != nil {
+			s.log.Error("bot move in game " + fmt.Sprint(id) + ": " + err.Error())
+			continue
+		}
+
+		cg.scheduleBotMove()
+	}
+
+	return nil
+}
+
+func (s *GameService) playBotMove(gameId uint64, level int, fen string, moves []string) error {
+	started := time.Now()
+
+	bl, err := FetchBotLevel(level)
+
+	if err != nil {
+		return err
+	}
+
+	analysis, err := s.botSearch(bl, fen, moves)
+
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(time.Until(started.Add(BOT_MIN_THINK_TIME)))
+
+	// The game may have moved on while the computer was thinking, e.g. by a resignation
+	cg, err := s.FetchChessGame(gameId)
+
+	if err != nil {
+		return err
+	}
+
+	bot := cg.GetCurrentMover()
+
+	if cg.GetOutcome() != NO_OUTCOME || strings.Join(cg.uciMoves(), " ") != strings.Join(moves, " ") || !bot.IsBot() {
+		return nil
+	}
+
+	if _, err = cg.PlayMove(*bot, analysis.BestMove, MoveGuard{}); err != nil {
+		return err
+	}
+
+	player, _ := cg.GetColorOfBoard(*bot)
+
+	return cg.PublishEvent(MOVE_EVENT, EventData{Player: player.String(), Move: analysis.BestMove})
+}
+
+// A move for the computer at level bl, from the engine pool if the level uses it
+func (s *GameService) botSearch(bl BotLevel, fen string, moves []string) (*engine.Analysis, error) {
+	if bl.limits != (engine.Limits{}) {
+		ctx, cancel := context.WithTimeout(context.Background(), engine.MAX_MOVE_TIME)
+		defer cancel()
+
+		analysis, err := s.engine.Analyse(ctx, fen, moves, bl.limits)
+
+		if err == nil && analysis.BestMove != "" {
+			return analysis, nil
+		}
+
+		if err != nil {
+			s.log.Warn("bot falling back to the built-in engine: " + err.Error())
+		}
+	}
+
+	return engine.SimpleAnalyse(fen, moves, bl.simpleDepth, bl.simpleNoise)
+}
@@ -0,0 +1,184 @@
+package games
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
+)
+
+const (
+	maxChatMessageLength = 500
+
+	// A player may send at most chatBurst messages in any chatWindow
+	chatBurst  = 5
+	chatWindow = 10 * time.Second
+)
+
+// Quick messages boards with only buttons can send by ID
+var ChatPresets = []ChatPreset{
+	{1, "Hello!"},
+	{2, "Good luck!"},
+	{3, "Have fun!"},
+	{4, "Thinking…"},
+	{5, "Nice move!"},
+	{6, "Oops"},
+	{7, "Good game!"},
+	{8, "Well played!"},
+	{9, "Thanks!"},
+	{10, "Rematch?"},
+}
+
+type ChatPreset struct {
+	Id   int
+	Text string
+}
+
+type ChatMessage struct {
+	Id        uint64
+	Player    PlayerColor
+	PresetId  sql.NullInt64 // Set for preset messages
+	Body      string
+	CreatedAt time.Time
+}
+
+type chatSender struct {
+	gameId uint64
+	player PlayerColor
+}
+
+// When each sender's recent messages were sent, see allowChat
+type chatAllowances struct {
+	sync.Mutex
+	sent map[chatSender][]time.Time // Oldest first, never empty
+}
+
+// Send a free-text message to the opponent
+func (cg *ChessGame) SendChat(sender Chessboard, body string) (*ChatMessage, error) {
+	body = strings.TrimSpace(body)
+
+	if body == "" || utf8.RuneCountInString(body) > maxChatMessageLength {
+		return nil, sv.NewGenericError("Messages must be 1-500 characters", 400, sv.NOT_SENSITIVE)
+	}
+
+	return cg.sendChat(sender, sql.NullInt64{}, body)
+}
+
+// Send one of the ChatPresets to the opponent
+func (cg *ChessGame) SendChatPreset(sender Chessboard, presetId int) (*ChatMessage, error) {
+	for _, preset := range ChatPresets {
+		if preset.Id == presetId {
+			return cg.sendChat(sender, sql.NullInt64{Int64: int64(presetId), Valid: true}, preset.Text)
+		}
+	}
+
+	return nil, sv.NewDoesNotExistError("Chat preset")
+}
+
+func (cg *ChessGame) sendChat(sender Chessboard, presetId sql.NullInt64, body string) (*ChatMessage, error) {
+	if cg.Archived {
+		return nil, newArchivedError()
+	}
+
+	player, err := cg.GetColorOfBoard(sender)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !cg.svc.allowChat(chatSender{cg.Id, player}, time.Now()) {
+		return nil, sv.NewGenericError("Too many messages, slow down", 429, sv.NOT_SENSITIVE)
+	}
+
+	msg := ChatMessage{Player: player, PresetId: presetId, Body: body}
+
+	if err = cg.svc.chat.Create(context.Background(), cg.Id, &msg); err != nil {
+		return nil, err
+	}
+
+	opponent := player.Other()
+	muted, err := cg.isChatMuted(opponent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The sender's own board hears about the message too, so every app attached to it stays in sync
+	boards := []uint64{sender.OnboardId}
+
+	if !muted {
+		boards = append(boards, cg.GetBoardOfPlayer(opponent).OnboardId)
+	}
+
+	cg.svc.events.Notify(cg.Id, boards, CHAT_EVENT, EventData{
+		Player:   player.String(),
+		ChatId:   msg.Id,
+		Message:  msg.Body,
+		PresetId: int(presetId.Int64),
+	})
+
+	return &msg, nil
+}
+
+// The game's chat as the board's player sees it
+func (cg *ChessGame) FetchChat(viewer Chessboard) ([]ChatMessage, error) {
+	player, err := cg.GetColorOfBoard(viewer)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cg.svc.chat.List(context.Background(), cg.Id, player)
+}
+
+// Stop or start receiving the opponent's messages. Messages sent while muted stay hidden after unmuting.
+func (cg *ChessGame) MuteOpponent(muter Chessboard, mute bool) error {
+	player, err := cg.GetColorOfBoard(muter)
+
+	if err != nil {
+		return err
+	}
+
+	return cg.svc.chat.SetMuted(context.Background(), cg.Id, player, mute)
+}
+
+func (cg *ChessGame) isChatMuted(player PlayerColor) (bool, error) {
+	return cg.svc.chat.IsMuted(context.Background(), cg.Id, player)
+}
+
+// Record a message against the sender's allowance, unless they have used it up
+func (s *GameService) allowChat(sender chatSender, now time.Time) bool {
+	s.chatLimiter.Lock()
+	defer s.chatLimiter.Unlock()
+
+	// Forget everyone whose window is empty, so finished games do not stay in the limiter
+	for other, sent := range s.chatLimiter.sent {
+		if now.Sub(sent[len(sent)-1]) >= chatWindow {
+			delete(s.chatLimiter.sent, other)
+		}
+	}
+
+	recent := []time.Time{}
+
+	for _, t := range s.chatLimiter.sent[sender] {
+		if now.Sub(t) < chatWindow {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= chatBurst {
+		s.chatLimiter.sent[sender] = recent
+		return false
+	}
+
+	s.chatLimiter.sent[sender] = append(recent, now)
+
+	return true
+}
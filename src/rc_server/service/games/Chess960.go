@@ -0,0 +1,359 @@
+package games
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// Chess960 games castle with the king and rooks from wherever they start. The chess engine only knows how to
+// castle from the standard squares, so it is given no castling rights in Chess960 games and castling is played here.
+//
+// The start FEN of a Chess960 game names the files of the castling rooks (Shredder-FEN), e.g. HAha, and castling
+// is written the way UCI engines write it in Chess960: as the king moving onto its own rook, e.g. b1a1.
+
+// Pass as the Chess960 position to have one picked at random when the game is created
+const RANDOM_CHESS960_POSITION = -1
+
+// The Chess960 position number of the standard starting position
+const STANDARD_CHESS960_POSITION = 518
+
+// A castling move of a Chess960 game. The king always ends on the g or c file and the rook next to it.
+type chess960Castling struct {
+	Move             *chess.Move // The king moving onto the rook
+	KingFrom, KingTo chess.Square
+	RookFrom, RookTo chess.Square
+}
+
+// The FEN of a Chess960 starting position, numbered 0-959 as in Scharnagl's scheme
+func Chess960Fen(position int) (string, error) {
+	if position < 0 || position > 959 {
+		return "", sv.NewInvalidInputError("Chess960 position")
+	}
+
+	// Where the two knights go among the five squares left after placing the bishops and queen
+	knightPlacements := [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+	var rank [8]byte
+	n := position
+
+	rank[2*(n%4)+1] = 'b'
+	n /= 4
+	rank[2*(n%4)] = 'b'
+	n /= 4
+
+	placeOnEmpty := func(index int, piece byte) int {
+		for file := range rank {
+			if rank[file] != 0 {
+				continue
+			}
+
+			if index == 0 {
+				rank[file] = piece
+				return file
+			}
+
+			index--
+		}
+
+		return -1
+	}
+
+	placeOnEmpty(n%6, 'q')
+	n /= 6
+
+	// Place the second knight first so the first one's index is not shifted
+	placeOnEmpty(knightPlacements[n][1], 'n')
+	placeOnEmpty(knightPlacements[n][0], 'n')
+
+	// The rooks and the king take the three remaining squares, king in the middle
+	queenRook := placeOnEmpty(0, 'r')
+	placeOnEmpty(0, 'k')
+	kingRook := placeOnEmpty(0, 'r')
+
+	castling := string([]byte{byte('A' + kingRook), byte('A' + queenRook), byte('a' + kingRook), byte('a' + queenRook)})
+
+	black := string(rank[:])
+	white := strings.ToUpper(black)
+
+	return black + "/pppppppp/8/8/8/8/PPPPPPPP/" + white + " w " + castling + " - 0 1", nil
+}
+
+func randomChess960Position() (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(960))
+
+	if err != nil {
+		return 0, sv.NewInternalError("randomChess960Position " + err.Error())
+	}
+
+	return int(n.Int64()), nil
+}
+
+// The squares of the rooks a Chess960 FEN gives castling rights to, none for any other FEN
+func chess960Rooks(fen string) []chess.Square {
+	rooks := []chess.Square{}
+	fields := strings.Fields(fen)
+
+	if len(fields) < 3 {
+		return rooks
+	}
+
+	for _, c := range fields[2] {
+		if c >= 'A' && c <= 'H' {
+			rooks = append(rooks, chess.NewSquare(chess.File(c-'A'), chess.Rank1))
+		} else if c >= 'a' && c <= 'h' {
+			rooks = append(rooks, chess.NewSquare(chess.File(c-'a'), chess.Rank8))
+		}
+	}
+
+	return rooks
+}
+
+// The FEN to hand the chess engine, which cannot read or play Chess960 castling rights
+func engineFen(fen string) string {
+	if len(chess960Rooks(fen)) == 0 {
+		return fen
+	}
+
+	fields := strings.Fields(fen)
+	fields[2] = "-"
+
+	return strings.Join(fields, " ")
+}
+
+// The castling field of a Chess960 FEN giving rights to castle with rooks
+func chess960CastlingField(rooks []chess.Square) string {
+	field := ""
+
+	for _, rook := range rooks {
+		if rook.Rank() == chess.Rank1 {
+			field += strings.ToUpper(rook.File().String())
+		} else {
+			field += rook.File().String()
+		}
+	}
+
+	if field == "" {
+		return "-"
+	}
+
+	return field
+}
+
+// The rooks that can still castle: those the start FEN names that have neither moved nor been captured,
+// and whose king has not moved
+func (cg *ChessGame) chess960CastlingRooks() []chess.Square {
+	rooks := chess960Rooks(cg.StartFen)
+
+	if len(rooks) == 0 {
+		return rooks
+	}
+
+	positions := cg.Positions()
+
+	for i, m := range cg.Moves() {
+		mover := positions[i].Board().Piece(m.S1())
+		kept := []chess.Square{}
+
+		for _, rook := range rooks {
+			kingMoved := mover.Type() == chess.King && (mover.Color() == chess.White) == (rook.Rank() == chess.Rank1)
+
+			if rook != m.S1() && rook != m.S2() && !kingMoved {
+				kept = append(kept, rook)
+			}
+		}
+
+		rooks = kept
+	}
+
+	return rooks
+}
+
+// The castling moves the player to move can make
+func (cg *ChessGame) chess960Castlings() []chess960Castling {
+	castlings := []chess960Castling{}
+	rooks := cg.chess960CastlingRooks()
+
+	if len(rooks) == 0 {
+		return castlings
+	}
+
+	pos := cg.Game.Position()
+	board := pos.Board()
+	king := chess.NewPiece(chess.King, pos.Turn())
+
+	for _, rook := range rooks {
+		if board.Piece(rook) != chess.NewPiece(chess.Rook, pos.Turn()) {
+			continue
+		}
+
+		for file := chess.FileA; file <= chess.FileH; file++ {
+			kingFrom := chess.NewSquare(file, rook.Rank())
+
+			if board.Piece(kingFrom) != king {
+				continue
+			}
+
+			// Decoding a king move onto a square it cannot reach does not fail
+			m, _ := chess.UCINotation{}.Decode(pos, kingFrom.String()+rook.String())
+
+			if c, ok := chess960CastlingOf(board, m); ok && c.legal(board) {
+				castlings = append(castlings, c)
+			}
+		}
+	}
+
+	return castlings
+}
+
+// The castling a move of the king onto its own rook stands for
+func chess960CastlingOf(before *chess.Board, m *chess.Move) (chess960Castling, bool) {
+	king, rook := before.Piece(m.S1()), before.Piece(m.S2())
+
+	if king.Type() != chess.King || rook != chess.NewPiece(chess.Rook, king.Color()) || m.S1().Rank() != m.S2().Rank() {
+		return chess960Castling{}, false
+	}
+
+	rank := m.S1().Rank()
+
+	c := chess960Castling{
+		Move:     m,
+		KingFrom: m.S1(),
+		KingTo:   chess.NewSquare(chess.FileG, rank),
+		RookFrom: m.S2(),
+		RookTo:   chess.NewSquare(chess.FileF, rank),
+	}
+
+	if m.S2().File() < m.S1().File() {
+		c.KingTo, c.RookTo = chess.NewSquare(chess.FileC, rank), chess.NewSquare(chess.FileD, rank)
+	}
+
+	return c, true
+}
+
+func (c chess960Castling) side() chess.Side {
+	if c.KingTo.File() == chess.FileC {
+		return chess.QueenSide
+	}
+
+	return chess.KingSide
+}
+
+// The board once the king and rook have castled
+func (c chess960Castling) after(before *chess.Board) *chess.Board {
+	squares := before.SquareMap()
+	king, rook := squares[c.KingFrom], squares[c.RookFrom]
+
+	delete(squares, c.KingFrom)
+	delete(squares, c.RookFrom)
+	squares[c.KingTo], squares[c.RookTo] = king, rook
+
+	return chess.NewBoard(squares)
+}
+
+// Whether the king and rook only cross empty squares and the king does not castle out of, through or into check
+func (c chess960Castling) legal(before *chess.Board) bool {
+	king := before.Piece(c.KingFrom)
+	color := king.Color()
+	rank := c.KingFrom.Rank()
+
+	for _, path := range [][2]chess.Square{{c.KingFrom, c.KingTo}, {c.RookFrom, c.RookTo}} {
+		from, to := path[0].File(), path[1].File()
+
+		if from > to {
+			from, to = to, from
+		}
+
+		for f := from; f <= to; f++ {
+			sq := chess.NewSquare(f, rank)
+
+			if sq != c.KingFrom && sq != c.RookFrom && before.Piece(sq) != chess.NoPiece {
+				return false
+			}
+		}
+	}
+
+	if inCheck(before, color) || inCheck(c.after(before), color) {
+		return false
+	}
+
+	// The squares the king passes over, with the rook it castles with out of the way
+	squares := before.SquareMap()
+	delete(squares, c.KingFrom)
+	delete(squares, c.RookFrom)
+
+	step := sign(int(c.KingTo.File()) - int(c.KingFrom.File()))
+
+	for f := int(c.KingFrom.File()) + step; f != int(c.KingTo.File()); f += step {
+		sq := chess.NewSquare(chess.File(f), rank)
+		squares[sq] = king
+
+		if inCheck(chess.NewBoard(squares), color) {
+			return false
+		}
+
+		delete(squares, sq)
+	}
+
+	return true
+}
+
+// Castle and carry on in a new chess.Game from the position it leads to, as the chess engine cannot play the move.
+// The moves and positions before it are kept, see Moves and Positions.
+func (cg *ChessGame) castle(c chess960Castling) {
+	pos := cg.Game.Position()
+	fields := strings.Fields(cg.Game.FEN())
+	halfMoves, _ := strconv.Atoi(fields[4])
+	fullMoves, _ := strconv.Atoi(fields[5])
+	turn := "b"
+
+	if pos.Turn() == chess.Black {
+		turn = "w"
+		fullMoves++
+	}
+
+	fen, _ := chess.FEN(fmt.Sprintf("%s %s - - %d %d", c.after(pos.Board()), turn, halfMoves+1, fullMoves))
+
+	cg.earlierMoves = append(cg.earlierMoves, cg.Game.Moves()...)
+	cg.earlierMoves = append(cg.earlierMoves, c.Move)
+	cg.earlierPositions = append(cg.earlierPositions, cg.Game.Positions()...)
+	cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}), fen)
+}
+
+// SAN of a castling move, which the chess engine would write as a king move
+func chess960CastlingSan(c chess960Castling, after *chess.Position) string {
+	san := "O-O"
+
+	if c.side() == chess.QueenSide {
+		san = "O-O-O"
+	}
+
+	if after.Status() == chess.Checkmate {
+		return san + "#"
+	} else if inCheck(after.Board(), after.Turn()) {
+		return san + "+"
+	}
+
+	return san
+}
+
+// Which way a move castles, if it does. Chess960 castling moves are not tagged by the chess engine.
+func CastlingSide(before *chess.Position, m *chess.Move) (chess.Side, bool) {
+	if m.HasTag(chess.KingSideCastle) {
+		return chess.KingSide, true
+	} else if m.HasTag(chess.QueenSideCastle) {
+		return chess.QueenSide, true
+	}
+
+	if c, ok := chess960CastlingOf(before.Board(), m); ok {
+		return c.side(), true
+	}
+
+	return chess.KingSide, false
+}
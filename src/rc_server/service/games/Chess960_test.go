@@ -0,0 +1,78 @@
+package games
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestChess960Fen(t *testing.T) {
+	tests := []struct {
+		position int
+		fen      string
+	}{
+		{STANDARD_CHESS960_POSITION, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w CAca - 0 1"},
+		{-1, ""},
+		{960, ""},
+	}
+
+	for _, tt := range tests {
+		fen, err := Chess960Fen(tt.position)
+
+		if fen != tt.fen || (err == nil) != (tt.fen != "") {
+			t.Fatalf("position %d: got %q, %v", tt.position, fen, err)
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	start := "nrkbbqrn/pppppppp/8/8/8/8/PPPPPPPP/NRKBBQRN w GBgb - 0 1"
+	developed := []string{"a1b3", "a8b6", "e2e3", "e7e6", "d2d3", "d7d6", "d1e2", "d8e7", "e1d2", "e8d7"}
+
+	tests := []struct {
+		name   string
+		fen    string
+		moves  []string
+		castle string // Empty when it is not legal
+		after  string
+	}{
+		{"king stays, rook moves", start, developed, "c1b1", "1rk2qrn/pppbbppp/1n1pp3/8/8/1N1PP3/PPPBBPPP/2KR1QRN b gb - 5 6"},
+		{"path blocked", start, developed, "c1g1", ""},
+		{"standard position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1", []string{"e2e4", "e7e5", "g1f3", "g8f6", "f1c4", "f8c5"},
+			"e1h1", "rnbqk2r/pppp1ppp/5n2/2b1p3/2B1P3/5N2/PPPP1PPP/RNBQ1RK1 b ha - 5 4"},
+		{"through check", "1r2kr2/8/8/8/8/8/8/1R2K2R w HBb - 0 1", nil, "e1h1", ""},
+		{"out of check", "1r2k3/8/8/8/4r3/8/8/1R2K2R w HB - 0 1", nil, "e1h1", ""},
+		{"rook moved", "1r2k2r/8/8/8/8/8/8/1R2K2R w HBhb - 0 1", []string{"b1b2", "b8b7", "b2b1", "b7b8"}, "e1b1", ""},
+		{"other side still allowed", "1r2k2r/8/8/8/8/8/8/1R2K2R w HBhb - 0 1", []string{"b1b2", "b8b7", "b2b1", "b7b8"},
+			"e1h1", "1r2k2r/8/8/8/8/8/8/1R3RK1 b h - 5 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := testGame(t, tt.fen, tt.moves...)
+			_, err := cg.applyMove(tt.castle)
+
+			if tt.after == "" {
+				if err == nil {
+					t.Fatalf("castled to %s", cg.GetFEN())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cg.GetFEN() != tt.after {
+				t.Fatalf("got %s, want %s", cg.GetFEN(), tt.after)
+			}
+
+			if side, ok := CastlingSide(cg.Positions()[len(tt.moves)], cg.GetMove(-1)); !ok || (side == chess.KingSide) != (tt.castle[2] > tt.castle[0]) {
+				t.Fatalf("castled to side %v, %t", side, ok)
+			}
+		})
+	}
+}
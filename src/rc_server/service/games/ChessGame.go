@@ -4,13 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/notnil/chess"
 
-	. "remotechess/src/rc_server/rcdb/chessboards"
-	. "remotechess/src/rc_server/rcdb/games"
 	sv "remotechess/src/rc_server/service"
 	. "remotechess/src/rc_server/service/chessboards"
 	. "remotechess/src/rc_server/service/common"
@@ -28,17 +26,112 @@ type ChessGame struct {
 	White, Black   Chessboard
 	OfferedDraw    GameMethod
 	OfferingPlayer PlayerColor
+	Clock          GameClock
+	CreatedAt      time.Time
+	StartFen       string // Empty for games from the standard starting position
+	Variant        GameVariant
+	Rated          bool
+	Visibility     GameVisibility
+	BroadcastDelay time.Duration // How far behind spectators are shown the game
+	Takebacks      bool          // Whether the players may ask each other to take moves back
+	TakebackPlies  int           // How many moves TakebackPlayer asked to take back, 0 when no takeback is pending
+	TakebackPlayer PlayerColor
+	MirroredPly    int   // How many moves the board of the player to move has confirmed reproducing
+	Version        int64 // Goes up with every stored change of position or result
+
+	// Archived games were imported from elsewhere and can no longer be played.
+	// PgnTags holds the tags they were imported with.
+	Archived bool
+	PgnTags  PgnTags
+
+	// Set when the game was ended by the server rather than by the chess engine, e.g. on time
+	adjudicatedOutcome GameOutcome
+	adjudicatedMethod  GameMethod
+
+	// The moves and positions before the last Chess960 castling, which Game starts after, see castle
+	earlierMoves     []*chess.Move
+	earlierPositions []*chess.Position
+
+	svc *GameService // The service the game was fetched or created through, which stores its changes
 }
 
 type ChessGamePersistent struct {
 	Id               uint64
-	FkWhite, FkBlack uint64
+	FkWhite, FkBlack sql.NullInt64
 	Fen              string
 	CurrentMove      PlayerColor
 	Outcome          GameOutcome
 	Method           GameMethod
 	OfferedDraw      GameMethod
 	OfferingPlayer   PlayerColor
+	TcKind           TimeControlKind
+	TcBaseMs         int64
+	TcIncrementMs    int64
+	TcDaysPerMove    int
+	WhiteTimeMs      int64
+	BlackTimeMs      int64
+	TurnStartedAt    time.Time
+	CreatedAt        time.Time
+	Archived         bool
+	PgnTags          PgnTags
+	StartFen         sql.NullString
+	Variant          GameVariant
+	Rated            bool
+	Visibility       GameVisibility
+	BroadcastDelayMs int64
+	Takebacks        bool
+	TakebackPlies    int
+	TakebackPlayer   PlayerColor
+	MirroredPly      int
+	Version          int64
+	Plies            int // How many moves have been played
+}
+
+// The stored form of the game
+func (cg *ChessGame) persistent() ChessGamePersistent {
+	tc := cg.Clock.TimeControl
+
+	cgp := ChessGamePersistent{
+		Id:               cg.Id,
+		Fen:              cg.GetFEN(),
+		CurrentMove:      cg.GetTurn(),
+		Outcome:          cg.GetOutcome(),
+		Method:           cg.GetMethod(),
+		OfferedDraw:      cg.OfferedDraw,
+		OfferingPlayer:   cg.OfferingPlayer,
+		TcKind:           tc.Kind,
+		TcBaseMs:         tc.Base.Milliseconds(),
+		TcIncrementMs:    tc.Increment.Milliseconds(),
+		TcDaysPerMove:    tc.DaysPerMove,
+		WhiteTimeMs:      cg.Clock.WhiteRemaining.Milliseconds(),
+		BlackTimeMs:      cg.Clock.BlackRemaining.Milliseconds(),
+		TurnStartedAt:    cg.Clock.TurnStartedAt,
+		CreatedAt:        cg.CreatedAt,
+		Archived:         cg.Archived,
+		PgnTags:          cg.PgnTags,
+		StartFen:         sql.NullString{String: cg.StartFen, Valid: cg.StartFen != ""},
+		Variant:          cg.Variant,
+		Rated:            cg.Rated,
+		Visibility:       cg.Visibility,
+		BroadcastDelayMs: cg.BroadcastDelay.Milliseconds(),
+		Takebacks:        cg.Takebacks,
+		TakebackPlies:    cg.TakebackPlies,
+		TakebackPlayer:   cg.TakebackPlayer,
+		MirroredPly:      cg.MirroredPly,
+		Version:          cg.Version,
+		Plies:            len(cg.Moves()),
+	}
+
+	// Archived games may have been played against someone without a board here
+	if cg.White.OnboardId != 0 {
+		cgp.FkWhite = sql.NullInt64{Int64: int64(cg.White.OnboardId), Valid: true}
+	}
+
+	if cg.Black.OnboardId != 0 {
+		cgp.FkBlack = sql.NullInt64{Int64: int64(cg.Black.OnboardId), Valid: true}
+	}
+
+	return cgp
 }
 
 func MakeGameOptionsDefault() gameOptions {
@@ -50,39 +143,48 @@ func MakeGameOptionsFetchMoves() gameOptions {
 }
 
 func MakeGameOptionsProvidedMoves(moves []*chess.Move) gameOptions {
-	return gameOptions{FetchMoves: true, ProvidedFen: "", ProvidedMoves: moves}
+	return gameOptions{FetchMoves: false, ProvidedFen: "", ProvidedMoves: moves}
 }
 
-func newChessGame(id uint64, white Chessboard, black Chessboard, outcome GameOutcome, method GameMethod, offeredDraw GameMethod, offeringPlayer PlayerColor, options gameOptions) *ChessGame {
+// Replay the fetched or provided moves from this position instead of the standard starting position
+func (o gameOptions) WithStartFen(fen string) gameOptions {
+	o.ProvidedFen = fen
+	return o
+}
+
+func (s *GameService) newChessGame(id uint64, white Chessboard, black Chessboard, outcome GameOutcome, method GameMethod, offeredDraw GameMethod, offeringPlayer PlayerColor, options gameOptions) *ChessGame {
 	var cg ChessGame
 
+	cg.svc = s
 	cg.Id = id
 	cg.White = white
 	cg.Black = black
 	cg.OfferedDraw = offeredDraw
 	cg.OfferingPlayer = offeringPlayer
+	cg.StartFen = options.ProvidedFen
 
-	if options.FetchMoves {
+	if options.ProvidedFen != "" {
+		fen, _ := chess.FEN(engineFen(options.ProvidedFen))
+		cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}), fen)
+	} else {
 		cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+	}
+
+	if options.FetchMoves {
 		moves, _ := cg.FetchMoves()
 
 		for _, mStr := range moves {
-			cg.Game.MoveStr(mStr)
+			cg.applyMove(mStr)
 		}
-	} else if options.ProvidedFen != "" {
-		fen, _ := chess.FEN(options.ProvidedFen)
-		cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}), fen)
 	} else if options.ProvidedMoves != nil {
-		cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
-
 		for _, m := range options.ProvidedMoves {
-			cg.Game.Move(m)
+			cg.applyMove(m.String())
 		}
-	} else {
-		cg.Game = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
 	}
 
-	if method == RESIGNATION {
+	if method.IsAdjudicated() {
+		cg.Adjudicate(outcome, method)
+	} else if method == RESIGNATION {
 		if outcome == WHITE_WON {
 			cg.Game.Resign(chess.Black)
 		} else if outcome == BLACK_WON {
@@ -134,205 +236,432 @@ func (cg *ChessGame) GetBoardOfPlayer(player PlayerColor) *Chessboard {
 }
 
 func (cg *ChessGame) GetOutcome() GameOutcome {
+	if cg.adjudicatedMethod != NO_METHOD {
+		return cg.adjudicatedOutcome
+	}
+
 	return GameOutcome(cg.Game.Outcome())
 }
 
 func (cg *ChessGame) GetMethod() GameMethod {
+	if cg.adjudicatedMethod != NO_METHOD {
+		return cg.adjudicatedMethod
+	}
+
 	return GameMethod(cg.Game.Method())
 }
 
+// End the game for a reason the chess engine cannot decide by itself
+func (cg *ChessGame) Adjudicate(outcome GameOutcome, method GameMethod) {
+	cg.adjudicatedOutcome = outcome
+	cg.adjudicatedMethod = method
+}
+
+// The FEN of the current position. Chess960 games name the rooks that can still castle, as their start FEN does.
 func (cg *ChessGame) GetFEN() string {
-	return cg.Game.FEN()
+	if len(chess960Rooks(cg.StartFen)) == 0 {
+		return cg.Game.FEN()
+	}
+
+	fields := strings.Fields(cg.Game.FEN())
+	fields[2] = chess960CastlingField(cg.chess960CastlingRooks())
+
+	return strings.Join(fields, " ")
+}
+
+// Every move played so far, in order
+func (cg *ChessGame) Moves() []*chess.Move {
+	return append(append([]*chess.Move{}, cg.earlierMoves...), cg.Game.Moves()...)
+}
+
+// The position before each move, followed by the current one
+func (cg *ChessGame) Positions() []*chess.Position {
+	return append(append([]*chess.Position{}, cg.earlierPositions...), cg.Game.Positions()...)
 }
 
+// The i-th move, counting back from the last one when i is negative. Nil when there is no such move.
 func (cg *ChessGame) GetMove(i int) *chess.Move {
-	return cg.Game.GetMove(i)
+	moves := cg.Moves()
+
+	if i < 0 {
+		i += len(moves)
+	}
+
+	if i < 0 || i >= len(moves) {
+		return nil
+	}
+
+	return moves[i]
 }
 
 func (cg *ChessGame) GetLegalMoves() []*chess.Move {
-	return cg.Game.Position().ValidMoves()
+	moves := cg.Game.Position().ValidMoves()
+
+	for _, c := range cg.chess960Castlings() {
+		moves = append(moves, c.Move)
+	}
+
+	return moves
 }
 
-func CreateChessGame(white *Chessboard, black *Chessboard) (*ChessGame, error) {
-	cg := newChessGame(0, *white, *black, NO_OUTCOME, NO_METHOD, NO_METHOD, PLAYER_WHITE, MakeGameOptionsDefault())
+// Whether the player to move is in check
+func (cg *ChessGame) InCheck() bool {
+	pos := cg.Game.Position()
+	return inCheck(pos.Board(), pos.Turn())
+}
 
-	ctx := context.Background()
-	tx, err := sv.Db.BeginTx(ctx, nil)
+// Play a move given in UCI notation, Chess960 castling included
+func (cg *ChessGame) applyMove(moveUci string) (*chess.Move, error) {
+	for _, c := range cg.chess960Castlings() {
+		if c.Move.String() == moveUci {
+			cg.castle(c)
+			return c.Move, nil
+		}
+	}
 
-	if err != nil {
-		return nil, sv.NewInternalError("CreateChessGame" + err.Error())
+	return cg.Game.MoveStr(moveUci)
+}
+
+func (s *GameService) CreateChessGame(white *Chessboard, black *Chessboard, settings GameSettings) (*ChessGame, error) {
+	var cg *ChessGame
+
+	err := sv.WithinTx(context.Background(), s.tx, func(ctx context.Context) (err error) {
+		cg, err = s.CreateChessGameTx(ctx, white, black, settings)
+		return err
+	})
+
+	return cg, err
+}
+
+// Create a game as part of the unit of work ctx belongs to. The boards are locked until it ends,
+// so nothing else can start a game on them between checking their limits and creating this one.
+func (s *GameService) CreateChessGameTx(ctx context.Context, white *Chessboard, black *Chessboard, settings GameSettings) (*ChessGame, error) {
+	if settings.Rated {
+		if err := checkRatable(white, black, settings); err != nil {
+			return nil, err
+		}
 	}
 
-	defer tx.Rollback()
+	if settings.Variant == CHESS960 {
+		if settings.StartFen != "" {
+			return nil, sv.NewGenericError("Chess960 games start from a numbered position rather than a FEN", 400, sv.NOT_SENSITIVE)
+		}
 
-	row := sv.Db.QueryRowContext(ctx, GetGameQuery(CREATE_GAME), white.OnboardId, black.OnboardId, cg.Game.FEN())
+		position := settings.Chess960Position
+		var err error
+
+		if position == RANDOM_CHESS960_POSITION {
+			if position, err = randomChess960Position(); err != nil {
+				return nil, err
+			}
+		}
 
-	if row.Err() != nil {
-		return nil, sv.NewInternalError("CreateChessGame " + row.Err().Error())
+		if settings.StartFen, err = Chess960Fen(position); err != nil {
+			return nil, err
+		}
 	}
 
-	err = row.Scan(&cg.Id)
+	cg := s.newChessGame(0, *white, *black, NO_OUTCOME, NO_METHOD, NO_METHOD, PLAYER_WHITE, MakeGameOptionsDefault().WithStartFen(settings.StartFen))
+	cg.CreatedAt = time.Now()
+	cg.Clock = NewGameClock(settings.TimeControl, cg.CreatedAt)
+	cg.Variant = settings.Variant
+	cg.Rated = settings.Rated
+	cg.Visibility = settings.Visibility
+	cg.BroadcastDelay = settings.BroadcastDelay
+	cg.Takebacks = settings.Takebacks
 
-	if err != nil {
-		return nil, sv.NewInternalError("CreateChessGame " + err.Error())
+	if err := s.boards.LockChessboards(ctx, white.OnboardId, black.OnboardId); err != nil {
+		return nil, err
+	}
+
+	for _, board := range []*Chessboard{white, black} {
+		if err := s.CheckGameLimitsTx(ctx, *board, cg.Clock.TimeControl); err != nil {
+			return nil, err
+		}
 	}
 
-	res, err := sv.Db.ExecContext(ctx, GetChessboardQuery(UPDATE_CURRENT_GAME_MULTI), cg.Id, white.OnboardId, black.OnboardId)
+	cgp := cg.persistent()
 
-	if err != nil {
-		return nil, sv.NewInternalError("CreateChessGame " + err.Error())
-	} else if rowsAffected, _ := res.RowsAffected(); rowsAffected != 2 {
-		return nil, sv.NewInternalError("CreateChessGame fk_cur_game update did not affect 2 rows")
+	if err := s.games.Create(ctx, &cgp); err != nil {
+		return nil, err
 	}
 
-	err = tx.Commit()
+	cg.Id = cgp.Id
 
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
+	if err := s.boards.FocusNewGame(ctx, cg.Id, white, black, cg.Clock.TimeControl.Kind == CORRESPONDENCE); err != nil {
+		return nil, err
 	}
 
-	white.CurGame.Int64 = int64(cg.Id)
-	black.CurGame.Int64 = int64(cg.Id)
+	sv.AfterCommit(ctx, cg.scheduleBotMove)
 
 	return cg, nil
 }
 
 // Update any changes to the ChessGame to the database
 func (cg *ChessGame) Save() error {
-	res, err := sv.Db.Exec(GetGameQuery(UPDATE_GAME), cg.Id, cg.GetFEN(), cg.GetTurn(), cg.GetOutcome(), cg.GetMethod())
+	return cg.save(context.Background())
+}
 
-	if err != nil {
-		return sv.NewInternalError("NewChessGame " + err.Error())
+func (cg *ChessGame) save(ctx context.Context) error {
+	if err := cg.svc.games.Update(ctx, cg.persistent()); err != nil {
+		return err
 	}
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("ChessGame")
-	}
+	cg.Version++
+
+	sv.AfterCommit(ctx, func() {
+		cg.scheduleFlagCheck()
+		cg.scheduleBotMove()
+
+		if cg.GetOutcome() != NO_OUTCOME {
+			cg.rateFinishedGame()
+		}
+	})
 
 	return nil
 }
 
-func FetchChessGame(id uint64) (*ChessGame, error) {
-	var cgp ChessGamePersistent
-
-	row := sv.Db.QueryRow(GetGameQuery(SELECT_GAME), id)
+func (s *GameService) FetchChessGame(id uint64) (*ChessGame, error) {
+	cgp, err := s.games.Fetch(context.Background(), id)
 
-	if row.Err() != nil {
-		return nil, sv.NewInternalError("FetchChessGame " + row.Err().Error())
-	}
+	if err != nil {
+		return nil, err
+	} else {
+		// Archived games may have been played against someone without a board here
+		var white, black Chessboard
 
-	err := row.Scan(&cgp.Id, &cgp.FkWhite, &cgp.FkBlack, &cgp.Fen, &cgp.CurrentMove, &cgp.Outcome, &cgp.Method, &cgp.OfferedDraw, &cgp.OfferingPlayer)
+		if cgp.FkWhite.Valid {
+			fetched, _ := s.boards.FetchChessboard(uint64(cgp.FkWhite.Int64))
+			white = *fetched
+		}
 
-	if err == sql.ErrNoRows {
-		return nil, sv.NewDoesNotExistError("Game")
-	} else {
-		white, _ := FetchChessboard(cgp.FkWhite)
-		black, _ := FetchChessboard(cgp.FkBlack)
+		if cgp.FkBlack.Valid {
+			fetched, _ := s.boards.FetchChessboard(uint64(cgp.FkBlack.Int64))
+			black = *fetched
+		}
 
-		cg := newChessGame(cgp.Id, *white, *black, cgp.Outcome, cgp.Method, cgp.OfferedDraw, cgp.OfferingPlayer, MakeGameOptionsFetchMoves())
+		cg := s.newChessGame(cgp.Id, white, black, cgp.Outcome, cgp.Method, cgp.OfferedDraw, cgp.OfferingPlayer, MakeGameOptionsFetchMoves().WithStartFen(cgp.StartFen.String))
+		cg.CreatedAt = cgp.CreatedAt
+		cg.Archived = cgp.Archived
+		cg.PgnTags = cgp.PgnTags
+		cg.Variant = cgp.Variant
+		cg.Rated = cgp.Rated
+		cg.Visibility = cgp.Visibility
+		cg.BroadcastDelay = time.Duration(cgp.BroadcastDelayMs) * time.Millisecond
+		cg.Takebacks = cgp.Takebacks
+		cg.TakebackPlies = cgp.TakebackPlies
+		cg.TakebackPlayer = cgp.TakebackPlayer
+		cg.MirroredPly = cgp.MirroredPly
+		cg.Version = cgp.Version
+
+		cg.Clock = GameClock{
+			TimeControl: TimeControl{
+				Kind:        cgp.TcKind,
+				Base:        time.Duration(cgp.TcBaseMs) * time.Millisecond,
+				Increment:   time.Duration(cgp.TcIncrementMs) * time.Millisecond,
+				DaysPerMove: cgp.TcDaysPerMove,
+			},
+			WhiteRemaining: time.Duration(cgp.WhiteTimeMs) * time.Millisecond,
+			BlackRemaining: time.Duration(cgp.BlackTimeMs) * time.Millisecond,
+			TurnStartedAt:  cgp.TurnStartedAt,
+		}
 
 		return cg, nil
 	}
 }
 
-func FetchCurrentGame(cb *Chessboard) (*ChessGame, error) {
-	if cb.OwnerId.Valid {
-		return FetchChessGame(uint64(cb.CurGame.Int64))
+// The game the board is showing
+func (s *GameService) FetchFocusedGame(cb *Chessboard) (*ChessGame, error) {
+	if cb.FocusedGame.Valid {
+		return s.FetchChessGame(uint64(cb.FocusedGame.Int64))
 	} else {
-		return nil, nil
+		return nil, sv.NewDoesNotExistError("Game")
 	}
 }
 
-func (cg *ChessGame) MakeMove(mover Chessboard, moveUci string) error {
-	if cg.GetCurrentMover().OnboardId != mover.OnboardId {
-		return sv.NewGenericError("Not your turn", 405, sv.NOT_SENSITIVE)
+// Make a move and store it together with the position it leads to, so either both are stored or neither is.
+// A move chosen at another version than the game is at is refused with a Conflict error. Returns false
+// when the guard's idempotency key shows the move has already been played, cg then holds the current state.
+func (cg *ChessGame) PlayMove(mover Chessboard, moveUci string, guard MoveGuard) (bool, error) {
+	// A flag that fell before the move ends the game by itself, whatever happens to the move
+	if flagged, err := cg.CheckFlag(); err != nil {
+		return false, err
+	} else if flagged {
+		return false, sv.NewGenericError("Time has run out", 409, sv.NOT_SENSITIVE)
 	}
 
-	move, err := cg.Game.MoveStr(moveUci)
+	replayed := false
 
-	if err != nil {
-		if strings.Contains(err.Error(), "decode") {
-			return sv.NewInvalidInputError("Move UCI")
-		} else if strings.Contains(err.Error(), "invalid move") {
-			return sv.NewGenericError("Move is not valid in current position", 405, sv.NOT_SENSITIVE)
+	err := sv.WithinTx(context.Background(), cg.svc.tx, func(ctx context.Context) error {
+		if err := cg.svc.boards.LockChessboards(ctx, cg.White.OnboardId, cg.Black.OnboardId); err != nil {
+			return err
+		}
+
+		// Checked before the version, which a retry of a move that went through is bound to be behind
+		if guard.IdempotencyKey != "" {
+			req, err := cg.svc.moves.FetchRequest(ctx, mover.OnboardId, guard.IdempotencyKey, IDEMPOTENCY_KEY_TTL)
+
+			if err == nil {
+				if req.GameId != cg.Id || req.Move != moveUci {
+					return sv.NewGenericError("Idempotency key was already used for another move", 422, sv.NOT_SENSITIVE)
+				}
+
+				replayed = true
+				return nil
+			} else if !sv.IsDoesNotExist(err) {
+				return err
+			}
 		}
-	}
 
-	from := move.S1().String()
-	to := move.S2().String()
+		if guard.Version.Valid && guard.Version.Int64 != cg.Version {
+			return sv.NewConflictError("Game")
+		}
+
+		if err := cg.makeMove(ctx, mover, moveUci); err != nil {
+			return err
+		}
 
-	piece := move.PieceMoved()
-	pieceType := CPieceToString(piece.Type())
-	pieceColor := strings.ToUpper(piece.Color().Name())
+		if err := cg.save(ctx); err != nil {
+			return err
+		}
 
-	tags := move.GetTags()
+		if guard.IdempotencyKey == "" {
+			return nil
+		}
 
-	row := sv.Db.QueryRow(GetGameQuery(CREATE_MOVE), cg.Id, pieceColor, from, to, pieceType, tags)
+		return cg.svc.moves.CreateRequest(ctx, mover.OnboardId, guard.IdempotencyKey, MoveRequest{cg.Id, moveUci}, IDEMPOTENCY_KEY_TTL)
+	})
 
-	if row.Err() != nil {
-		return sv.NewInternalError("MakeMove " + row.Err().Error())
+	if err != nil || !replayed {
+		return !replayed, err
 	}
 
-	var _id uint64
-	err = row.Scan(&_id)
+	current, err := cg.svc.FetchChessGame(cg.Id)
 
 	if err != nil {
-		return sv.NewInternalError("MakeMove " + err.Error())
+		return false, err
 	}
-	return nil
+
+	*cg = *current
+
+	return false, nil
 }
 
-func (cg *ChessGame) UndoMove() error {
-	if len(cg.Game.Moves()) == 0 {
-		return sv.NewGenericError("No moves to undo", 405, sv.NOT_SENSITIVE)
+func (cg *ChessGame) makeMove(ctx context.Context, mover Chessboard, moveUci string) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
+	if cg.GetOutcome() != NO_OUTCOME {
+		return sv.NewGenericError("Game is already over", 409, sv.NOT_SENSITIVE)
+	}
+
+	if cg.GetCurrentMover().OnboardId != mover.OnboardId {
+		return sv.NewGenericError("Not your turn", 405, sv.NOT_SENSITIVE)
+	}
+
+	if cg.AwaitingMirror() {
+		return newAwaitingMirrorError()
+	}
+
+	clock := cg.Clock
+
+	// Flagged since PlayMove checked, the flag timer adjudicates it
+	if !clock.Press(cg.GetTurn(), cg.ClockRunning(), time.Now()) {
+		return sv.NewGenericError("Time has run out", 409, sv.NOT_SENSITIVE)
 	}
 
-	res, err := sv.Db.Exec(GetGameQuery(DELETE_LAST_MOVE), cg.Id)
+	player := cg.GetTurn()
+	move, err := cg.applyMove(moveUci)
 
 	if err != nil {
-		return sv.NewInternalError("UndoMove " + err.Error())
+		if strings.Contains(err.Error(), "decode") {
+			return sv.NewInvalidInputError("Move UCI")
+		} else if strings.Contains(err.Error(), "invalid move") {
+			return sv.NewGenericError("Move is not valid in current position", 405, sv.NOT_SENSITIVE)
+		}
 	}
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("UndoMove affected " + fmt.Sprint(rowsAffected) + " rows")
+	if err = cg.svc.moves.Create(ctx, cg.Id, newMoveRecord(player, move)); err != nil {
+		return err
+	}
+
+	// A pending takeback no longer refers to the last moves once another one is made
+	if cg.TakebackPlies != 0 {
+		if err = cg.svc.games.SetTakeback(ctx, cg.Id, 0, PLAYER_WHITE); err != nil {
+			return err
+		}
+
+		cg.TakebackPlies = 0
+		cg.TakebackPlayer = PLAYER_WHITE
 	}
 
-	moves := cg.Game.Moves()
-	*cg = *newChessGame(cg.Id, cg.White, cg.Black, NO_OUTCOME, NO_METHOD, NO_METHOD, PLAYER_WHITE, MakeGameOptionsProvidedMoves(moves[:len(moves)-1]))
+	cg.Clock = clock
+	cg.checkVariantOutcome()
 
 	return nil
 }
 
-func (cg *ChessGame) FetchMoves() ([]string, error) {
-	moves := []string{}
-
-	rows, err := sv.Db.Query(GetGameQuery(GET_MOVES), cg.Id)
+// Take back the last plies moves, clear any pending takeback request and store the game
+func (cg *ChessGame) undoMoves(ctx context.Context, plies int) error {
+	moves := cg.Moves()
 
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
+	if len(moves) < plies {
+		return sv.NewGenericError("No moves to undo", 405, sv.NOT_SENSITIVE)
 	}
 
-	defer rows.Close()
+	// Both players put their boards back themselves, there is nothing to mirror
+	remaining := len(moves) - plies
 
-	for rows.Next() {
-		var from, to string
-		err = rows.Scan(&from, &to)
+	undone := cg.svc.newChessGame(cg.Id, cg.White, cg.Black, NO_OUTCOME, NO_METHOD, NO_METHOD, PLAYER_WHITE, MakeGameOptionsProvidedMoves(moves[:remaining]).WithStartFen(cg.StartFen))
+	undone.Clock = cg.Clock
+	undone.Clock.TurnStartedAt = time.Now()
+	undone.CreatedAt = cg.CreatedAt
+	undone.Variant = cg.Variant
+	undone.Rated = cg.Rated
+	undone.Visibility = cg.Visibility
+	undone.BroadcastDelay = cg.BroadcastDelay
+	undone.Takebacks = cg.Takebacks
+	undone.MirroredPly = remaining
+	undone.Version = cg.Version
 
-		if err != nil {
-			return nil, sv.NewInternalError(err.Error())
+	// Stored before the moves are deleted, so they are only deleted while the game is at the version they were taken back from
+	if err := undone.save(ctx); err != nil {
+		return err
+	}
+
+	for i := 0; i < plies; i++ {
+		if err := cg.svc.moves.DeleteLast(ctx, cg.Id); err != nil {
+			return err
 		}
+	}
 
-		moves = append(moves, (from + to))
+	if err := cg.svc.games.SetTakeback(ctx, cg.Id, 0, PLAYER_WHITE); err != nil {
+		return err
 	}
 
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
+	if err := cg.svc.games.ResetMirrored(ctx, cg.Id, remaining); err != nil {
+		return err
 	}
 
-	return moves, nil
+	*cg = *undone
+
+	return nil
+}
+
+func (cg *ChessGame) FetchMoves() ([]string, error) {
+	return cg.svc.moves.List(context.Background(), cg.Id)
 }
 
 func (cg *ChessGame) ResignGame(chessboard Chessboard) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
+	if cg.GetOutcome() != NO_OUTCOME {
+		return sv.NewGenericError("Game is already over", 409, sv.NOT_SENSITIVE)
+	}
+
 	if chessboard.OnboardId == cg.White.OnboardId {
 		cg.Game.Resign(chess.White)
 	} else if chessboard.OnboardId == cg.Black.OnboardId {
@@ -345,6 +674,10 @@ func (cg *ChessGame) ResignGame(chessboard Chessboard) error {
 }
 
 func (cg *ChessGame) OfferDraw(chessboard Chessboard, drawMethod GameMethod) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
 	eligbleDraws := cg.Game.EligibleDraws()
 
 	for _, d := range eligbleDraws {
@@ -362,20 +695,33 @@ DRAW_IS_ELIGIBLE:
 		return sv.NewGenericError("Board is not in this game", 400, sv.NOT_SENSITIVE)
 	}
 
-	res, err := sv.Db.Exec(GetGameQuery(UPDATE_DRAW), cg.Id, drawMethod, player)
+	return cg.svc.games.SetDraw(context.Background(), cg.Id, drawMethod, player)
+}
 
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
+// Accept or reject the pending draw offer. An accepted draw is stored together with the end of the game.
+func (cg *ChessGame) ResolveDraw(chessboard Chessboard, accept bool) error {
+	return sv.WithinTx(context.Background(), cg.svc.tx, func(ctx context.Context) error {
+		if err := cg.svc.boards.LockChessboards(ctx, cg.White.OnboardId, cg.Black.OnboardId); err != nil {
+			return err
+		}
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("Draw update did not affect 1 row")
-	}
+		if !accept {
+			return cg.rejectDraw(ctx, chessboard)
+		}
 
-	return nil
+		if err := cg.acceptDraw(chessboard); err != nil {
+			return err
+		}
+
+		return cg.save(ctx)
+	})
 }
 
-func (cg *ChessGame) AcceptDraw(chessboard Chessboard) error {
+func (cg *ChessGame) acceptDraw(chessboard Chessboard) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
 	if cg.OfferedDraw != DRAW_OFFER && cg.OfferedDraw != FIFTY_MOVE_RULE && cg.OfferedDraw != THREEFOLD_REPETITION {
 		return sv.NewGenericError("There is no pending draw for this game", 409, sv.NOT_SENSITIVE)
 	}
@@ -397,7 +743,11 @@ func (cg *ChessGame) AcceptDraw(chessboard Chessboard) error {
 	return nil
 }
 
-func (cg *ChessGame) RejectDraw(chessboard Chessboard) error {
+func (cg *ChessGame) rejectDraw(ctx context.Context, chessboard Chessboard) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
 	if cg.OfferedDraw != DRAW_OFFER && cg.OfferedDraw != FIFTY_MOVE_RULE && cg.OfferedDraw != THREEFOLD_REPETITION {
 		return sv.NewGenericError("There is no pending draw for this game", 409, sv.NOT_SENSITIVE)
 	}
@@ -410,14 +760,8 @@ func (cg *ChessGame) RejectDraw(chessboard Chessboard) error {
 		return sv.NewGenericError("You are not a player in this game", 403, sv.NOT_SENSITIVE)
 	}
 
-	res, err := sv.Db.Exec(GetGameQuery(UPDATE_DRAW), cg.Id, NO_METHOD, PLAYER_WHITE)
-
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
-
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("Draw update did not affect 1 row")
+	if err := cg.svc.games.SetDraw(ctx, cg.Id, NO_METHOD, PLAYER_WHITE); err != nil {
+		return err
 	}
 
 	cg.OfferedDraw = NO_METHOD
@@ -425,6 +769,10 @@ func (cg *ChessGame) RejectDraw(chessboard Chessboard) error {
 	return nil
 }
 
+func newArchivedError() error {
+	return sv.NewGenericError("Archived games cannot be played", 409, sv.NOT_SENSITIVE)
+}
+
 func (cg *ChessGame) PrintBoard() string {
 	pieceTiles := [13]byte{'-', 'K', 'Q', 'R', 'B', 'N', 'P', 'k', 'q', 'r', 'b', 'n', 'p'}
 
@@ -447,6 +795,14 @@ func (cg *ChessGame) PrintBoard() string {
 	return string(output)
 }
 
+func chessColor(player PlayerColor) chess.Color {
+	if player == PLAYER_WHITE {
+		return chess.White
+	} else {
+		return chess.Black
+	}
+}
+
 func CPieceToString(p chess.PieceType) string {
 	switch p {
 	case chess.King:
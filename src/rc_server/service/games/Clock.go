@@ -0,0 +1,403 @@
+package games
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type TimeControlKind int
+
+const (
+	UNTIMED        TimeControlKind = iota
+	FISCHER                        // Base time, Increment added after every move
+	BRONSTEIN                      // Base time, up to Increment of the time used is given back after every move
+	SIMPLE_DELAY                   // Base time, the clock waits Increment before it starts counting down
+	CORRESPONDENCE                 // DaysPerMove for every move, no base time
+)
+
+const (
+	maxBaseTime    = 10 * time.Hour
+	maxIncrement   = 10 * time.Minute
+	maxDaysPerMove = 60
+)
+
+var (
+	timeControlToStr = map[TimeControlKind]string{
+		UNTIMED:        "UNTIMED",
+		FISCHER:        "FISCHER",
+		BRONSTEIN:      "BRONSTEIN",
+		SIMPLE_DELAY:   "SIMPLE_DELAY",
+		CORRESPONDENCE: "CORRESPONDENCE",
+	}
+
+	strToTimeControl = inverseMap(timeControlToStr).(map[string]TimeControlKind)
+)
+
+type TimeControl struct {
+	Kind        TimeControlKind
+	Base        time.Duration
+	Increment   time.Duration // The increment for FISCHER, or the delay for BRONSTEIN and SIMPLE_DELAY
+	DaysPerMove int
+}
+
+type GameClock struct {
+	TimeControl
+	WhiteRemaining time.Duration
+	BlackRemaining time.Duration
+	TurnStartedAt  time.Time
+}
+
+func (k TimeControlKind) String() string {
+	return timeControlToStr[k]
+}
+
+func TimeControlKindFromString(s string) (TimeControlKind, error) {
+	if kind, ok := strToTimeControl[strings.ToUpper(s)]; ok {
+		return kind, nil
+	}
+
+	return UNTIMED, sv.NewInvalidInputError("Time control " + s)
+}
+
+func (this *TimeControlKind) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToTimeControl[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid TimeControlKind enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this TimeControlKind) Value() (driver.Value, error) {
+	if val, ok := timeControlToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown TimeControlKind")
+	}
+}
+
+func NewTimeControl(kind TimeControlKind, base time.Duration, increment time.Duration, daysPerMove int) (TimeControl, error) {
+	tc := TimeControl{Kind: kind, Base: base, Increment: increment, DaysPerMove: daysPerMove}
+
+	switch kind {
+	case UNTIMED:
+		return TimeControl{Kind: UNTIMED}, nil
+	case FISCHER, BRONSTEIN, SIMPLE_DELAY:
+		if base <= 0 || base > maxBaseTime {
+			return tc, sv.NewInvalidInputError("Base time")
+		}
+
+		if increment < 0 || increment > maxIncrement {
+			return tc, sv.NewInvalidInputError("Increment")
+		}
+
+		tc.DaysPerMove = 0
+	case CORRESPONDENCE:
+		if daysPerMove < 1 || daysPerMove > maxDaysPerMove {
+			return tc, sv.NewInvalidInputError("Days per move")
+		}
+
+		tc.Base = 0
+		tc.Increment = 0
+	default:
+		return tc, sv.NewInvalidInputError("Time control")
+	}
+
+	return tc, nil
+}
+
+// The rating pool games with this time control are rated in, by their estimated
+// duration of the base time plus 40 increments
+func (tc TimeControl) RatingPool() RatingPool {
+	estimated := tc.Base + 40*tc.Increment
+
+	switch {
+	case tc.Kind == CORRESPONDENCE:
+		return CORRESPONDENCE_POOL
+	case tc.Kind == UNTIMED:
+		return CLASSICAL
+	case estimated < 3*time.Minute:
+		return BULLET
+	case estimated < 8*time.Minute:
+		return BLITZ
+	case estimated < 25*time.Minute:
+		return RAPID
+	default:
+		return CLASSICAL
+	}
+}
+
+func (tc TimeControl) perMove() time.Duration {
+	return time.Duration(tc.DaysPerMove) * 24 * time.Hour
+}
+
+// A fresh clock with both players' full starting time
+func NewGameClock(tc TimeControl, now time.Time) GameClock {
+	clock := GameClock{TimeControl: tc, TurnStartedAt: now}
+
+	if tc.Kind == CORRESPONDENCE {
+		clock.WhiteRemaining = tc.perMove()
+	} else {
+		clock.WhiteRemaining = tc.Base
+	}
+
+	clock.BlackRemaining = clock.WhiteRemaining
+
+	return clock
+}
+
+func (c *GameClock) stored(player PlayerColor) *time.Duration {
+	if player == PLAYER_WHITE {
+		return &c.WhiteRemaining
+	} else {
+		return &c.BlackRemaining
+	}
+}
+
+// The moment the player to move runs out of time, assuming they do not move before then
+func (c *GameClock) FlagsAt(turn PlayerColor) time.Time {
+	switch c.Kind {
+	case SIMPLE_DELAY:
+		return c.TurnStartedAt.Add(c.Increment + *c.stored(turn))
+	case CORRESPONDENCE:
+		return c.TurnStartedAt.Add(c.perMove())
+	default:
+		return c.TurnStartedAt.Add(*c.stored(turn))
+	}
+}
+
+// Time left on a player's clock at the given moment. Only the player to move loses time, and only while the clock runs.
+func (c *GameClock) Remaining(player PlayerColor, turn PlayerColor, running bool, now time.Time) time.Duration {
+	stored := *c.stored(player)
+
+	if !running || player != turn {
+		return stored
+	}
+
+	remaining := c.FlagsAt(turn).Sub(now)
+
+	if remaining < 0 {
+		return 0
+	} else if remaining > stored && c.Kind != CORRESPONDENCE {
+		// Still inside a SIMPLE_DELAY period
+		return stored
+	}
+
+	return remaining
+}
+
+// Stop the mover's clock and start the opponent's. Returns false if the mover had already run out of time.
+func (c *GameClock) Press(mover PlayerColor, running bool, now time.Time) bool {
+	defer func() { c.TurnStartedAt = now }()
+
+	if !running {
+		return true
+	}
+
+	elapsed := now.Sub(c.TurnStartedAt)
+	remaining := c.Remaining(mover, mover, running, now)
+
+	if remaining <= 0 {
+		return false
+	}
+
+	switch c.Kind {
+	case FISCHER:
+		remaining += c.Increment
+	case BRONSTEIN:
+		if elapsed < c.Increment {
+			remaining += elapsed
+		} else {
+			remaining += c.Increment
+		}
+	case CORRESPONDENCE:
+		remaining = c.perMove()
+	}
+
+	*c.stored(mover) = remaining
+	return true
+}
+
+// Whether the player to move's clock is currently counting down. Over-the-board clocks only
+// start once both sides have made their first move, correspondence deadlines apply from the start.
+func (cg *ChessGame) ClockRunning() bool {
+	if cg.Clock.Kind == UNTIMED || cg.GetOutcome() != NO_OUTCOME {
+		return false
+	}
+
+	return cg.Clock.Kind == CORRESPONDENCE || len(cg.Moves()) >= 2
+}
+
+func (cg *ChessGame) GetRemainingTime(player PlayerColor) time.Duration {
+	return cg.Clock.Remaining(player, cg.GetTurn(), cg.ClockRunning(), time.Now())
+}
+
+// Adjudicate the game if the player to move has run out of time.
+// Returns whether the game was ended by this call.
+func (cg *ChessGame) CheckFlag() (bool, error) {
+	if !cg.ClockRunning() || time.Now().Before(cg.Clock.FlagsAt(cg.GetTurn())) {
+		return false, nil
+	}
+
+	loser := cg.GetTurn()
+	winner := loser.Other()
+
+	*cg.Clock.stored(loser) = 0
+
+	// A lone king can still win King of the Hill by walking to the center
+	if cg.Variant != KING_OF_THE_HILL_VARIANT && !hasMatingMaterial(cg.Game.Position().Board(), chessColor(winner)) {
+		cg.Adjudicate(DRAW, TIMEOUT_VS_INSUFFICIENT_MATERIAL)
+	} else if winner == PLAYER_WHITE {
+		cg.Adjudicate(WHITE_WON, TIMEOUT)
+	} else {
+		cg.Adjudicate(BLACK_WON, TIMEOUT)
+	}
+
+	adjudicated, err := cg.svc.games.Adjudicate(context.Background(), cg.Id, cg.GetOutcome(), cg.GetMethod(),
+		cg.Clock.WhiteRemaining.Milliseconds(), cg.Clock.BlackRemaining.Milliseconds())
+
+	if err != nil {
+		return false, err
+	}
+
+	if !adjudicated {
+		// Someone else ended the game first and has already announced it
+		return false, nil
+	}
+
+	cg.Version++
+
+	err = cg.PublishEvent(GAME_OVER_EVENT, EventData{
+		Player:  loser.String(),
+		Outcome: cg.GetOutcome().ToStore(),
+		Method:  cg.GetMethod().String(),
+	})
+
+	cg.rateFinishedGame()
+
+	return true, err
+}
+
+// Whether a player has enough material left that they could ever deliver checkmate.
+// A player whose opponent flags without it only gets a draw.
+func hasMatingMaterial(board *chess.Board, color chess.Color) bool {
+	minors := 0
+
+	for _, p := range board.SquareMap() {
+		if p.Color() != color {
+			continue
+		}
+
+		switch p.Type() {
+		case chess.Queen, chess.Rook, chess.Pawn:
+			return true
+		case chess.Bishop, chess.Knight:
+			minors++
+		}
+	}
+
+	return minors >= 2
+}
+
+// Periodically adjudicate live games whose player to move has run out of time. The flag timers end most
+// games on time, this catches the ones whose timer was lost to a restart or set on another server.
+func (s *GameService) StartFlagScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.adjudicateFlaggedGames(); err != nil {
+				s.log.Error("flag checks: " + err.Error())
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *GameService) adjudicateFlaggedGames() error {
+	ids, err := s.games.ListFlaggedLive(context.Background())
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err = s.adjudicateFlag(id); err != nil {
+			s.log.Error("adjudicating game " + fmt.Sprint(id) + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Looking at a game never ends it, only this and the actions of its players do
+func (s *GameService) adjudicateFlag(id uint64) error {
+	cg, err := s.FetchChessGame(id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = cg.CheckFlag()
+	return err
+}
+
+// A timer for the next flag fall of each game whose clock is running, see scheduleFlagCheck
+type flagTimerSet struct {
+	sync.Mutex
+	timers map[uint64]*time.Timer
+}
+
+// Make sure a flag fall is noticed and announced even if nobody looks at the game
+func (cg *ChessGame) scheduleFlagCheck() {
+	cg.svc.flagTimers.Lock()
+	defer cg.svc.flagTimers.Unlock()
+
+	if t, ok := cg.svc.flagTimers.timers[cg.Id]; ok {
+		t.Stop()
+		delete(cg.svc.flagTimers.timers, cg.Id)
+	}
+
+	if !cg.ClockRunning() {
+		return
+	}
+
+	id := cg.Id
+	var timer *time.Timer
+
+	timer = time.AfterFunc(time.Until(cg.Clock.FlagsAt(cg.GetTurn())), func() {
+		cg.svc.flagTimers.Lock()
+
+		if cg.svc.flagTimers.timers[id] == timer {
+			delete(cg.svc.flagTimers.timers, id)
+		}
+
+		cg.svc.flagTimers.Unlock()
+
+		if err := cg.svc.adjudicateFlag(id); err != nil {
+			cg.svc.log.Error("flag check for game " + fmt.Sprint(id) + ": " + err.Error())
+		}
+	})
+
+	cg.svc.flagTimers.timers[id] = timer
+}
@@ -0,0 +1,108 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	. "remotechess/src/rc_server/service/common"
+)
+
+var clockStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestGameClockPress(t *testing.T) {
+	tests := []struct {
+		name      string
+		tc        TimeControl
+		running   bool
+		elapsed   time.Duration
+		ok        bool
+		remaining time.Duration // White's time after pressing
+	}{
+		{"fischer adds the increment", TimeControl{Kind: FISCHER, Base: 5 * time.Minute, Increment: 2 * time.Second}, true, 10 * time.Second, true, 4*time.Minute + 52*time.Second},
+		{"fischer flag", TimeControl{Kind: FISCHER, Base: 5 * time.Minute, Increment: 2 * time.Second}, true, 5 * time.Minute, false, 5 * time.Minute},
+		{"bronstein gives back the time used", TimeControl{Kind: BRONSTEIN, Base: 5 * time.Minute, Increment: 3 * time.Second}, true, 2 * time.Second, true, 5 * time.Minute},
+		{"bronstein gives back at most the delay", TimeControl{Kind: BRONSTEIN, Base: 5 * time.Minute, Increment: 3 * time.Second}, true, 10 * time.Second, true, 4*time.Minute + 53*time.Second},
+		{"delay not used up", TimeControl{Kind: SIMPLE_DELAY, Base: 5 * time.Minute, Increment: 3 * time.Second}, true, 2 * time.Second, true, 5 * time.Minute},
+		{"delay used up", TimeControl{Kind: SIMPLE_DELAY, Base: 5 * time.Minute, Increment: 3 * time.Second}, true, 10 * time.Second, true, 4*time.Minute + 53*time.Second},
+		{"delay flag", TimeControl{Kind: SIMPLE_DELAY, Base: 5 * time.Minute, Increment: 3 * time.Second}, true, 5*time.Minute + 3*time.Second, false, 5 * time.Minute},
+		{"correspondence resets the deadline", TimeControl{Kind: CORRESPONDENCE, DaysPerMove: 3}, true, 48 * time.Hour, true, 72 * time.Hour},
+		{"correspondence deadline missed", TimeControl{Kind: CORRESPONDENCE, DaysPerMove: 3}, true, 73 * time.Hour, false, 72 * time.Hour},
+		{"stopped clock", TimeControl{Kind: FISCHER, Base: 5 * time.Minute, Increment: 2 * time.Second}, false, time.Hour, true, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewGameClock(tt.tc, clockStart)
+			now := clockStart.Add(tt.elapsed)
+
+			if ok := clock.Press(PLAYER_WHITE, tt.running, now); ok != tt.ok {
+				t.Fatalf("Press returned %t", ok)
+			}
+
+			if clock.WhiteRemaining != tt.remaining {
+				t.Fatalf("white has %v, want %v", clock.WhiteRemaining, tt.remaining)
+			}
+
+			if clock.BlackRemaining != NewGameClock(tt.tc, clockStart).BlackRemaining {
+				t.Fatalf("black's clock changed to %v", clock.BlackRemaining)
+			}
+
+			if !clock.TurnStartedAt.Equal(now) {
+				t.Fatalf("black's turn started at %v", clock.TurnStartedAt)
+			}
+		})
+	}
+}
+
+func TestGameClockRemaining(t *testing.T) {
+	tests := []struct {
+		name      string
+		tc        TimeControl
+		player    PlayerColor
+		running   bool
+		elapsed   time.Duration
+		remaining time.Duration
+	}{
+		{"mover counts down", TimeControl{Kind: FISCHER, Base: time.Minute}, PLAYER_WHITE, true, 15 * time.Second, 45 * time.Second},
+		{"opponent is not charged", TimeControl{Kind: FISCHER, Base: time.Minute}, PLAYER_BLACK, true, 15 * time.Second, time.Minute},
+		{"stopped clock", TimeControl{Kind: FISCHER, Base: time.Minute}, PLAYER_WHITE, false, 15 * time.Second, time.Minute},
+		{"flagged", TimeControl{Kind: FISCHER, Base: time.Minute}, PLAYER_WHITE, true, 2 * time.Minute, 0},
+		{"bronstein counts down at once", TimeControl{Kind: BRONSTEIN, Base: time.Minute, Increment: 5 * time.Second}, PLAYER_WHITE, true, 2 * time.Second, 58 * time.Second},
+		{"inside the delay", TimeControl{Kind: SIMPLE_DELAY, Base: time.Minute, Increment: 5 * time.Second}, PLAYER_WHITE, true, 2 * time.Second, time.Minute},
+		{"after the delay", TimeControl{Kind: SIMPLE_DELAY, Base: time.Minute, Increment: 5 * time.Second}, PLAYER_WHITE, true, 15 * time.Second, 50 * time.Second},
+		{"correspondence", TimeControl{Kind: CORRESPONDENCE, DaysPerMove: 2}, PLAYER_WHITE, true, 12 * time.Hour, 36 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewGameClock(tt.tc, clockStart)
+
+			if remaining := clock.Remaining(tt.player, PLAYER_WHITE, tt.running, clockStart.Add(tt.elapsed)); remaining != tt.remaining {
+				t.Fatalf("got %v, want %v", remaining, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestGameClockFlagsAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		tc      TimeControl
+		flagsIn time.Duration
+	}{
+		{"fischer", TimeControl{Kind: FISCHER, Base: 3 * time.Minute, Increment: 2 * time.Second}, 3 * time.Minute},
+		{"bronstein", TimeControl{Kind: BRONSTEIN, Base: 3 * time.Minute, Increment: 2 * time.Second}, 3 * time.Minute},
+		{"simple delay waits first", TimeControl{Kind: SIMPLE_DELAY, Base: 3 * time.Minute, Increment: 2 * time.Second}, 3*time.Minute + 2*time.Second},
+		{"correspondence", TimeControl{Kind: CORRESPONDENCE, DaysPerMove: 5}, 5 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewGameClock(tt.tc, clockStart)
+
+			if flagsAt := clock.FlagsAt(PLAYER_BLACK); !flagsAt.Equal(clockStart.Add(tt.flagsIn)) {
+				t.Fatalf("flags at %v, want %v", flagsAt, clockStart.Add(tt.flagsIn))
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package games
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+// How long before a correspondence deadline each reminder is queued for the player to move
+var deadlineReminders = []struct {
+	kind NotificationKind
+	lead time.Duration
+}{
+	{DEADLINE_DAY_REMINDER, 24 * time.Hour},
+	{DEADLINE_HOUR_REMINDER, time.Hour},
+}
+
+// Periodically adjudicate correspondence games whose mover let the deadline pass and queue reminders
+// for deadlines coming up. Since every check is a conditional update it is safe to run on several servers at once.
+func (s *GameService) StartCorrespondenceScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runCorrespondenceChecks()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *GameService) runCorrespondenceChecks() {
+	if err := s.adjudicateOverdueGames(); err != nil {
+		s.log.Error("correspondence deadlines: " + err.Error())
+	}
+
+	for _, r := range deadlineReminders {
+		if err := s.queueDeadlineReminders(r.kind, r.lead); err != nil {
+			s.log.Error("correspondence reminders: " + err.Error())
+		}
+	}
+}
+
+func (s *GameService) adjudicateOverdueGames() error {
+	ids, err := s.games.ListOverdueCorrespondence(context.Background())
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err = s.adjudicateFlag(id); err != nil {
+			s.log.Error("adjudicating game " + fmt.Sprint(id) + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Queue a reminder for every deadline within lead that has not had one yet, and tell the boards concerned
+func (s *GameService) queueDeadlineReminders(kind NotificationKind, lead time.Duration) error {
+	queued, err := s.games.QueueDeadlineReminders(context.Background(), kind, lead)
+
+	if err != nil {
+		return err
+	}
+
+	for _, n := range queued {
+		s.events.Notify(n.GameId, []uint64{n.BoardId}, DEADLINE_REMINDER_EVENT, EventData{
+			Reminder: n.Kind.String(),
+			Deadline: n.Deadline.UnixMilli(),
+		})
+	}
+
+	return nil
+}
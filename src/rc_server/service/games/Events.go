@@ -0,0 +1,40 @@
+package games
+
+import (
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
+)
+
+// Publish an event for this game to its log and to any subscribed clients.
+// If the game has ended, a GAME_OVER event follows it.
+func (cg *ChessGame) PublishEvent(kind EventKind, data EventData) error {
+	boards := []uint64{cg.White.OnboardId, cg.Black.OnboardId}
+
+	if data.Fen == "" {
+		data.Fen = cg.GetFEN()
+	}
+
+	if data.Clock == nil && cg.Clock.Kind != UNTIMED {
+		data.Clock = &EventClock{
+			WhiteMs: cg.GetRemainingTime(PLAYER_WHITE).Milliseconds(),
+			BlackMs: cg.GetRemainingTime(PLAYER_BLACK).Milliseconds(),
+		}
+	}
+
+	_, err := cg.svc.events.Publish(cg.Id, boards, kind, data)
+
+	if err != nil {
+		return err
+	}
+
+	if kind != GAME_OVER_EVENT && cg.GetOutcome() != NO_OUTCOME {
+		_, err = cg.svc.events.Publish(cg.Id, boards, GAME_OVER_EVENT, EventData{
+			Outcome: cg.GetOutcome().ToStore(),
+			Method:  cg.GetMethod().String(),
+			Fen:     data.Fen,
+			Clock:   data.Clock,
+		})
+	}
+
+	return err
+}
@@ -0,0 +1,224 @@
+package games
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// Everything about a game that is chosen before it starts, either when it is
+// created directly or by the sender of an invitation
+type GameSettings struct {
+	TimeControl TimeControl
+	StartFen    string // Empty for the standard starting position
+	Variant     GameVariant
+	Rated       bool // Casual games leave the players' ratings alone
+	Takebacks   bool // Whether the players may ask each other to take moves back
+
+	Visibility     GameVisibility
+	BroadcastDelay time.Duration
+
+	// Only used for CHESS960 games. RANDOM_CHESS960_POSITION
+	// has a position picked when the game is created.
+	Chess960Position int
+}
+
+func MakeGameSettingsDefault() GameSettings {
+	return GameSettings{TimeControl: TimeControl{Kind: UNTIMED}, Variant: STANDARD, Takebacks: true, Chess960Position: RANDOM_CHESS960_POSITION}
+}
+
+// Invitations keep their settings in a single JSON column until a game is created from them
+func (this *GameSettings) Scan(value interface{}) error {
+	if value == nil {
+		*this = MakeGameSettingsDefault()
+		return nil
+	}
+
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	*this = MakeGameSettingsDefault()
+
+	if err := json.Unmarshal(b, this); err != nil {
+		return sv.NewInternalError("Invalid GameSettings received: " + err.Error())
+	}
+
+	return nil
+}
+
+func (this GameSettings) Value() (driver.Value, error) {
+	b, err := json.Marshal(this)
+
+	if err != nil {
+		return nil, sv.NewInternalError("GameSettings " + err.Error())
+	}
+
+	return b, nil
+}
+
+// Check that a FEN describes a position a game can be started from,
+// and return it in the canonical form the chess engine writes it in
+func NormalizeStartFen(fen string) (string, error) {
+	fenOption, err := chess.FEN(fen)
+
+	if err != nil {
+		return "", sv.NewInvalidInputError("FEN")
+	}
+
+	game := chess.NewGame(fenOption)
+	whiteKings, blackKings := 0, 0
+
+	for sq, p := range game.Position().Board().SquareMap() {
+		if p == chess.WhiteKing {
+			whiteKings++
+		} else if p == chess.BlackKing {
+			blackKings++
+		} else if p.Type() == chess.Pawn && (sq.Rank() == chess.Rank1 || sq.Rank() == chess.Rank8) {
+			return "", sv.NewGenericError("Pawns cannot stand on the first or last rank", 400, sv.NOT_SENSITIVE)
+		}
+	}
+
+	if whiteKings != 1 || blackKings != 1 {
+		return "", sv.NewGenericError("Each side must have exactly one king", 400, sv.NOT_SENSITIVE)
+	}
+
+	pos := game.Position()
+
+	// The side to move could take the king
+	if inCheck(pos.Board(), pos.Turn().Other()) {
+		return "", sv.NewInvalidInputError("FEN")
+	}
+
+	if !castlingMatchesBoard(pos) {
+		return "", sv.NewInvalidInputError("FEN")
+	}
+
+	if len(game.Position().ValidMoves()) == 0 {
+		return "", sv.NewGenericError("The side to move has no legal moves", 400, sv.NOT_SENSITIVE)
+	}
+
+	return game.FEN(), nil
+}
+
+// Every castling right needs its king and rook still on their starting squares
+func castlingMatchesBoard(pos *chess.Position) bool {
+	rights := []struct {
+		color      chess.Color
+		side       chess.Side
+		king, rook chess.Square
+	}{
+		{chess.White, chess.KingSide, chess.E1, chess.H1},
+		{chess.White, chess.QueenSide, chess.E1, chess.A1},
+		{chess.Black, chess.KingSide, chess.E8, chess.H8},
+		{chess.Black, chess.QueenSide, chess.E8, chess.A8},
+	}
+
+	board := pos.Board()
+
+	for _, r := range rights {
+		if !pos.CastleRights().CanCastle(r.color, r.side) {
+			continue
+		}
+
+		if board.Piece(r.king) != chess.NewPiece(chess.King, r.color) || board.Piece(r.rook) != chess.NewPiece(chess.Rook, r.color) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether the king of color is attacked by any of the opponent's pieces
+func inCheck(board *chess.Board, color chess.Color) bool {
+	squares := board.SquareMap()
+	var king chess.Square
+
+	for sq, p := range squares {
+		if p == chess.NewPiece(chess.King, color) {
+			king = sq
+		}
+	}
+
+	for sq, p := range squares {
+		if p.Color() == color.Other() && attacks(board, p, sq, king) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether piece p standing on from attacks to
+func attacks(board *chess.Board, p chess.Piece, from chess.Square, to chess.Square) bool {
+	df, dr := int(to.File())-int(from.File()), int(to.Rank())-int(from.Rank())
+	adf, adr := abs(df), abs(dr)
+
+	switch p.Type() {
+	case chess.Pawn:
+		forward := 1
+
+		if p.Color() == chess.Black {
+			forward = -1
+		}
+
+		return dr == forward && adf == 1
+	case chess.Knight:
+		return (adf == 1 && adr == 2) || (adf == 2 && adr == 1)
+	case chess.King:
+		return adf <= 1 && adr <= 1 && adf+adr > 0
+	case chess.Rook:
+		return (df == 0) != (dr == 0) && pathClear(board, from, df, dr)
+	case chess.Bishop:
+		return adf == adr && adf > 0 && pathClear(board, from, df, dr)
+	case chess.Queen:
+		return ((df == 0) != (dr == 0) || (adf == adr && adf > 0)) && pathClear(board, from, df, dr)
+	}
+
+	return false
+}
+
+// Whether the squares strictly between from and from + (df, dr) on a straight or diagonal line are empty
+func pathClear(board *chess.Board, from chess.Square, df int, dr int) bool {
+	stepF, stepR := sign(df), sign(dr)
+	steps := abs(df)
+
+	if abs(dr) > steps {
+		steps = abs(dr)
+	}
+
+	for i := 1; i < steps; i++ {
+		sq := chess.NewSquare(chess.File(int(from.File())+i*stepF), chess.Rank(int(from.Rank())+i*stepR))
+
+		if board.Piece(sq) != chess.NoPiece {
+			return false
+		}
+	}
+
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+
+	return 0
+}
@@ -0,0 +1,41 @@
+package games
+
+import (
+	"testing"
+)
+
+func TestNormalizeStartFen(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		ok   bool
+	}{
+		{"standard", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", true},
+		{"endgame", "8/5k2/8/8/3K4/8/4P3/8 b - - 3 41", true},
+		{"castling with its rook", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", true},
+		{"not a FEN", "rnbqkbnr/pppppppp/8/8", false},
+		{"two white kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", false},
+		{"no black king", "8/8/8/8/8/8/8/4K3 w - - 0 1", false},
+		{"pawn on the first rank", "4k3/8/8/8/8/8/8/P3K3 w - - 0 1", false},
+		{"pawn on the last rank", "p3k3/8/8/8/8/8/8/4K3 w - - 0 1", false},
+		{"king can be taken", "4k3/8/8/8/8/8/4R3/4K3 w - - 0 1", false},
+		{"castling without the rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", false},
+		{"castling after the king moved", "4k3/8/8/8/8/8/8/3K3R w K - 0 1", false},
+		{"stalemate", "k7/8/1Q6/8/8/8/8/4K3 b - - 0 1", false},
+		{"checkmate", "k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fen, err := NormalizeStartFen(tt.fen)
+
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v", err)
+			}
+
+			if tt.ok && fen != tt.fen {
+				t.Fatalf("normalized to %q", fen)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package games
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+const (
+	DEFAULT_HISTORY_PAGE_SIZE = 20
+	MAX_HISTORY_PAGE_SIZE     = 100
+)
+
+// Results of a game from the point of view of the player whose history is searched
+var historyResults = map[string]bool{"WIN": true, "LOSS": true, "DRAW": true, "ONGOING": true}
+
+// Narrows down a search of a player's games. Zero values match everything.
+type GameFilter struct {
+	Color    NullablePlayerColor // The color the searched player had
+	Opponent sql.NullInt64       // User ID of the other player
+	Result   string              // WIN, LOSS, DRAW or ONGOING
+	Method   *GameMethod
+	Variant  *GameVariant
+	From     sql.NullTime // Games created at or after
+	To       sql.NullTime // Games created before
+	Limit    int
+	Offset   int
+}
+
+// A finished or ongoing game as shown in a list, without its moves.
+// Players without a board on this server are only known by the name their game was imported with.
+type GameSummary struct {
+	Id                       uint64
+	WhiteBoard, BlackBoard   sql.NullInt64
+	WhiteUserId, BlackUserId sql.NullInt64
+	WhiteName, BlackName     sql.NullString
+	Outcome                  GameOutcome
+	Method                   GameMethod
+	Variant                  GameVariant
+	Rated                    bool
+	TimeControl              TimeControlKind
+	MoveCount                int
+	CreatedAt                time.Time
+	EndedAt                  sql.NullTime
+}
+
+type GameHistoryPage struct {
+	Games  []GameSummary
+	Total  int // Matching games across all pages
+	Limit  int
+	Offset int
+}
+
+func ParseHistoryResult(s string) (string, error) {
+	result := strings.ToUpper(s)
+
+	if !historyResults[result] {
+		return "", sv.NewInvalidInputError("Result " + s)
+	}
+
+	return result, nil
+}
+
+// Games played on any board the user owns
+func (s *GameService) SearchUserGames(user UserCore, filter GameFilter) (*GameHistoryPage, error) {
+	return s.searchGames(sql.NullInt64{Int64: int64(user.Id), Valid: true}, sql.NullInt64{}, filter)
+}
+
+// Games played on the board, whoever owned it at the time
+func (s *GameService) SearchBoardGames(board Chessboard, filter GameFilter) (*GameHistoryPage, error) {
+	return s.searchGames(sql.NullInt64{}, sql.NullInt64{Int64: int64(board.OnboardId), Valid: true}, filter)
+}
+
+func (s *GameService) searchGames(userId sql.NullInt64, boardId sql.NullInt64, filter GameFilter) (*GameHistoryPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DEFAULT_HISTORY_PAGE_SIZE
+	} else if filter.Limit > MAX_HISTORY_PAGE_SIZE {
+		filter.Limit = MAX_HISTORY_PAGE_SIZE
+	}
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	games, total, err := s.games.Search(context.Background(), userId, boardId, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GameHistoryPage{Games: games, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
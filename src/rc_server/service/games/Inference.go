@@ -0,0 +1,235 @@
+package games
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+type InferenceStatus int
+
+const (
+	NO_CHANGE       InferenceStatus = iota // The pieces are back where they started
+	IN_PROGRESS                            // No legal move matches the board yet
+	NEEDS_PROMOTION                        // A pawn reached the last rank and the board must say what it became
+	MOVE_READY                             // Exactly one legal move matches the board
+)
+
+var inferenceStatusToStr = map[InferenceStatus]string{
+	NO_CHANGE:       "NO_CHANGE",
+	IN_PROGRESS:     "IN_PROGRESS",
+	NEEDS_PROMOTION: "NEEDS_PROMOTION",
+	MOVE_READY:      "MOVE_READY",
+}
+
+// A piece being lifted off or placed on a square, as sensed by a board that can only tell whether a square is occupied
+type SensorEvent struct {
+	Action BoardAction
+	Square chess.Square
+}
+
+type Inference struct {
+	Status     InferenceStatus
+	Move       string   // UCI notation, set when the status is MOVE_READY
+	Candidates []string // The promotions to choose from when the status is NEEDS_PROMOTION
+}
+
+// The sensor events of the move the current mover is making in each game
+type sensorState struct {
+	board  uint64
+	ply    int // How many moves the game had when the events started
+	events []SensorEvent
+}
+
+type sensorStateSet struct {
+	sync.Mutex
+	games map[uint64]*sensorState
+}
+
+func (s InferenceStatus) String() string {
+	return inferenceStatusToStr[s]
+}
+
+// Read a square in algebraic notation, e.g. e4
+func ParseSquare(s string) (chess.Square, error) {
+	s = strings.ToLower(s)
+
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return chess.NoSquare, sv.NewInvalidInputError("Square " + s)
+	}
+
+	return chess.NewSquare(chess.File(s[0]-'a'), chess.Rank(s[1]-'1')), nil
+}
+
+// Read the piece a pawn promotes to, by name or by its letter in UCI notation
+func ParsePromotionPiece(s string) (chess.PieceType, error) {
+	switch strings.ToLower(s) {
+	case "q", "queen":
+		return chess.Queen, nil
+	case "r", "rook":
+		return chess.Rook, nil
+	case "b", "bishop":
+		return chess.Bishop, nil
+	case "n", "knight":
+		return chess.Knight, nil
+	}
+
+	return chess.NoPieceType, sv.NewInvalidInputError("Promotion piece " + s)
+}
+
+// Add a lift or place to the move the board's player is making and work out which legal move it adds up to.
+// Once exactly one move matches it is returned as MOVE_READY for the caller to play, and the events are cleared.
+func (cg *ChessGame) RecordSensorEvent(mover Chessboard, ev SensorEvent) (Inference, error) {
+	return cg.inferMove(mover, &ev, chess.NoPieceType)
+}
+
+// Settle which piece a pawn promoted to when the sensor events could not tell
+func (cg *ChessGame) ChoosePromotion(mover Chessboard, piece chess.PieceType) (Inference, error) {
+	if piece == chess.NoPieceType || piece == chess.King || piece == chess.Pawn {
+		return Inference{}, sv.NewInvalidInputError("Promotion piece")
+	}
+
+	return cg.inferMove(mover, nil, piece)
+}
+
+// Forget the sensor events so far, e.g. after the board has been put back into sync
+func (cg *ChessGame) ResetSensorEvents() {
+	cg.svc.sensorStates.Lock()
+	defer cg.svc.sensorStates.Unlock()
+
+	delete(cg.svc.sensorStates.games, cg.Id)
+}
+
+func (cg *ChessGame) inferMove(mover Chessboard, ev *SensorEvent, promotion chess.PieceType) (Inference, error) {
+	if cg.Archived {
+		return Inference{}, newArchivedError()
+	}
+
+	if cg.GetOutcome() != NO_OUTCOME {
+		return Inference{}, sv.NewGenericError("Game is already over", 409, sv.NOT_SENSITIVE)
+	}
+
+	if cg.GetCurrentMover().OnboardId != mover.OnboardId {
+		return Inference{}, sv.NewGenericError("Not your turn", 405, sv.NOT_SENSITIVE)
+	}
+
+	// Until then the board is busy reproducing the opponent's move, which is not a move of its own
+	if cg.AwaitingMirror() {
+		return Inference{}, newAwaitingMirrorError()
+	}
+
+	cg.svc.sensorStates.Lock()
+	defer cg.svc.sensorStates.Unlock()
+
+	ply := len(cg.Moves())
+	state, ok := cg.svc.sensorStates.games[cg.Id]
+
+	// Events left over from before the last move no longer mean anything
+	if !ok || state.ply != ply || state.board != mover.OnboardId {
+		state = &sensorState{board: mover.OnboardId, ply: ply}
+		cg.svc.sensorStates.games[cg.Id] = state
+	}
+
+	if ev != nil {
+		state.events = append(state.events, *ev)
+	}
+
+	inference := matchSensorEvents(cg.Game.Position(), cg.chess960Castlings(), state.events, promotion)
+
+	if inference.Status == MOVE_READY || inference.Status == NO_CHANGE {
+		delete(cg.svc.sensorStates.games, cg.Id)
+	}
+
+	return inference, nil
+}
+
+// Find the legal moves that leave the board occupied the way the events did and that touch no square the
+// player left alone. Captures show up as the captured square being lifted and placed on again.
+// Chess960 castling moves are not among the chess engine's valid moves and are passed in separately.
+func matchSensorEvents(pos *chess.Position, castlings []chess960Castling, events []SensorEvent, promotion chess.PieceType) Inference {
+	before := pos.Board().SquareMap()
+	sensed := occupancyOf(before)
+	touched := map[chess.Square]bool{}
+
+	for _, ev := range events {
+		touched[ev.Square] = true
+
+		if ev.Action == LIFT_PIECE {
+			sensed &^= 1 << uint(ev.Square)
+		} else {
+			sensed |= 1 << uint(ev.Square)
+		}
+	}
+
+	// Every move empties the square it leaves, so nothing has been played while the occupancy is unchanged
+	if sensed == occupancyOf(before) {
+		return Inference{Status: NO_CHANGE}
+	}
+
+	candidates := []*chess.Move{}
+	moves := pos.ValidMoves()
+	afterBoards := []*chess.Board{}
+
+	for _, m := range moves {
+		afterBoards = append(afterBoards, pos.Update(m).Board())
+	}
+
+	for _, c := range castlings {
+		moves = append(moves, c.Move)
+		afterBoards = append(afterBoards, c.after(pos.Board()))
+	}
+
+	for i, m := range moves {
+		after := afterBoards[i].SquareMap()
+
+		if occupancyOf(after) != sensed {
+			continue
+		}
+
+		matches := true
+
+		for i := 0; i < 64; i++ {
+			sq := chess.Square(i)
+
+			if before[sq] != after[sq] && !touched[sq] {
+				matches = false
+				break
+			}
+		}
+
+		if matches && (promotion == chess.NoPieceType || m.Promo() == promotion) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return Inference{Status: MOVE_READY, Move: chess.UCINotation{}.Encode(pos, candidates[0])}
+	case len(candidates) > 1 && candidates[0].Promo() != chess.NoPieceType:
+		inference := Inference{Status: NEEDS_PROMOTION, Candidates: []string{}}
+
+		for _, m := range candidates {
+			inference.Candidates = append(inference.Candidates, chess.UCINotation{}.Encode(pos, m))
+		}
+
+		return inference
+	default:
+		return Inference{Status: IN_PROGRESS}
+	}
+}
+
+func occupancyOf(squares map[chess.Square]chess.Piece) uint64 {
+	var occupancy uint64
+
+	for sq, p := range squares {
+		if p != chess.NoPiece {
+			occupancy |= 1 << uint(sq)
+		}
+	}
+
+	return occupancy
+}
@@ -0,0 +1,101 @@
+package games
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/notnil/chess"
+
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+)
+
+func lifted(sq chess.Square) SensorEvent {
+	return SensorEvent{Action: LIFT_PIECE, Square: sq}
+}
+
+func placed(sq chess.Square) SensorEvent {
+	return SensorEvent{Action: PLACE_PIECE, Square: sq}
+}
+
+// A game between two unstored boards, from fen after the given moves
+func testGame(t *testing.T, fen string, moves ...string) *ChessGame {
+	t.Helper()
+
+	var s GameService
+	cg := s.newChessGame(0, Chessboard{}, Chessboard{}, NO_OUTCOME, NO_METHOD, NO_METHOD, PLAYER_WHITE, MakeGameOptionsDefault().WithStartFen(fen))
+
+	for _, m := range moves {
+		if _, err := cg.applyMove(m); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+
+	return cg
+}
+
+func TestMatchSensorEvents(t *testing.T) {
+	italian := []string{"e2e4", "e7e5", "g1f3", "b8c6", "f1c4", "g8f6"}
+	chess960 := "nrkbbqrn/pppppppp/8/8/8/8/PPPPPPPP/NRKBBQRN w GBgb - 0 1"
+
+	tests := []struct {
+		name      string
+		fen       string
+		moves     []string
+		events    []SensorEvent
+		promotion chess.PieceType
+		inference Inference
+	}{
+		{"nothing lifted", "", nil, nil, chess.NoPieceType, Inference{Status: NO_CHANGE}},
+		{"piece put back", "", nil, []SensorEvent{lifted(chess.E2), placed(chess.E2)}, chess.NoPieceType, Inference{Status: NO_CHANGE}},
+		{"piece in the air", "", nil, []SensorEvent{lifted(chess.E2)}, chess.NoPieceType, Inference{Status: IN_PROGRESS}},
+		{"pawn push", "", nil, []SensorEvent{lifted(chess.E2), placed(chess.E4)}, chess.NoPieceType, Inference{Status: MOVE_READY, Move: "e2e4"}},
+		{"capture", "", []string{"e2e4", "d7d5"}, []SensorEvent{lifted(chess.D5), lifted(chess.E4), placed(chess.D5)}, chess.NoPieceType,
+			Inference{Status: MOVE_READY, Move: "e4d5"}},
+		{"castling king first", "", italian, []SensorEvent{lifted(chess.E1), placed(chess.G1), lifted(chess.H1), placed(chess.F1)}, chess.NoPieceType,
+			Inference{Status: MOVE_READY, Move: "e1g1"}},
+		{"castling rook first", "", italian, []SensorEvent{lifted(chess.H1), placed(chess.F1), lifted(chess.E1), placed(chess.G1)}, chess.NoPieceType,
+			Inference{Status: MOVE_READY, Move: "e1g1"}},
+		{"king half way through castling", "", italian, []SensorEvent{lifted(chess.E1), placed(chess.G1)}, chess.NoPieceType,
+			Inference{Status: IN_PROGRESS}},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", nil, []SensorEvent{lifted(chess.E5), placed(chess.D6), lifted(chess.D5)}, chess.NoPieceType,
+			Inference{Status: MOVE_READY, Move: "e5d6"}},
+		{"promotion to choose", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", nil, []SensorEvent{lifted(chess.A7), placed(chess.A8)}, chess.NoPieceType,
+			Inference{Status: NEEDS_PROMOTION, Candidates: []string{"a7a8q", "a7a8r", "a7a8b", "a7a8n"}}},
+		{"promotion chosen", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", nil, []SensorEvent{lifted(chess.A7), placed(chess.A8)}, chess.Knight,
+			Inference{Status: MOVE_READY, Move: "a7a8n"}},
+		{"chess960 castling with the king staying put", chess960, []string{"a1b3", "a8b6", "e2e3", "e7e6", "d2d3", "d7d6", "d1e2", "d8e7", "e1d2", "e8d7"},
+			[]SensorEvent{lifted(chess.B1), placed(chess.D1)}, chess.NoPieceType, Inference{Status: MOVE_READY, Move: "c1b1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := testGame(t, tt.fen, tt.moves...)
+			inference := matchSensorEvents(cg.Game.Position(), cg.chess960Castlings(), tt.events, tt.promotion)
+
+			if tt.inference.Candidates != nil {
+				got := map[string]bool{}
+
+				for _, c := range inference.Candidates {
+					got[c] = true
+				}
+
+				want := map[string]bool{}
+
+				for _, c := range tt.inference.Candidates {
+					want[c] = true
+				}
+
+				if inference.Status != tt.inference.Status || !reflect.DeepEqual(got, want) {
+					t.Fatalf("got %+v, want %+v", inference, tt.inference)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(inference, tt.inference) {
+				t.Fatalf("got %+v, want %+v", inference, tt.inference)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package games
+
+import (
+	"context"
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+// Whether the player to move still has to reproduce the opponent's last move on their physical board.
+// The computer has no board to update.
+func (cg *ChessGame) AwaitingMirror() bool {
+	plies := len(cg.Moves())
+
+	return plies > 0 && cg.MirroredPly < plies && !cg.GetCurrentMover().IsBot()
+}
+
+// The steps that reproduce the last move on the other player's board, in the order they should be carried out
+func (cg *ChessGame) MirrorInstructions() []BoardInstruction {
+	instructions := []BoardInstruction{}
+	plies := len(cg.Moves())
+
+	if plies == 0 {
+		return instructions
+	}
+
+	m := cg.Moves()[plies-1]
+	before := cg.Positions()[plies-1].Board()
+	piece := m.PieceMoved()
+
+	// The king comes off while the rook moves, as either may be going to where the other one stands
+	if c, ok := chess960CastlingOf(before, m); ok {
+		if c.KingFrom != c.KingTo {
+			instructions = append(instructions, BoardInstruction{Action: LIFT_PIECE, Piece: piece, From: c.KingFrom, To: chess.NoSquare})
+		}
+
+		if c.RookFrom != c.RookTo {
+			instructions = append(instructions, BoardInstruction{Action: MOVE_PIECE, Piece: before.Piece(c.RookFrom), From: c.RookFrom, To: c.RookTo})
+		}
+
+		if c.KingFrom != c.KingTo {
+			instructions = append(instructions, BoardInstruction{Action: PLACE_PIECE, Piece: piece, From: chess.NoSquare, To: c.KingTo})
+		}
+
+		return instructions
+	}
+
+	// Captured pieces come off first so the square is free to move onto
+	if m.HasTag(chess.EnPassant) {
+		captured := chess.NewSquare(m.S2().File(), m.S1().Rank())
+		instructions = append(instructions, BoardInstruction{Action: LIFT_PIECE, Piece: before.Piece(captured), From: captured, To: chess.NoSquare})
+	} else if m.HasTag(chess.Capture) {
+		instructions = append(instructions, BoardInstruction{Action: LIFT_PIECE, Piece: before.Piece(m.S2()), From: m.S2(), To: chess.NoSquare})
+	}
+
+	instructions = append(instructions, BoardInstruction{Action: MOVE_PIECE, Piece: piece, From: m.S1(), To: m.S2()})
+
+	if m.HasTag(chess.KingSideCastle) || m.HasTag(chess.QueenSideCastle) {
+		rank := m.S1().Rank()
+		rookFrom, rookTo := chess.NewSquare(chess.FileH, rank), chess.NewSquare(chess.FileF, rank)
+
+		if m.HasTag(chess.QueenSideCastle) {
+			rookFrom, rookTo = chess.NewSquare(chess.FileA, rank), chess.NewSquare(chess.FileD, rank)
+		}
+
+		instructions = append(instructions, BoardInstruction{Action: MOVE_PIECE, Piece: before.Piece(rookFrom), From: rookFrom, To: rookTo})
+	}
+
+	if m.Promo() != chess.NoPieceType {
+		instructions = append(instructions,
+			BoardInstruction{Action: LIFT_PIECE, Piece: piece, From: m.S2(), To: chess.NoSquare},
+			BoardInstruction{Action: PLACE_PIECE, Piece: chess.NewPiece(m.Promo(), piece.Color()), From: chess.NoSquare, To: m.S2()})
+	}
+
+	return instructions
+}
+
+// Acknowledge that the opponent's last move has been reproduced on the board, which lets its player move
+func (cg *ChessGame) ConfirmMirrored(chessboard Chessboard) error {
+	if cg.Archived {
+		return newArchivedError()
+	}
+
+	if cg.GetCurrentMover().OnboardId != chessboard.OnboardId {
+		return sv.NewGenericError("Only the board of the player to move mirrors the last move", 409, sv.NOT_SENSITIVE)
+	}
+
+	if !cg.AwaitingMirror() {
+		return sv.NewGenericError("There is no move to mirror", 409, sv.NOT_SENSITIVE)
+	}
+
+	plies := len(cg.Moves())
+
+	if err := cg.svc.games.ConfirmMirrored(context.Background(), cg.Id, plies); err != nil {
+		return err
+	}
+
+	cg.MirroredPly = plies
+	return nil
+}
+
+func newAwaitingMirrorError() error {
+	return sv.NewGenericError("Reproduce the opponent's move on your board and confirm it first", 409, sv.NOT_SENSITIVE)
+}
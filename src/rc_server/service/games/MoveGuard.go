@@ -0,0 +1,23 @@
+package games
+
+import (
+	"database/sql"
+	"time"
+)
+
+// How long a board can retry a move request under the same idempotency key
+const IDEMPOTENCY_KEY_TTL = 24 * time.Hour
+
+const MAX_IDEMPOTENCY_KEY_LENGTH = 255
+
+// What a board sends along with a move so it is neither played on a position the board has not seen
+// nor played twice when the board retries a request it got no answer to.
+//
+// Version is only compared with the version the game was fetched at. What keeps two moves from being played
+// on the same position is that the game is then stored with an update conditional on that fetched version,
+// so a move without a Version is still refused if another got in first, it just cannot tell the board had
+// not seen that other move. Servers whose boards all send it can refuse moves without one (moves.require_version).
+type MoveGuard struct {
+	Version        sql.NullInt64 // The game version the move was chosen at, unset for none
+	IdempotencyKey string        // The same for every retry of a request, empty for none
+}
@@ -0,0 +1,344 @@
+package games
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+)
+
+const pgnLineLength = 80
+
+// The tags every PGN must contain, in the order they must appear
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+type PgnTags map[string]string
+
+func (this *PgnTags) Scan(value interface{}) error {
+	*this = PgnTags{}
+
+	if value == nil {
+		return nil
+	}
+
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if err := json.Unmarshal(b, this); err != nil {
+		return sv.NewInternalError("Invalid PgnTags received: " + err.Error())
+	}
+
+	return nil
+}
+
+func (this PgnTags) Value() (driver.Value, error) {
+	if this == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(this)
+
+	if err != nil {
+		return nil, sv.NewInternalError("PgnTags " + err.Error())
+	}
+
+	return b, nil
+}
+
+// Export the game in PGN. Tags a game was imported with are kept, anything missing is filled in from this server.
+func (cg *ChessGame) ExportPGN() (string, error) {
+	tags := PgnTags{
+		"Event":       "RemoteChess game",
+		"Site":        "RemoteChess",
+		"Date":        cg.CreatedAt.Format("2006.01.02"),
+		"Round":       "-",
+		"Result":      string(cg.GetOutcome()),
+		"Termination": pgnTermination(cg.GetOutcome(), cg.GetMethod()),
+		"TimeControl": pgnTimeControl(cg.Clock.TimeControl),
+	}
+
+	var err error
+
+	if tags["White"], err = cg.svc.pgnPlayerName(cg.White); err != nil {
+		return "", err
+	}
+
+	if tags["Black"], err = cg.svc.pgnPlayerName(cg.Black); err != nil {
+		return "", err
+	}
+
+	if cg.StartFen != "" {
+		tags["SetUp"] = "1"
+		tags["FEN"] = cg.StartFen
+	}
+
+	if cg.Variant != STANDARD {
+		tags["Variant"] = variantToPgn[cg.Variant]
+	}
+
+	for k, v := range cg.PgnTags {
+		tags[k] = v
+	}
+
+	var sb strings.Builder
+
+	for _, k := range sevenTagRoster {
+		writePgnTag(&sb, k, tags[k])
+		delete(tags, k)
+	}
+
+	others := make([]string, 0, len(tags))
+
+	for k := range tags {
+		others = append(others, k)
+	}
+
+	sort.Strings(others)
+
+	for _, k := range others {
+		writePgnTag(&sb, k, tags[k])
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(cg.pgnMovetext())
+	sb.WriteString("\n")
+
+	return sb.String(), nil
+}
+
+func writePgnTag(sb *strings.Builder, key string, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+
+	fmt.Fprintf(sb, "[%s \"%s\"]\n", key, value)
+}
+
+// SAN movetext followed by the result, wrapped to the line length the PGN standard asks for
+func (cg *ChessGame) pgnMovetext() string {
+	positions := cg.Positions()
+	tokens := []string{}
+	moveNumber := 1
+
+	// The full move number is the last field of a FEN
+	if fields := strings.Fields(cg.StartFen); len(fields) == 6 {
+		if n, err := strconv.Atoi(fields[5]); err == nil && n > 0 {
+			moveNumber = n
+		}
+	}
+
+	for i, m := range cg.Moves() {
+		pos := positions[i]
+
+		if pos.Turn() == chess.White {
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+
+		if c, ok := chess960CastlingOf(pos.Board(), m); ok {
+			tokens = append(tokens, chess960CastlingSan(c, positions[i+1]))
+		} else {
+			tokens = append(tokens, chess.AlgebraicNotation{}.Encode(pos, m))
+		}
+
+		if pos.Turn() == chess.Black {
+			moveNumber++
+		}
+	}
+
+	tokens = append(tokens, string(cg.GetOutcome()))
+
+	var sb strings.Builder
+	lineLength := 0
+
+	for i, token := range tokens {
+		if i > 0 {
+			if lineLength+1+len(token) > pgnLineLength {
+				sb.WriteString("\n")
+				lineLength = 0
+			} else {
+				sb.WriteString(" ")
+				lineLength++
+			}
+		}
+
+		sb.WriteString(token)
+		lineLength += len(token)
+	}
+
+	return sb.String()
+}
+
+func (s *GameService) pgnPlayerName(board Chessboard) (string, error) {
+	if !board.OwnerId.Valid {
+		return "?", nil
+	}
+
+	owner, err := s.users.FetchUserCore(uint64(board.OwnerId.Int64))
+
+	if err != nil {
+		return "", err
+	}
+
+	return owner.Username, nil
+}
+
+func pgnTermination(outcome GameOutcome, method GameMethod) string {
+	switch {
+	case outcome == NO_OUTCOME:
+		return "unterminated"
+	case method == TIMEOUT || method == TIMEOUT_VS_INSUFFICIENT_MATERIAL:
+		return "time forfeit"
+	default:
+		return "normal"
+	}
+}
+
+func pgnTimeControl(tc TimeControl) string {
+	base := int64(tc.Base.Seconds())
+	increment := int64(tc.Increment.Seconds())
+
+	switch tc.Kind {
+	case FISCHER:
+		return fmt.Sprintf("%d+%d", base, increment)
+	case BRONSTEIN, SIMPLE_DELAY:
+		// PGN has no notation for delays
+		return fmt.Sprint(base)
+	case CORRESPONDENCE:
+		return fmt.Sprintf("1/%d", int64(tc.perMove().Seconds()))
+	default:
+		return "-"
+	}
+}
+
+// Create a finished, archived game from a PGN played elsewhere. The uploading board is recorded as
+// the player of the given color; the opponent is only known by the name in the PGN's tags.
+func (s *GameService) ImportPGN(uploader Chessboard, color PlayerColor, pgn io.Reader) (*ChessGame, error) {
+	pgnOption, err := chess.PGN(pgn)
+
+	if err != nil {
+		return nil, sv.NewInvalidInputError("PGN")
+	}
+
+	imported := chess.NewGame(pgnOption)
+	tags := PgnTags{}
+
+	for _, tp := range imported.TagPairs() {
+		tags[tp.Key] = tp.Value
+	}
+
+	if variant, ok := tags["Variant"]; ok && !strings.EqualFold(variant, variantToPgn[STANDARD]) {
+		return nil, sv.NewGenericError("Only standard chess games can be imported", 400, sv.NOT_SENSITIVE)
+	}
+
+	startFen := ""
+
+	if tags["FEN"] != "" {
+		if startFen, err = NormalizeStartFen(tags["FEN"]); err != nil {
+			return nil, err
+		}
+	}
+
+	outcome, method := importedResult(imported, tags)
+
+	if outcome == NO_OUTCOME {
+		return nil, sv.NewGenericError("Only finished games can be imported", 400, sv.NOT_SENSITIVE)
+	}
+
+	var white, black Chessboard
+
+	if color == PLAYER_WHITE {
+		white = uploader
+	} else {
+		black = uploader
+	}
+
+	cg := s.newChessGame(0, white, black, outcome, method, NO_METHOD, PLAYER_WHITE, MakeGameOptionsProvidedMoves(imported.Moves()).WithStartFen(startFen))
+	cg.Archived = true
+	cg.PgnTags = tags
+	cg.Clock = NewGameClock(TimeControl{Kind: UNTIMED}, cg.CreatedAt)
+
+	cgp := cg.persistent()
+	cgp.Outcome, cgp.Method = outcome, method
+
+	err = sv.WithinTx(context.Background(), s.tx, func(ctx context.Context) error {
+		if err := s.games.Import(ctx, &cgp); err != nil {
+			return err
+		}
+
+		positions := imported.Positions()
+
+		for i, move := range imported.Moves() {
+			player := PLAYER_WHITE
+
+			if positions[i].Turn() == chess.Black {
+				player = PLAYER_BLACK
+			}
+
+			if err := s.moves.Create(ctx, cgp.Id, newMoveRecord(player, move)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	cg.Id = cgp.Id
+	cg.CreatedAt = cgp.CreatedAt
+
+	return cg, nil
+}
+
+// Work out how an imported game ended. PGN only records the result, so anything
+// that cannot be read off the final position is inferred from the Termination tag.
+func importedResult(imported *chess.Game, tags PgnTags) (GameOutcome, GameMethod) {
+	outcome := GameOutcome(imported.Outcome())
+
+	if outcome == NO_OUTCOME {
+		outcome = GameOutcome(chess.Outcome(tags["Result"]))
+
+		if _, ok := outcomeToStr[outcome]; !ok {
+			outcome = NO_OUTCOME
+		}
+	}
+
+	if imported.Method() != chess.NoMethod {
+		return outcome, GameMethod(imported.Method())
+	}
+
+	if status := imported.Position().Status(); status == chess.Checkmate || status == chess.Stalemate {
+		return outcome, GameMethod(status)
+	}
+
+	onTime := strings.Contains(strings.ToLower(tags["Termination"]), "time")
+
+	switch {
+	case outcome == NO_OUTCOME:
+		return outcome, NO_METHOD
+	case outcome == DRAW && onTime:
+		return outcome, TIMEOUT_VS_INSUFFICIENT_MATERIAL
+	case outcome == DRAW:
+		return outcome, DRAW_OFFER
+	case onTime:
+		return outcome, TIMEOUT
+	default:
+		return outcome, RESIGNATION
+	}
+}
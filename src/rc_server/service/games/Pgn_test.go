@@ -0,0 +1,110 @@
+package games_test
+
+import (
+	"strings"
+	"testing"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+	"remotechess/src/rc_server/service/usercore"
+	"remotechess/src/rc_server/storage/memory"
+)
+
+// A game service on the in-memory repositories with one registered board
+func newPgnTestService(t *testing.T) (*GameService, Chessboard) {
+	t.Helper()
+
+	repos := memory.NewRepositories()
+	boards := NewBoardService(repos.Chessboards)
+	users := usercore.NewUserService(repos.Users, repos.Friends, repos.Ratings, repos.Notifications)
+	s := NewGameService(repos.Transactor, repos.Games, repos.Moves, repos.Chat, boards, users, events.NewEventService(repos.Events), nil, nil)
+
+	board, _, err := boards.RegisterNewChessboard(7)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, board
+}
+
+func TestPgnRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     string
+		movetext string
+	}{
+		{"checkmate", "", "1. e4 e5 2. Bc4 Nc6 3. Qh5 Nf6 4. Qxf7# 1-0"},
+		{"castling and en passant", `[Termination "normal"]`,
+			"1. e4 Nf6 2. e5 d5 3. exd6 Qxd6 4. Nf3 Bg4 5. Be2 Nc6 6. O-O O-O-O 1/2-1/2"},
+		{"promotion from a position", `[SetUp "1"]
+[FEN "4k3/P7/8/8/8/8/8/4K3 w - - 0 1"]`, "1. a8=Q+ Kd7 2. Qb7+ Ke6 3. Qc6+ Ke5 1/2-1/2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, board := newPgnTestService(t)
+
+			imported, err := s.ImportPGN(board, PLAYER_WHITE, strings.NewReader(`[White "Morphy"]
+[Black "Duke Karl"]
+`+tt.tags+"\n\n"+tt.movetext+"\n"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cg, err := s.FetchChessGame(imported.Id)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pgn, err := cg.ExportPGN()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parts := strings.SplitN(pgn, "\n\n", 2)
+
+			if len(parts) != 2 || strings.Join(strings.Fields(parts[1]), " ") != tt.movetext {
+				t.Fatalf("exported %q", pgn)
+			}
+
+			for _, tag := range []string{`[White "Morphy"]`, `[Black "Duke Karl"]`} {
+				if !strings.Contains(parts[0], tag) {
+					t.Fatalf("tag %s missing from %q", tag, parts[0])
+				}
+			}
+
+			if !cg.Archived || cg.White.OnboardId != board.OnboardId {
+				t.Fatalf("imported game: archived %t, white %d", cg.Archived, cg.White.OnboardId)
+			}
+		})
+	}
+}
+
+func TestImportPgnRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		pgn  string
+	}{
+		{"unfinished", "1. e4 e5 *"},
+		{"another variant", "[Variant \"Chess960\"]\n\n1. e4 e5 1-0"},
+		{"illegal start position", "[SetUp \"1\"]\n[FEN \"4k3/8/8/8/8/8/8/3KK3 w - - 0 1\"]\n\n1. Kc2 Kd7 0-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, board := newPgnTestService(t)
+			_, err := s.ImportPGN(board, PLAYER_BLACK, strings.NewReader(tt.pgn))
+
+			if serviceError, ok := err.(*sv.ServiceError); !ok || serviceError.HttpCodeHint != 400 {
+				t.Fatalf("got error %v, want a 400", err)
+			}
+		})
+	}
+}
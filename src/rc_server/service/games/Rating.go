@@ -0,0 +1,69 @@
+package games
+
+import (
+	"context"
+	"fmt"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+// Rated games are standard chess played between the owners of two different boards from the standard position
+func checkRatable(white *Chessboard, black *Chessboard, settings GameSettings) error {
+	if white.IsBot() || black.IsBot() {
+		return sv.NewGenericError("Games against the computer cannot be rated", 400, sv.NOT_SENSITIVE)
+	}
+
+	if !white.OwnerId.Valid || !black.OwnerId.Valid {
+		return sv.NewGenericError("Rated games can only be played on boards with owners", 400, sv.NOT_SENSITIVE)
+	}
+
+	if white.OwnerId.Int64 == black.OwnerId.Int64 {
+		return sv.NewGenericError("Rated games need two different players", 400, sv.NOT_SENSITIVE)
+	}
+
+	if settings.StartFen != "" {
+		return sv.NewGenericError("Rated games cannot start from a custom position", 400, sv.NOT_SENSITIVE)
+	}
+
+	// Ratings are kept in one pool per time control, which only makes sense for standard chess
+	if settings.Variant != STANDARD {
+		return sv.NewGenericError("Only standard chess games can be rated", 400, sv.NOT_SENSITIVE)
+	}
+
+	return nil
+}
+
+// The game has already been stored by the time it is rated, so a failure here is logged rather than returned
+func (cg *ChessGame) rateFinishedGame() {
+	if err := cg.applyRatings(); err != nil {
+		cg.svc.log.Error("rating game " + fmt.Sprint(cg.Id) + ": " + err.Error())
+	}
+}
+
+// Update both players' ratings once a rated game is over. Safe to call more than once,
+// the game is marked as rated in the same transaction so only the first call counts.
+func (cg *ChessGame) applyRatings() error {
+	if !cg.Rated || cg.GetOutcome() == NO_OUTCOME {
+		return nil
+	}
+
+	whiteScore := 0.5
+
+	switch cg.GetOutcome() {
+	case WHITE_WON:
+		whiteScore = 1
+	case BLACK_WON:
+		whiteScore = 0
+	}
+
+	return sv.WithinTx(context.Background(), cg.svc.tx, func(ctx context.Context) error {
+		applied, err := cg.svc.games.MarkRatingsApplied(ctx, cg.Id)
+
+		if err != nil || !applied {
+			return err
+		}
+
+		return cg.svc.users.UpdateRatings(ctx, cg.Clock.RatingPool(), cg.Id, uint64(cg.White.OwnerId.Int64), uint64(cg.Black.OwnerId.Int64), whiteScore)
+	})
+}
@@ -0,0 +1,139 @@
+package games
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/notnil/chess"
+
+	"remotechess/src/rc_server/logging"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/engine"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+// Where games are stored. Missing games are reported with a DoesNotExist error.
+type GameRepository interface {
+	Create(ctx context.Context, cgp *ChessGamePersistent) error // Sets the new game's Id
+	Import(ctx context.Context, cgp *ChessGamePersistent) error // Stores a finished, archived game and sets its Id and CreatedAt
+	Fetch(ctx context.Context, id uint64) (ChessGamePersistent, error)
+
+	// Store the position, result and clocks of the game and bump its version, but only if it is still
+	// at cgp.Version. A Conflict error otherwise.
+	Update(ctx context.Context, cgp ChessGamePersistent) error
+
+	// End an unfinished game with the given result and bump its version. False if the game was already over.
+	Adjudicate(ctx context.Context, id uint64, outcome GameOutcome, method GameMethod, whiteMs int64, blackMs int64) (bool, error)
+
+	SetDraw(ctx context.Context, id uint64, offered GameMethod, player PlayerColor) error
+	SetTakeback(ctx context.Context, id uint64, plies int, player PlayerColor) error
+	SetVisibility(ctx context.Context, id uint64, visibility GameVisibility) error
+	ConfirmMirrored(ctx context.Context, id uint64, ply int) error // Never moves the mirrored ply backwards
+	ResetMirrored(ctx context.Context, id uint64, ply int) error
+
+	// Claim the right to rate a finished rated game. Only the first call for a game returns true.
+	MarkRatingsApplied(ctx context.Context, id uint64) (bool, error)
+
+	// A page of the games played by a user or by a board, newest first, and how many match in total.
+	// Exactly one of userId and boardId is set.
+	Search(ctx context.Context, userId sql.NullInt64, boardId sql.NullInt64, filter GameFilter) ([]GameSummary, int, error)
+
+	ListOngoing(ctx context.Context, onboardId uint64) ([]uint64, error) // Oldest first
+	CountOngoing(ctx context.Context, onboardId uint64) (correspondence int, live int, err error)
+	ListOverdueCorrespondence(ctx context.Context) ([]uint64, error)
+	ListFlaggedLive(ctx context.Context) ([]uint64, error) // Unfinished live games whose player to move has run out of time
+	ListAwaitingBot(ctx context.Context) ([]uint64, error) // Unfinished games where it is the computer's turn
+
+	// Queue reminder kind for every correspondence deadline less than lead away that has not had it yet.
+	// Only the newly queued reminders are returned.
+	QueueDeadlineReminders(ctx context.Context, kind NotificationKind, lead time.Duration) ([]Notification, error)
+
+	// How many more of its recent games the board played as White than as Black
+	ColorBalance(ctx context.Context, onboardId uint64) (int, error)
+}
+
+// A move as it is stored
+type MoveRecord struct {
+	Player    PlayerColor
+	From, To  string
+	Piece     string
+	Promotion string
+	Tags      chess.MoveTag
+}
+
+type MoveRepository interface {
+	Create(ctx context.Context, gameId uint64, m MoveRecord) error
+	List(ctx context.Context, gameId uint64) ([]string, error) // UCI, in the order played
+	DeleteLast(ctx context.Context, gameId uint64) error
+
+	SaveAnalysis(ctx context.Context, gameId uint64, analysis []MoveAnalysis) error
+	FetchAnalysis(ctx context.Context, gameId uint64) ([]MoveAnalysis, error) // Nil unless every move has been analysed
+
+	// The move a board played under an idempotency key in the last ttl, DoesNotExist otherwise
+	FetchRequest(ctx context.Context, onboardId uint64, key string, ttl time.Duration) (MoveRequest, error)
+	// Remember the move played under the key, and forget the board's keys older than ttl
+	CreateRequest(ctx context.Context, onboardId uint64, key string, req MoveRequest, ttl time.Duration) error
+}
+
+// A move played under an idempotency key
+type MoveRequest struct {
+	GameId uint64
+	Move   string
+}
+
+type ChatRepository interface {
+	Create(ctx context.Context, gameId uint64, msg *ChatMessage) error // Sets the message's Id and CreatedAt
+	List(ctx context.Context, gameId uint64, viewer PlayerColor) ([]ChatMessage, error)
+	SetMuted(ctx context.Context, gameId uint64, player PlayerColor, muted bool) error
+	IsMuted(ctx context.Context, gameId uint64, player PlayerColor) (bool, error)
+}
+
+// Creates, plays and stores games. Every game fetched or created through it keeps using it.
+type GameService struct {
+	tx     sv.Transactor
+	games  GameRepository
+	moves  MoveRepository
+	chat   ChatRepository
+	boards *BoardService
+	users  *UserService
+	events *EventService
+	engine *engine.Pool
+	log    *logging.Logger
+
+	// What is going on in the games right now, none of it is stored
+	flagTimers   flagTimerSet
+	botMoves     botMoveSet
+	sensorStates sensorStateSet
+	spectators   spectatorSet
+	chatLimiter  chatAllowances
+	analysisJobs analysisJobSet
+}
+
+func NewGameService(tx sv.Transactor, games GameRepository, moves MoveRepository, chat ChatRepository,
+	boards *BoardService, users *UserService, events *EventService, pool *engine.Pool, log *logging.Logger) *GameService {
+	s := &GameService{tx: tx, games: games, moves: moves, chat: chat, boards: boards, users: users, events: events, engine: pool, log: log}
+
+	s.flagTimers.timers = map[uint64]*time.Timer{}
+	s.botMoves.thinking = map[uint64]string{}
+	s.sensorStates.games = map[uint64]*sensorState{}
+	s.spectators.games = map[uint64]map[*Spectator]struct{}{}
+	s.chatLimiter.sent = map[chatSender][]time.Time{}
+	s.analysisJobs.jobs = map[uint64]*analysisJob{}
+
+	return s
+}
+
+func newMoveRecord(player PlayerColor, move *chess.Move) MoveRecord {
+	return MoveRecord{
+		Player:    player,
+		From:      move.S1().String(),
+		To:        move.S2().String(),
+		Piece:     CPieceToString(move.PieceMoved().Type()),
+		Promotion: move.Promo().String(),
+		Tags:      move.GetTags(),
+	}
+}
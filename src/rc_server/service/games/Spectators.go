@@ -0,0 +1,185 @@
+package games
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type GameVisibility int
+
+const (
+	PUBLIC       GameVisibility = iota // Anyone can watch
+	FRIENDS_ONLY                       // Friends of either player can watch
+	PRIVATE                            // Only the players can see the game
+)
+
+// Spectators may be shown the game at most this far behind
+const MAX_BROADCAST_DELAY = 15 * time.Minute
+
+var (
+	visibilityToStr = map[GameVisibility]string{
+		PUBLIC:       "PUBLIC",
+		FRIENDS_ONLY: "FRIENDS",
+		PRIVATE:      "PRIVATE",
+	}
+
+	strToVisibility = inverseMap(visibilityToStr).(map[string]GameVisibility)
+)
+
+func (v GameVisibility) String() string {
+	return visibilityToStr[v]
+}
+
+func GameVisibilityFromString(s string) (GameVisibility, error) {
+	if visibility, ok := strToVisibility[strings.ToUpper(s)]; ok {
+		return visibility, nil
+	}
+
+	return PUBLIC, sv.NewInvalidInputError("Visibility " + s)
+}
+
+func (this *GameVisibility) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToVisibility[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid GameVisibility enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this GameVisibility) Value() (driver.Value, error) {
+	if val, ok := visibilityToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown GameVisibility")
+	}
+}
+
+// Whether one of the game's boards belongs to the user
+func (cg *ChessGame) IsPlayedBy(user UserCore) bool {
+	for _, owner := range []sql.NullInt64{cg.White.OwnerId, cg.Black.OwnerId} {
+		if owner.Valid && uint64(owner.Int64) == user.Id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether a user may watch the game. viewer is nil for anonymous requests.
+func (cg *ChessGame) CanSpectate(viewer *UserCore) (bool, error) {
+	if viewer != nil && cg.IsPlayedBy(*viewer) {
+		return true, nil
+	}
+
+	switch cg.Visibility {
+	case PUBLIC:
+		return true, nil
+	case FRIENDS_ONLY:
+		if viewer == nil {
+			return false, nil
+		}
+
+		for _, owner := range []sql.NullInt64{cg.White.OwnerId, cg.Black.OwnerId} {
+			if !owner.Valid {
+				continue
+			}
+
+			friends, err := cg.svc.users.IsFriendsWith(viewer, uint64(owner.Int64))
+
+			if err != nil || friends {
+				return friends, err
+			}
+		}
+
+		return false, nil
+	default:
+		return false, nil
+	}
+}
+
+func (cg *ChessGame) SetVisibility(visibility GameVisibility) error {
+	if err := cg.svc.games.SetVisibility(context.Background(), cg.Id, visibility); err != nil {
+		return err
+	}
+
+	cg.Visibility = visibility
+
+	return nil
+}
+
+// Someone currently watching a game. Anonymous spectators have a zero UserId.
+type Spectator struct {
+	UserId   uint64
+	Username string
+	Since    time.Time
+}
+
+// Who is watching each game right now
+type spectatorSet struct {
+	sync.Mutex
+	games map[uint64]map[*Spectator]struct{}
+}
+
+// Record that someone started watching the game. Call the returned function when they stop.
+func (cg *ChessGame) AddSpectator(viewer *UserCore) func() {
+	spectator := &Spectator{Since: time.Now()}
+
+	if viewer != nil {
+		spectator.UserId = viewer.Id
+		spectator.Username = viewer.Username
+	}
+
+	id := cg.Id
+
+	cg.svc.spectators.Lock()
+	defer cg.svc.spectators.Unlock()
+
+	if cg.svc.spectators.games[id] == nil {
+		cg.svc.spectators.games[id] = map[*Spectator]struct{}{}
+	}
+
+	cg.svc.spectators.games[id][spectator] = struct{}{}
+
+	return func() {
+		cg.svc.spectators.Lock()
+		defer cg.svc.spectators.Unlock()
+
+		delete(cg.svc.spectators.games[id], spectator)
+
+		if len(cg.svc.spectators.games[id]) == 0 {
+			delete(cg.svc.spectators.games, id)
+		}
+	}
+}
+
+// Everyone watching the game right now, longest watching first
+func (cg *ChessGame) FetchSpectators() []Spectator {
+	cg.svc.spectators.Lock()
+	defer cg.svc.spectators.Unlock()
+
+	list := []Spectator{}
+
+	for s := range cg.svc.spectators.games[cg.Id] {
+		list = append(list, *s)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Since.Before(list[j].Since) })
+
+	return list
+}
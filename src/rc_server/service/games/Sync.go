@@ -0,0 +1,273 @@
+package games
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+// Something a player does with a piece on a physical board
+type BoardAction string
+
+const (
+	LIFT_PIECE  BoardAction = "LIFT"
+	PLACE_PIECE BoardAction = "PLACE"
+	MOVE_PIECE  BoardAction = "MOVE"
+)
+
+// What a physical board can tell about where its pieces are. Boards that can only sense whether a
+// square is occupied leave Pieces nil.
+type SensedPosition struct {
+	Occupancy uint64 // Bit n is set when square n is occupied, a1 = 0, b1 = 1, ..., h8 = 63
+	Pieces    map[chess.Square]chess.Piece
+}
+
+// One step towards making the physical board match the game. From is empty for pieces put on
+// the board and To is empty for pieces taken off it. Piece is unknown when lifting from a board
+// that only senses occupancy.
+type BoardInstruction struct {
+	Action BoardAction
+	Piece  chess.Piece
+	From   chess.Square
+	To     chess.Square
+}
+
+type SyncReport struct {
+	InSync       bool
+	WrongSquares []chess.Square
+	Instructions []BoardInstruction // In the order they should be carried out
+}
+
+var pieceNames = map[chess.PieceType]string{
+	chess.King:   "king",
+	chess.Queen:  "queen",
+	chess.Rook:   "rook",
+	chess.Bishop: "bishop",
+	chess.Knight: "knight",
+	chess.Pawn:   "pawn",
+}
+
+var fenPieces = map[rune]chess.Piece{
+	'K': chess.WhiteKing, 'Q': chess.WhiteQueen, 'R': chess.WhiteRook, 'B': chess.WhiteBishop, 'N': chess.WhiteKnight, 'P': chess.WhitePawn,
+	'k': chess.BlackKing, 'q': chess.BlackQueen, 'r': chess.BlackRook, 'b': chess.BlackBishop, 'n': chess.BlackKnight, 'p': chess.BlackPawn,
+}
+
+// Read an occupancy bitmap, either decimal or hexadecimal with a 0x prefix
+func ParseOccupancy(s string) (SensedPosition, error) {
+	occupancy, err := strconv.ParseUint(s, 0, 64)
+
+	if err != nil {
+		return SensedPosition{}, sv.NewInvalidInputError("Occupancy")
+	}
+
+	return SensedPosition{Occupancy: occupancy}, nil
+}
+
+// Read the piece placement field of a FEN, e.g. rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR.
+// Any number of kings is accepted, a knocked over king is exactly what needs reporting.
+func ParsePlacement(s string) (SensedPosition, error) {
+	sensed := SensedPosition{Pieces: map[chess.Square]chess.Piece{}}
+	ranks := strings.Split(strings.Fields(s + " ")[0], "/")
+
+	if len(ranks) != 8 {
+		return sensed, sv.NewInvalidInputError("Placement")
+	}
+
+	for i, rank := range ranks {
+		file := 0
+
+		for _, c := range rank {
+			if c >= '1' && c <= '8' {
+				file += int(c - '0')
+				continue
+			}
+
+			piece, ok := fenPieces[c]
+
+			if !ok || file > 7 {
+				return sensed, sv.NewInvalidInputError("Placement")
+			}
+
+			sq := chess.NewSquare(chess.File(file), chess.Rank(7-i))
+			sensed.Pieces[sq] = piece
+			sensed.Occupancy |= 1 << uint(sq)
+			file++
+		}
+
+		if file != 8 {
+			return sensed, sv.NewInvalidInputError("Placement")
+		}
+	}
+
+	return sensed, nil
+}
+
+// Compare what the board senses with the game's position and work out how to fix the board.
+// Only the players' boards are told the position, the instructions would give it away to anyone else.
+func (cg *ChessGame) SyncPosition(viewer Chessboard, sensed SensedPosition) (SyncReport, error) {
+	if _, err := cg.GetColorOfBoard(viewer); err != nil {
+		return SyncReport{}, sv.NewGenericError("You are not a player in this game", 403, sv.NOT_SENSITIVE)
+	}
+
+	return DiffPosition(cg.Game.Position().Board(), sensed), nil
+}
+
+// Pieces on the wrong square are moved where a piece of the same kind is missing, whatever cannot
+// be paired up that way is lifted off or placed on the board. Lifts come first so every square
+// a piece is moved or placed onto is already free.
+func DiffPosition(expected *chess.Board, sensed SensedPosition) SyncReport {
+	report := SyncReport{WrongSquares: []chess.Square{}, Instructions: []BoardInstruction{}}
+	expectedPieces := expected.SquareMap()
+
+	// Squares holding a piece that has to go, and squares missing the piece they should hold
+	extra := []chess.Square{}
+	missing := []chess.Square{}
+
+	for i := 0; i < 64; i++ {
+		sq := chess.Square(i)
+		want, wantOk := expectedPieces[sq]
+		wantOk = wantOk && want != chess.NoPiece
+		occupied := sensed.Occupancy&(1<<uint(i)) != 0
+
+		if sensed.Pieces == nil {
+			if occupied && !wantOk {
+				extra = append(extra, sq)
+			} else if !occupied && wantOk {
+				missing = append(missing, sq)
+			}
+
+			continue
+		}
+
+		have, haveOk := sensed.Pieces[sq]
+
+		if haveOk && wantOk && have == want {
+			continue
+		}
+
+		if haveOk {
+			extra = append(extra, sq)
+		}
+
+		if wantOk {
+			missing = append(missing, sq)
+		}
+	}
+
+	lifts := []BoardInstruction{}
+	moves := []BoardInstruction{}
+	places := []BoardInstruction{}
+
+	for _, from := range extra {
+		piece := sensed.Pieces[from]
+		paired := false
+
+		// The sensed piece is only known for boards that report full placement
+		if sensed.Pieces != nil {
+			for j, to := range missing {
+				if expectedPieces[to] == piece {
+					moves = append(moves, BoardInstruction{Action: MOVE_PIECE, Piece: piece, From: from, To: to})
+					missing = append(missing[:j], missing[j+1:]...)
+					paired = true
+					break
+				}
+			}
+		}
+
+		if !paired {
+			lifts = append(lifts, BoardInstruction{Action: LIFT_PIECE, Piece: piece, From: from, To: chess.NoSquare})
+		}
+	}
+
+	for _, to := range missing {
+		places = append(places, BoardInstruction{Action: PLACE_PIECE, Piece: expectedPieces[to], From: chess.NoSquare, To: to})
+	}
+
+	report.Instructions = append(report.Instructions, lifts...)
+	report.Instructions = append(report.Instructions, orderMoves(moves)...)
+	report.Instructions = append(report.Instructions, places...)
+
+	wrong := map[chess.Square]bool{}
+
+	for _, in := range report.Instructions {
+		if in.From != chess.NoSquare {
+			wrong[in.From] = true
+		}
+
+		if in.To != chess.NoSquare {
+			wrong[in.To] = true
+		}
+	}
+
+	for sq := range wrong {
+		report.WrongSquares = append(report.WrongSquares, sq)
+	}
+
+	sort.Slice(report.WrongSquares, func(i, j int) bool { return report.WrongSquares[i] < report.WrongSquares[j] })
+	report.InSync = len(report.Instructions) == 0
+
+	return report
+}
+
+// Moves onto a square that another move still has to clear wait until that move is done.
+// Swaps and longer cycles cannot be ordered that way, one of their pieces is lifted off and put back last.
+func orderMoves(moves []BoardInstruction) []BoardInstruction {
+	ordered := []BoardInstruction{}
+	putBack := []BoardInstruction{}
+
+	for len(moves) > 0 {
+		progress := false
+
+		for i := 0; i < len(moves); i++ {
+			blocked := false
+
+			for j, other := range moves {
+				if j != i && other.From == moves[i].To {
+					blocked = true
+					break
+				}
+			}
+
+			if !blocked {
+				ordered = append(ordered, moves[i])
+				moves = append(moves[:i], moves[i+1:]...)
+				i--
+				progress = true
+			}
+		}
+
+		if !progress {
+			m := moves[0]
+			moves = moves[1:]
+			ordered = append(ordered, BoardInstruction{Action: LIFT_PIECE, Piece: m.Piece, From: m.From, To: chess.NoSquare})
+			putBack = append(putBack, BoardInstruction{Action: PLACE_PIECE, Piece: m.Piece, From: chess.NoSquare, To: m.To})
+		}
+	}
+
+	return append(ordered, putBack...)
+}
+
+// A human readable version of the instruction, for boards with a display
+func (in BoardInstruction) String() string {
+	switch in.Action {
+	case LIFT_PIECE:
+		if in.Piece == chess.NoPiece {
+			return "Lift the piece from " + in.From.String()
+		}
+
+		return "Lift the " + pieceName(in.Piece) + " from " + in.From.String()
+	case PLACE_PIECE:
+		return "Place a " + pieceName(in.Piece) + " on " + in.To.String()
+	default:
+		return "Move the " + pieceName(in.Piece) + " from " + in.From.String() + " to " + in.To.String()
+	}
+}
+
+func pieceName(p chess.Piece) string {
+	return strings.ToLower(p.Color().Name()) + " " + pieceNames[p.Type()]
+}
@@ -0,0 +1,98 @@
+package games
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func lift(p chess.Piece, from chess.Square) BoardInstruction {
+	return BoardInstruction{Action: LIFT_PIECE, Piece: p, From: from, To: chess.NoSquare}
+}
+
+func place(p chess.Piece, to chess.Square) BoardInstruction {
+	return BoardInstruction{Action: PLACE_PIECE, Piece: p, From: chess.NoSquare, To: to}
+}
+
+func move(p chess.Piece, from chess.Square, to chess.Square) BoardInstruction {
+	return BoardInstruction{Action: MOVE_PIECE, Piece: p, From: from, To: to}
+}
+
+func TestDiffPosition(t *testing.T) {
+	start := chess.NewGame().Position().Board()
+
+	tests := []struct {
+		name         string
+		placement    string // Empty to sense occupancy only, from occupancy
+		occupancy    uint64
+		instructions []BoardInstruction
+	}{
+		{"in sync", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", 0, []BoardInstruction{}},
+		{"pawn pushed", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR", 0,
+			[]BoardInstruction{move(chess.WhitePawn, chess.E4, chess.E2)}},
+		{"extra and missing pieces", "rnbqkbnr/pppppppp/8/8/8/2Q5/PPPPPPPP/RNB1KBNR", 0,
+			[]BoardInstruction{move(chess.WhiteQueen, chess.C3, chess.D1)}},
+		{"piece knocked off", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKB1R", 0,
+			[]BoardInstruction{place(chess.WhiteKnight, chess.G1)}},
+		{"wrong kind of piece", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBBR", 0,
+			[]BoardInstruction{lift(chess.WhiteBishop, chess.G1), place(chess.WhiteKnight, chess.G1)}},
+		{"knight and bishop swapped", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKNBR", 0,
+			[]BoardInstruction{lift(chess.WhiteKnight, chess.F1), move(chess.WhiteBishop, chess.G1, chess.F1), place(chess.WhiteKnight, chess.G1)}},
+		{"occupancy only", "", 0xFFFF00001000EFFF,
+			[]BoardInstruction{lift(chess.NoPiece, chess.E4), place(chess.WhitePawn, chess.E2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensed := SensedPosition{Occupancy: tt.occupancy}
+
+			if tt.placement != "" {
+				var err error
+
+				if sensed, err = ParsePlacement(tt.placement); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report := DiffPosition(start, sensed)
+
+			if !reflect.DeepEqual(report.Instructions, tt.instructions) {
+				t.Fatalf("got %v, want %v", report.Instructions, tt.instructions)
+			}
+
+			if report.InSync != (len(tt.instructions) == 0) {
+				t.Fatalf("in sync: %t", report.InSync)
+			}
+		})
+	}
+}
+
+func TestOrderMoves(t *testing.T) {
+	n, b, r := chess.WhiteKnight, chess.WhiteBishop, chess.WhiteRook
+
+	tests := []struct {
+		name    string
+		moves   []BoardInstruction
+		ordered []BoardInstruction
+	}{
+		{"independent", []BoardInstruction{move(n, chess.B1, chess.C3), move(b, chess.F1, chess.C4)},
+			[]BoardInstruction{move(n, chess.B1, chess.C3), move(b, chess.F1, chess.C4)}},
+		{"chain waits for the square to clear", []BoardInstruction{move(n, chess.B1, chess.C3), move(b, chess.C3, chess.E5)},
+			[]BoardInstruction{move(b, chess.C3, chess.E5), move(n, chess.B1, chess.C3)}},
+		{"swap", []BoardInstruction{move(n, chess.B1, chess.C1), move(b, chess.C1, chess.B1)},
+			[]BoardInstruction{lift(n, chess.B1), move(b, chess.C1, chess.B1), place(n, chess.C1)}},
+		{"three-cycle", []BoardInstruction{move(n, chess.A1, chess.B1), move(b, chess.B1, chess.C1), move(r, chess.C1, chess.A1)},
+			[]BoardInstruction{lift(n, chess.A1), move(r, chess.C1, chess.A1), move(b, chess.B1, chess.C1), place(n, chess.B1)}},
+		{"two swaps", []BoardInstruction{move(n, chess.A1, chess.B1), move(b, chess.B1, chess.A1), move(r, chess.G1, chess.H1), move(n, chess.H1, chess.G1)},
+			[]BoardInstruction{lift(n, chess.A1), move(b, chess.B1, chess.A1), lift(r, chess.G1), move(n, chess.H1, chess.G1), place(n, chess.B1), place(r, chess.H1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ordered := orderMoves(tt.moves); !reflect.DeepEqual(ordered, tt.ordered) {
+				t.Fatalf("got %v, want %v", ordered, tt.ordered)
+			}
+		})
+	}
+}
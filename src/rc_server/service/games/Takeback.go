@@ -0,0 +1,111 @@
+package games
+
+import (
+	"context"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+)
+
+// A player can ask to take back their last move, or their last move and the opponent's reply to it
+const MAX_TAKEBACK_PLIES = 2
+
+// Ask the opponent to take back the given number of moves. The first of them must have been played by the board asking.
+func (cg *ChessGame) RequestTakeback(chessboard Chessboard, plies int) error {
+	player, err := cg.checkTakebackAllowed(chessboard)
+
+	if err != nil {
+		return err
+	}
+
+	if cg.TakebackPlies != 0 {
+		return sv.NewGenericError("A takeback has already been requested", 409, sv.NOT_SENSITIVE)
+	}
+
+	if plies < 1 || plies > MAX_TAKEBACK_PLIES {
+		return sv.NewInvalidInputError("Plies")
+	}
+
+	moves := len(cg.Moves())
+
+	if moves < plies || cg.Positions()[moves-plies].Turn() != chessColor(player) {
+		return sv.NewGenericError("You can only take back your own last move", 409, sv.NOT_SENSITIVE)
+	}
+
+	return cg.setTakeback(plies, player)
+}
+
+// Take back the moves the opponent asked for and store the game. Refused with a Conflict error
+// when a move was made since the game was loaded, as the moves asked for are no longer the last ones.
+func (cg *ChessGame) AcceptTakeback(chessboard Chessboard) error {
+	if err := cg.checkTakebackResponder(chessboard); err != nil {
+		return err
+	}
+
+	return sv.WithinTx(context.Background(), cg.svc.tx, func(ctx context.Context) error {
+		if err := cg.svc.boards.LockChessboards(ctx, cg.White.OnboardId, cg.Black.OnboardId); err != nil {
+			return err
+		}
+
+		return cg.undoMoves(ctx, cg.TakebackPlies)
+	})
+}
+
+func (cg *ChessGame) DeclineTakeback(chessboard Chessboard) error {
+	if err := cg.checkTakebackResponder(chessboard); err != nil {
+		return err
+	}
+
+	return cg.setTakeback(0, PLAYER_WHITE)
+}
+
+func (cg *ChessGame) checkTakebackAllowed(chessboard Chessboard) (PlayerColor, error) {
+	if cg.Archived {
+		return PLAYER_WHITE, newArchivedError()
+	}
+
+	if cg.GetOutcome() != NO_OUTCOME {
+		return PLAYER_WHITE, sv.NewGenericError("Game is already over", 409, sv.NOT_SENSITIVE)
+	}
+
+	if !cg.Takebacks {
+		return PLAYER_WHITE, sv.NewGenericError("Takebacks are disabled in this game", 403, sv.NOT_SENSITIVE)
+	}
+
+	player, err := cg.GetColorOfBoard(chessboard)
+
+	if err != nil {
+		return PLAYER_WHITE, sv.NewGenericError("You are not a player in this game", 403, sv.NOT_SENSITIVE)
+	}
+
+	return player, nil
+}
+
+// Only the opponent of the player asking for a pending takeback may answer it
+func (cg *ChessGame) checkTakebackResponder(chessboard Chessboard) error {
+	player, err := cg.checkTakebackAllowed(chessboard)
+
+	if err != nil {
+		return err
+	}
+
+	if cg.TakebackPlies == 0 {
+		return sv.NewGenericError("There is no pending takeback for this game", 409, sv.NOT_SENSITIVE)
+	}
+
+	if player == cg.TakebackPlayer {
+		return sv.NewGenericError("You cannot answer your own takeback request", 409, sv.NOT_SENSITIVE)
+	}
+
+	return nil
+}
+
+func (cg *ChessGame) setTakeback(plies int, player PlayerColor) error {
+	if err := cg.svc.games.SetTakeback(context.Background(), cg.Id, plies, player); err != nil {
+		return err
+	}
+
+	cg.TakebackPlies = plies
+	cg.TakebackPlayer = player
+	return nil
+}
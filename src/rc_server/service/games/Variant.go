@@ -0,0 +1,131 @@
+package games
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/notnil/chess"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+)
+
+type GameVariant int
+
+const (
+	STANDARD GameVariant = iota
+	CHESS960
+	KING_OF_THE_HILL_VARIANT // Bringing your king to one of the four center squares wins
+	THREE_CHECK_VARIANT      // Giving check three times wins
+)
+
+var (
+	variantToStr = map[GameVariant]string{
+		STANDARD:                 "STANDARD",
+		CHESS960:                 "CHESS960",
+		KING_OF_THE_HILL_VARIANT: "KING_OF_THE_HILL",
+		THREE_CHECK_VARIANT:      "THREE_CHECK",
+	}
+
+	strToVariant = inverseMap(variantToStr).(map[string]GameVariant)
+
+	variantToPgn = map[GameVariant]string{
+		STANDARD:                 "Standard",
+		CHESS960:                 "Chess960",
+		KING_OF_THE_HILL_VARIANT: "King of the Hill",
+		THREE_CHECK_VARIANT:      "Three-check",
+	}
+
+	hillSquares = []chess.Square{chess.D4, chess.E4, chess.D5, chess.E5}
+)
+
+func (v GameVariant) String() string {
+	return variantToStr[v]
+}
+
+func GameVariantFromString(s string) (GameVariant, error) {
+	if variant, ok := strToVariant[strings.ToUpper(s)]; ok {
+		return variant, nil
+	}
+
+	return STANDARD, sv.NewInvalidInputError("Variant " + s)
+}
+
+func (this *GameVariant) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToVariant[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid GameVariant enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this GameVariant) Value() (driver.Value, error) {
+	if val, ok := variantToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown GameVariant")
+	}
+}
+
+// Decide the game if the move just made won it under the rules of its variant.
+// Standard chess outcomes are left to the chess engine.
+func (cg *ChessGame) checkVariantOutcome() {
+	if cg.GetOutcome() != NO_OUTCOME || len(cg.Moves()) == 0 {
+		return
+	}
+
+	turn := cg.GetTurn()
+	mover := turn.Other()
+	winner := WHITE_WON
+
+	if mover == PLAYER_BLACK {
+		winner = BLACK_WON
+	}
+
+	switch cg.Variant {
+	case KING_OF_THE_HILL_VARIANT:
+		board := cg.Game.Position().Board()
+		king := chess.NewPiece(chess.King, chessColor(mover))
+
+		for _, sq := range hillSquares {
+			if board.Piece(sq) == king {
+				cg.Adjudicate(winner, KING_OF_THE_HILL)
+				return
+			}
+		}
+	case THREE_CHECK_VARIANT:
+		whiteChecks, blackChecks := cg.CountChecks()
+
+		if whiteChecks >= 3 || blackChecks >= 3 {
+			cg.Adjudicate(winner, THREE_CHECK)
+		}
+	}
+}
+
+// How many times each player has given check so far
+func (cg *ChessGame) CountChecks() (int, int) {
+	whiteChecks, blackChecks := 0, 0
+	positions := cg.Positions()
+
+	for i, m := range cg.Moves() {
+		if !m.HasTag(chess.Check) {
+			continue
+		}
+
+		if positions[i].Turn() == chess.White {
+			whiteChecks++
+		} else {
+			blackChecks++
+		}
+	}
+
+	return whiteChecks, blackChecks
+}
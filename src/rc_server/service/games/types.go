@@ -33,6 +33,14 @@ const (
 	INSUFFICIENT_MATERIAL  GameMethod = GameMethod(chess.InsufficientMaterial)
 )
 
+// Methods the chess engine knows nothing about. Games ending this way are adjudicated by the server.
+const (
+	TIMEOUT GameMethod = GameMethod(chess.InsufficientMaterial) + 1 + iota
+	TIMEOUT_VS_INSUFFICIENT_MATERIAL
+	KING_OF_THE_HILL
+	THREE_CHECK
+)
+
 var (
 	outcomeToStr = map[GameOutcome]string{
 		NO_OUTCOME: "NONE",
@@ -53,6 +61,11 @@ var (
 		FIFTY_MOVE_RULE:        "50_MOVES",
 		SEVENTY_FIVE_MOVE_RULE: "75_MOVES",
 		INSUFFICIENT_MATERIAL:  "INSUFFICIENT_MATERIAL",
+
+		TIMEOUT:                          "TIMEOUT",
+		TIMEOUT_VS_INSUFFICIENT_MATERIAL: "TIMEOUT_VS_INSUFFICIENT_MATERIAL",
+		KING_OF_THE_HILL:                 "KING_OF_THE_HILL",
+		THREE_CHECK:                      "THREE_CHECK",
 	}
 
 	strToMethod = inverseMap(methodToStr).(map[string]GameMethod)
@@ -74,6 +87,11 @@ func GameMethodFromString(s string) GameMethod {
 	return strToMethod[s]
 }
 
+// Whether the method can only be decided by the server and not by the chess engine
+func (o GameMethod) IsAdjudicated() bool {
+	return o > INSUFFICIENT_MATERIAL
+}
+
 // Interface implementations for sql driver and GameOutcome
 func (this *GameOutcome) Scan(value interface{}) error {
 	b, ok := value.([]byte)
@@ -1,234 +1,125 @@
 package invitations
 
 import (
-	"database/sql"
-	. "remotechess/src/rc_server/rcdb/invitations"
+	"context"
+
 	sv "remotechess/src/rc_server/service"
 	. "remotechess/src/rc_server/service/chessboards"
 	. "remotechess/src/rc_server/service/common"
 	. "remotechess/src/rc_server/service/games"
 	. "remotechess/src/rc_server/service/usercore"
-
-	"github.com/lib/pq"
 )
 
-func CreateCodeInvite(cb Chessboard) (int, error) {
-	var inviteCode int
-
-	row := sv.Db.QueryRow(GetInvitationQuery(CREATE_INVITE_WITH_CODE), cb.OnboardId, PLAYER_BLACK)
-
-	if row.Err() != nil {
-		return 0, sv.NewInternalError("CreateGameInviteWithCode " + row.Err().Error())
-	}
-
-	err := row.Scan(&inviteCode)
-
-	if err != nil {
-		return 0, sv.NewInternalError("CreateGameInviteWithCode " + err.Error())
-	}
-
-	return inviteCode, nil
+func (s *InvitationService) CreateCodeInvite(cb Chessboard, settings GameSettings) (int, error) {
+	return s.invites.CreateWithCode(context.Background(), cb.OnboardId, PLAYER_BLACK, settings)
 }
 
-func JoinCodeInvite(recipient *Chessboard, inviteCode int) (*ChessGame, error) {
-	var sender Chessboard
-	var recipientColor PlayerColor
-
-	row := sv.Db.QueryRow(GetInvitationQuery(GET_CODE_INVITE_SENDER_BOARD), inviteCode)
-
-	if row.Err() != nil {
-		return nil, sv.NewInternalError("JoinCodeInvite " + row.Err().Error())
-	}
+func (s *InvitationService) JoinCodeInvite(recipient *Chessboard, inviteCode int) (*ChessGame, error) {
+	var game *ChessGame
 
-	err := row.Scan(&sender.OnboardId, &sender.OwnerId, &sender.CurGame, &recipientColor)
+	err := sv.WithinTx(context.Background(), s.tx, func(ctx context.Context) error {
+		invite, err := s.invites.FetchCodeInvite(ctx, inviteCode)
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sv.NewDoesNotExistError("Invite")
-		} else {
-			return nil, sv.NewInternalError("JoinCodeInvite " + err.Error())
+		if err != nil {
+			return err
 		}
-	}
 
-	if sender.CurGame.Valid || recipient.CurGame.Valid {
-		return nil, sv.NewGenericError("Player(s) already in game", 409, sv.NOT_SENSITIVE)
-	}
-
-	if sender.OnboardId == recipient.OnboardId {
-		return nil, sv.NewGenericError("Cannot join your own game via code", 409, sv.NOT_SENSITIVE)
-	}
-
-	var game *ChessGame
+		if invite.Sender.OnboardId == recipient.OnboardId {
+			return sv.NewGenericError("Cannot join your own game via code", 409, sv.NOT_SENSITIVE)
+		}
 
-	if recipientColor == PLAYER_WHITE {
-		game, err = CreateChessGame(recipient, &sender)
-	} else {
-		game, err = CreateChessGame(&sender, recipient)
-	}
+		if err = s.boards.LockChessboards(ctx, invite.Sender.OnboardId, recipient.OnboardId); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return game, err
-	}
+		// Whoever joined first has cleared the sender's invites by the time the lock is ours
+		if invite, err = s.invites.FetchCodeInvite(ctx, inviteCode); err != nil {
+			return err
+		}
 
-	err = ClearInvites(sender.OnboardId)
+		game, err = s.startInvitedGame(ctx, invite.Sender, recipient, invite.RecipientColor, invite.Settings)
+		return err
+	})
 
 	return game, err
 }
 
-func CancelCodeInvite(inviteCode int) error {
-	res, err := sv.Db.Exec(GetInvitationQuery(CANCEL_CODE_INVITE), inviteCode)
-
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
-
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("Invite")
-	}
-
-	return nil
+// Cancel a code invite sent from one of owner's chessboards
+func (s *InvitationService) CancelCodeInvite(owner UserCore, inviteCode int) error {
+	return s.invites.CancelCode(context.Background(), inviteCode, owner.Id)
 }
 
-func SendInvite(sender Chessboard, recipient UserCore) error {
-	res, err := sv.Db.Exec(GetInvitationQuery(SEND_INVITE), sender.OnboardId, recipient.Id, PLAYER_BLACK)
-
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return sv.NewGenericError("There is already a pending invite for that user", 409, sv.NOT_SENSITIVE)
-		} else {
-			return sv.NewInternalError(err.Error())
-		}
-	}
-
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("SendInvite did not affect 1 row")
-	}
-
-	return nil
+func (s *InvitationService) SendInvite(sender Chessboard, recipient UserCore, settings GameSettings) error {
+	return s.invites.Send(context.Background(), sender.OnboardId, recipient.Id, PLAYER_BLACK, settings)
 }
 
-func CancelInvite(sender Chessboard, recipient UserCore) error {
-	_, err := sv.Db.Exec(GetInvitationQuery(CANCEL_SENT_INVITE), sender.OnboardId, recipient.Id)
-
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
-
-	return nil
+func (s *InvitationService) CancelInvite(sender Chessboard, recipient UserCore) error {
+	return s.invites.CancelSent(context.Background(), sender.OnboardId, recipient.Id)
 }
 
-func GetPendingInvites(user UserCore) ([]PendingInvite, error) {
-	rows, err := sv.Db.Query(GetInvitationQuery(GET_PENDING_INVITES), user.Id)
-
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
-	}
-
-	invites := []PendingInvite{}
-
-	defer rows.Close()
+func (s *InvitationService) GetPendingInvites(user UserCore) ([]PendingInvite, error) {
+	return s.invites.ListPending(context.Background(), user.Id)
+}
 
-	for rows.Next() {
-		var pending PendingInvite
-		var recipientColor NullablePlayerColor
+func (s *InvitationService) AcceptInvite(recipient *Chessboard, inviteId uint64, recipientColor PlayerColor) (*ChessGame, error) {
+	var game *ChessGame
 
-		err = rows.Scan(&pending.Id, &pending.Sender.Id, &pending.Sender.Username, &recipientColor)
+	err := sv.WithinTx(context.Background(), s.tx, func(ctx context.Context) error {
+		invite, err := s.invites.FetchSent(ctx, inviteId, recipient.OnboardId, recipientColor)
 
 		if err != nil {
-			return nil, sv.NewInternalError(err.Error())
+			return err
 		}
 
-		pending.YourColor = recipientColor.ToPointer()
-		invites = append(invites, pending)
-	}
-
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
-	}
-
-	return invites, nil
-}
-
-func AcceptInvite(recipient *Chessboard, inviteId uint64, recipientColor PlayerColor) (*ChessGame, error) {
-	var sender Chessboard
-
-	row := sv.Db.QueryRow(GetInvitationQuery(GET_SENT_INVITE_SENDER_BOARD), inviteId, recipient.OnboardId, recipientColor)
-
-	if row.Err() != nil {
-		return nil, sv.NewInternalError("AcceptInvite " + row.Err().Error())
-	}
-
-	err := row.Scan(&sender.OnboardId, &sender.OwnerId, &sender.CurGame)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sv.NewDoesNotExistError("Invite")
-		} else {
-			return nil, sv.NewInternalError("AcceptInvite " + err.Error())
+		if err = s.boards.LockChessboards(ctx, invite.Sender.OnboardId, recipient.OnboardId); err != nil {
+			return err
 		}
-	}
 
-	if sender.CurGame.Valid || recipient.CurGame.Valid {
-		return nil, sv.NewGenericError("Player(s) already in game", 409, sv.NOT_SENSITIVE)
-	}
-
-	var game *ChessGame
-
-	if recipientColor == PLAYER_WHITE {
-		game, err = CreateChessGame(recipient, &sender)
-	} else {
-		game, err = CreateChessGame(&sender, recipient)
-	}
-
-	if err != nil {
-		return game, err
-	}
+		// Whoever accepted one of the sender's invites first has cleared them all by the time the lock is ours
+		if invite, err = s.invites.FetchSent(ctx, inviteId, recipient.OnboardId, recipientColor); err != nil {
+			return err
+		}
 
-	err = ClearInvites(sender.OnboardId)
+		game, err = s.startInvitedGame(ctx, invite.Sender, recipient, recipientColor, invite.Settings)
+		return err
+	})
 
 	return game, err
 }
 
-func RejectInvite(inviteId uint64) error {
-	res, err := sv.Db.Exec(GetInvitationQuery(REJECT_INVITE), inviteId)
-
-	if err != nil {
-		return sv.NewInternalError("RejectInvite " + err.Error())
-	}
+// Start the game of an accepted invite and withdraw the sender's other invites, with both boards locked
+func (s *InvitationService) startInvitedGame(ctx context.Context, sender Chessboard, recipient *Chessboard, recipientColor PlayerColor, settings GameSettings) (*ChessGame, error) {
+	white, black := &sender, recipient
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("Invite")
+	if recipientColor == PLAYER_WHITE {
+		white, black = recipient, &sender
 	}
 
-	return nil
-}
-
-func DeleteInvite(inviteId uint64) error {
-	res, err := sv.Db.Exec(GetInvitationQuery(DELETE_INVITE), inviteId)
+	game, err := s.games.CreateChessGameTx(ctx, white, black, settings)
 
 	if err != nil {
-		return sv.NewInternalError("DeleteInvite " + err.Error())
+		return nil, err
 	}
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("Invite")
-	}
-
-	return nil
+	return game, s.invites.ClearSent(ctx, sender.OnboardId)
 }
 
-func ClearInvites(senderBid uint64) error {
-	_, err := sv.Db.Exec(GetInvitationQuery(CLEAR_INVITES), senderBid)
+// Reject an invite sent to recipient
+func (s *InvitationService) RejectInvite(recipient UserCore, inviteId uint64) error {
+	return s.invites.Reject(context.Background(), inviteId, recipient.Id)
+}
 
-	if err != nil {
-		return sv.NewInternalError("ClearInvites " + err.Error())
-	}
+func (s *InvitationService) DeleteInvite(inviteId uint64) error {
+	return s.invites.Delete(context.Background(), inviteId)
+}
 
-	return nil
+func (s *InvitationService) ClearInvites(senderBid uint64) error {
+	return s.invites.ClearSent(context.Background(), senderBid)
 }
 
 type PendingInvite struct {
 	Id        uint64
 	Sender    UserCore
 	YourColor *PlayerColor
+	Settings  GameSettings
 }
@@ -0,0 +1,49 @@
+package invitations
+
+import (
+	"context"
+
+	sv "remotechess/src/rc_server/service"
+
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/games"
+)
+
+// An invite as seen by the board answering it
+type ReceivedInvite struct {
+	Sender         Chessboard
+	RecipientColor PlayerColor
+	Settings       GameSettings
+}
+
+// Where invites are stored. Invites that cannot be found, or may not be answered by the
+// given recipient, are reported with a DoesNotExist error.
+type InvitationRepository interface {
+	// Store an invite anyone can join with the returned code
+	CreateWithCode(ctx context.Context, senderId uint64, recipientColor PlayerColor, settings GameSettings) (int, error)
+	FetchCodeInvite(ctx context.Context, code int) (ReceivedInvite, error)
+	CancelCode(ctx context.Context, code int, ownerId uint64) error // Only from one of the owner's boards
+
+	Send(ctx context.Context, senderId uint64, recipientId uint64, recipientColor PlayerColor, settings GameSettings) error
+	CancelSent(ctx context.Context, senderId uint64, recipientId uint64) error
+	ListPending(ctx context.Context, userId uint64) ([]PendingInvite, error) // Those the user has not declined
+
+	// An undeclined invite to the owner of recipientBoard that lets them play recipientColor
+	FetchSent(ctx context.Context, id uint64, recipientBoard uint64, recipientColor PlayerColor) (ReceivedInvite, error)
+	Reject(ctx context.Context, id uint64, recipientId uint64) error
+	Delete(ctx context.Context, id uint64) error
+	ClearSent(ctx context.Context, senderId uint64) error // Every invite sent from the board
+}
+
+// Sends invites and starts the games of accepted ones
+type InvitationService struct {
+	tx      sv.Transactor
+	invites InvitationRepository
+	boards  *BoardService
+	games   *GameService
+}
+
+func NewInvitationService(tx sv.Transactor, invites InvitationRepository, boards *BoardService, games *GameService) *InvitationService {
+	return &InvitationService{tx: tx, invites: invites, boards: boards, games: games}
+}
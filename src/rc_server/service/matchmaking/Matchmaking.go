@@ -0,0 +1,434 @@
+package matchmaking
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"remotechess/src/rc_server/logging"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type QueueState int
+
+const (
+	NOT_QUEUED QueueState = iota
+	WAITING
+	MATCHED
+)
+
+const (
+	DEFAULT_RATING_RANGE = 100.0
+	MAX_RATING_RANGE     = 1000.0
+
+	// Every ratingRangeWidenInterval spent waiting adds ratingRangeWidenStep to a board's range
+	ratingRangeWidenStep     = 50.0
+	ratingRangeWidenInterval = 10 * time.Second
+
+	pairingInterval = time.Second
+)
+
+var queueStateToStr = map[QueueState]string{
+	NOT_QUEUED: "NOT_QUEUED",
+	WAITING:    "WAITING",
+	MATCHED:    "MATCHED",
+}
+
+// What a board waiting in the queue is willing to play
+type Preferences struct {
+	Settings    GameSettings // Only the time control, variant and rated flag are used
+	RatingRange float64      // Furthest from the board's own rating an opponent may be when joining
+}
+
+type QueueStatus struct {
+	State       QueueState
+	JoinedAt    time.Time
+	Rating      float64
+	RatingRange float64 // The current range, including how far it has widened
+	GameId      uint64  // Only set when MATCHED
+}
+
+type queueEntry struct {
+	board       Chessboard
+	preferences Preferences
+	rating      float64
+	joinedAt    time.Time
+	pairing     bool // A game is being created for it, so no other pairing may take it
+}
+
+// Two boards, the lower onboard ID first
+type boardPair struct {
+	first, second uint64
+}
+
+type matchQueue struct {
+	sync.Mutex
+	entries []*queueEntry      // Oldest first
+	matches map[uint64]uint64  // Onboard ID to the game it was matched into, until the board looks
+	failed  map[boardPair]bool // Pairs whose game could not be created, they are not tried again
+	started bool
+}
+
+// Pairs up the boards waiting in its queue and starts their games
+type Matchmaker struct {
+	queue  matchQueue
+	games  *GameService
+	boards *BoardService
+	users  *UserService
+	log    *logging.Logger
+}
+
+func NewMatchmaker(games *GameService, boards *BoardService, users *UserService, log *logging.Logger) *Matchmaker {
+	return &Matchmaker{
+		queue:  matchQueue{matches: map[uint64]uint64{}, failed: map[boardPair]bool{}},
+		games:  games,
+		boards: boards,
+		users:  users,
+		log:    log,
+	}
+}
+
+func (s QueueState) String() string {
+	return queueStateToStr[s]
+}
+
+// Put a board in the queue, or pair it straight away if a compatible board is already waiting
+func (m *Matchmaker) JoinQueue(board Chessboard, preferences Preferences) (QueueStatus, error) {
+	if err := checkPreferences(board, &preferences); err != nil {
+		return QueueStatus{}, err
+	}
+
+	// Only turns a busy board away early, creating the game checks again with the boards locked
+	if err := m.games.CheckGameLimitsTx(context.Background(), board, preferences.Settings.TimeControl); err != nil {
+		return QueueStatus{}, err
+	}
+
+	rating, err := m.boardRating(board, preferences.Settings.TimeControl.RatingPool())
+
+	if err != nil {
+		return QueueStatus{}, err
+	}
+
+	m.queue.Lock()
+
+	if m.queue.find(board.OnboardId) >= 0 {
+		m.queue.Unlock()
+		return QueueStatus{}, sv.NewAlreadyExistsError("Queue entry")
+	}
+
+	delete(m.queue.matches, board.OnboardId)
+
+	entry := &queueEntry{board: board, preferences: preferences, rating: rating, joinedAt: time.Now()}
+	m.queue.entries = append(m.queue.entries, entry)
+
+	if !m.queue.started {
+		m.queue.started = true
+		go m.pairPeriodically()
+	}
+
+	m.queue.Unlock()
+
+	m.pair(time.Now())
+
+	m.queue.Lock()
+	defer m.queue.Unlock()
+
+	return m.queue.status(board.OnboardId, time.Now()), nil
+}
+
+func (m *Matchmaker) LeaveQueue(board Chessboard) error {
+	m.queue.Lock()
+	defer m.queue.Unlock()
+
+	i := m.queue.find(board.OnboardId)
+
+	if i < 0 {
+		return sv.NewDoesNotExistError("Queue entry")
+	}
+
+	m.queue.remove(i)
+	m.queue.forgetFailed(board.OnboardId)
+
+	return nil
+}
+
+// Whether the board is waiting or has been matched. A match is only reported once.
+func (m *Matchmaker) FetchQueueStatus(board Chessboard) QueueStatus {
+	m.queue.Lock()
+	defer m.queue.Unlock()
+
+	status := m.queue.status(board.OnboardId, time.Now())
+
+	if status.State == MATCHED {
+		delete(m.queue.matches, board.OnboardId)
+	}
+
+	return status
+}
+
+func checkPreferences(board Chessboard, preferences *Preferences) error {
+	settings := preferences.Settings
+
+	if settings.StartFen != "" || settings.Chess960Position != RANDOM_CHESS960_POSITION {
+		return sv.NewGenericError("Matchmaking games cannot start from a chosen position", 400, sv.NOT_SENSITIVE)
+	}
+
+	if settings.Rated && !board.OwnerId.Valid {
+		return sv.NewGenericError("Rated games can only be played on boards with owners", 400, sv.NOT_SENSITIVE)
+	}
+
+	if settings.Rated && settings.Variant != STANDARD {
+		return sv.NewGenericError("Only standard chess games can be rated", 400, sv.NOT_SENSITIVE)
+	}
+
+	if preferences.RatingRange == 0 {
+		preferences.RatingRange = DEFAULT_RATING_RANGE
+	} else if preferences.RatingRange < 0 || preferences.RatingRange > MAX_RATING_RANGE {
+		return sv.NewInvalidInputError("Rating range")
+	}
+
+	return nil
+}
+
+// Boards without an owner are matched as if they had a new player's rating
+func (m *Matchmaker) boardRating(board Chessboard, pool RatingPool) (float64, error) {
+	if !board.OwnerId.Valid {
+		return DEFAULT_RATING, nil
+	}
+
+	owner := UserCore{Id: uint64(board.OwnerId.Int64)}
+	rating, err := m.users.FetchRating(&owner, pool)
+
+	return rating.Rating, err
+}
+
+func (m *Matchmaker) pairPeriodically() {
+	for range time.Tick(pairingInterval) {
+		m.pair(time.Now())
+	}
+}
+
+// Pair up every compatible pair of waiting boards, those who have waited longest first. The games are
+// created without holding the queue lock, and a pair whose game cannot be created only holds up itself.
+func (m *Matchmaker) pair(now time.Time) {
+	q := &m.queue
+
+	q.Lock()
+	pairs := q.candidates(now)
+	q.Unlock()
+
+	for _, p := range pairs {
+		a, b := p[0], p[1]
+		game, err := m.createMatch(a, b)
+
+		if err == nil {
+			q.Lock()
+			q.matched(a, b, game.Id)
+			q.Unlock()
+			continue
+		}
+
+		m.log.Error("matchmaking boards " + fmt.Sprint(a.board.OnboardId) + " and " + fmt.Sprint(b.board.OnboardId) + ": " + err.Error())
+
+		// Most likely one of them started a game some other way
+		availableA, availableB := m.available(a), m.available(b)
+
+		q.Lock()
+		q.unmatched(a, b, availableA, availableB)
+		q.Unlock()
+	}
+}
+
+// Whether the board can still play the game it is waiting for
+func (m *Matchmaker) available(e *queueEntry) bool {
+	board, err := m.boards.FetchChessboard(e.board.OnboardId)
+
+	return err == nil && m.games.CheckGameLimitsTx(context.Background(), *board, e.preferences.Settings.TimeControl) == nil
+}
+
+// The rest of the queue's methods must be called with it locked
+
+func (q *matchQueue) find(onboardId uint64) int {
+	for i, e := range q.entries {
+		if e.board.OnboardId == onboardId {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (q *matchQueue) remove(i int) {
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+}
+
+func (q *matchQueue) status(onboardId uint64, now time.Time) QueueStatus {
+	if gameId, ok := q.matches[onboardId]; ok {
+		return QueueStatus{State: MATCHED, GameId: gameId}
+	}
+
+	i := q.find(onboardId)
+
+	if i < 0 {
+		return QueueStatus{State: NOT_QUEUED}
+	}
+
+	e := q.entries[i]
+
+	return QueueStatus{State: WAITING, JoinedAt: e.joinedAt, Rating: e.rating, RatingRange: e.ratingRange(now)}
+}
+
+// Take the compatible pairs of waiting boards that no other pairing has taken, oldest first
+func (q *matchQueue) candidates(now time.Time) [][2]*queueEntry {
+	pairs := [][2]*queueEntry{}
+
+	for i, a := range q.entries {
+		for _, b := range q.entries[i+1:] {
+			if a.pairing || b.pairing || q.failed[newBoardPair(a, b)] || !a.compatible(b, now) {
+				continue
+			}
+
+			a.pairing, b.pairing = true, true
+			pairs = append(pairs, [2]*queueEntry{a, b})
+		}
+	}
+
+	return pairs
+}
+
+// Either board may have left the queue while its game was created, it is matched all the same
+func (q *matchQueue) matched(a *queueEntry, b *queueEntry, gameId uint64) {
+	for _, e := range []*queueEntry{a, b} {
+		q.matches[e.board.OnboardId] = gameId
+		q.drop(e)
+	}
+}
+
+// Drop the boards that can no longer play. If both still can, they are not paired with each other again.
+func (q *matchQueue) unmatched(a *queueEntry, b *queueEntry, availableA bool, availableB bool) {
+	a.pairing, b.pairing = false, false
+
+	if availableA && availableB {
+		q.failed[newBoardPair(a, b)] = true
+		return
+	}
+
+	if !availableA {
+		q.drop(a)
+	}
+
+	if !availableB {
+		q.drop(b)
+	}
+}
+
+func (q *matchQueue) drop(e *queueEntry) {
+	if i := q.find(e.board.OnboardId); i >= 0 {
+		q.remove(i)
+	}
+
+	q.forgetFailed(e.board.OnboardId)
+}
+
+func (q *matchQueue) forgetFailed(onboardId uint64) {
+	for p := range q.failed {
+		if p.first == onboardId || p.second == onboardId {
+			delete(q.failed, p)
+		}
+	}
+}
+
+func newBoardPair(a *queueEntry, b *queueEntry) boardPair {
+	if a.board.OnboardId < b.board.OnboardId {
+		return boardPair{a.board.OnboardId, b.board.OnboardId}
+	}
+
+	return boardPair{b.board.OnboardId, a.board.OnboardId}
+}
+
+// How far from its own rating the board accepts opponents after waiting until now
+func (e *queueEntry) ratingRange(now time.Time) float64 {
+	widened := float64(now.Sub(e.joinedAt)/ratingRangeWidenInterval) * ratingRangeWidenStep
+
+	return math.Min(e.preferences.RatingRange+widened, MAX_RATING_RANGE)
+}
+
+func (e *queueEntry) compatible(other *queueEntry, now time.Time) bool {
+	mine, theirs := e.preferences.Settings, other.preferences.Settings
+
+	if mine.TimeControl != theirs.TimeControl || mine.Variant != theirs.Variant || mine.Rated != theirs.Rated {
+		return false
+	}
+
+	// Nobody gets matched against their own boards
+	if e.board.OwnerId.Valid && other.board.OwnerId.Valid && e.board.OwnerId.Int64 == other.board.OwnerId.Int64 {
+		return false
+	}
+
+	difference := math.Abs(e.rating - other.rating)
+
+	return difference <= e.ratingRange(now) && difference <= other.ratingRange(now)
+}
+
+// Create the game for a pair. Whoever has recently had white more often gets black, ties are broken at random.
+func (m *Matchmaker) createMatch(a *queueEntry, b *queueEntry) (*ChessGame, error) {
+	boardA, err := m.boards.FetchChessboard(a.board.OnboardId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	boardB, err := m.boards.FetchChessboard(b.board.OnboardId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	balanceA, err := m.games.RecentColorBalance(boardA.OnboardId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	balanceB, err := m.games.RecentColorBalance(boardB.OnboardId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	white, black := boardA, boardB
+
+	if balanceA > balanceB {
+		white, black = boardB, boardA
+	} else if balanceA == balanceB {
+		coin, err := rand.Int(rand.Reader, big.NewInt(2))
+
+		if err != nil {
+			return nil, sv.NewInternalError("createMatch " + err.Error())
+		}
+
+		if coin.Int64() == 1 {
+			white, black = boardB, boardA
+		}
+	}
+
+	game, err := m.games.CreateChessGame(white, black, a.preferences.Settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := game.PublishEvent(GAME_STARTED_EVENT, EventData{}); err != nil {
+		m.log.Error("publishing matched game " + err.Error())
+	}
+
+	return game, nil
+}
@@ -0,0 +1,132 @@
+package usercore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SessionLifetime = 30 * 24 * time.Hour
+
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+type Session struct {
+	Token     string
+	User      UserCore
+	ExpiresAt time.Time
+}
+
+func (s *UserService) RegisterUser(email string, username string, password string) (*UserCore, error) {
+	email = strings.TrimSpace(email)
+
+	if !strings.Contains(email, "@") || len(email) > 254 {
+		return nil, sv.NewInvalidInputError("Email")
+	}
+
+	if !usernamePattern.MatchString(username) {
+		return nil, sv.NewGenericError("Usernames must be 3-32 letters, digits, _ or -", 400, sv.NOT_SENSITIVE)
+	}
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, sv.NewGenericError("Passwords must be 8-72 characters", 400, sv.NOT_SENSITIVE)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return nil, sv.NewInternalError("RegisterUser " + err.Error())
+	}
+
+	user := UserCore{Email: email, Username: username, Password: string(hash)}
+
+	if err = s.users.Register(context.Background(), &user); err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+
+	return &user, nil
+}
+
+// Check a username or email and password pair, and start a new session for the user if they match
+func (s *UserService) Login(login string, password string) (*Session, error) {
+	user, err := s.users.FetchByLogin(context.Background(), strings.TrimSpace(login))
+
+	if sv.IsDoesNotExist(err) {
+		// Spend as long as a real comparison would so response times do not reveal which accounts exist
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, newBadLoginError()
+	} else if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, newBadLoginError()
+	}
+
+	user.Password = ""
+
+	return s.createSession(user)
+}
+
+func (s *UserService) createSession(user UserCore) (*Session, error) {
+	raw := make([]byte, 32)
+
+	if _, err := rand.Read(raw); err != nil {
+		return nil, sv.NewInternalError("createSession " + err.Error())
+	}
+
+	session := Session{
+		Token:     base64.RawURLEncoding.EncodeToString(raw),
+		User:      user,
+		ExpiresAt: time.Now().Add(SessionLifetime),
+	}
+
+	if err := s.users.CreateSession(context.Background(), hashToken(session.Token), user.Id, session.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Return the user a session token belongs to, if the session exists and has not expired
+func (s *UserService) FetchSessionUser(token string) (*UserCore, error) {
+	user, err := s.users.FetchSessionUser(context.Background(), hashToken(token))
+
+	if sv.IsDoesNotExist(err) {
+		return nil, sv.NewGenericError("Session is invalid or has expired", 401, sv.NOT_SENSITIVE)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *UserService) Logout(token string) error {
+	return s.users.DeleteSession(context.Background(), hashToken(token))
+}
+
+// Only hashes of session tokens are stored, so a leaked sessions table cannot be used to log in
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func newBadLoginError() error {
+	return sv.NewGenericError("Incorrect username or password", 401, sv.NOT_SENSITIVE)
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("remotechess"), bcrypt.DefaultCost)
@@ -0,0 +1,80 @@
+package usercore
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+type NotificationKind int
+
+const (
+	DEADLINE_DAY_REMINDER  NotificationKind = iota // A day left to move in a correspondence game
+	DEADLINE_HOUR_REMINDER                         // An hour left to move in a correspondence game
+)
+
+// The most notifications returned at once
+const MAX_NOTIFICATIONS = 100
+
+var (
+	notificationKindToStr = map[NotificationKind]string{
+		DEADLINE_DAY_REMINDER:  "DEADLINE_DAY",
+		DEADLINE_HOUR_REMINDER: "DEADLINE_HOUR",
+	}
+
+	strToNotificationKind = map[string]NotificationKind{
+		"DEADLINE_DAY":  DEADLINE_DAY_REMINDER,
+		"DEADLINE_HOUR": DEADLINE_HOUR_REMINDER,
+	}
+)
+
+// Something queued for a user to see, e.g. a reminder that a move is due. BoardId is the board it concerns.
+type Notification struct {
+	Id        uint64
+	UserId    uint64
+	BoardId   uint64
+	GameId    uint64
+	Kind      NotificationKind
+	Deadline  time.Time
+	CreatedAt time.Time
+	Read      bool
+}
+
+func (k NotificationKind) String() string {
+	return notificationKindToStr[k]
+}
+
+func (this *NotificationKind) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToNotificationKind[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid NotificationKind enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this NotificationKind) Value() (driver.Value, error) {
+	if val, ok := notificationKindToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown NotificationKind")
+	}
+}
+
+// The user's most recent notifications, newest first
+func (s *UserService) FetchNotifications(user *UserCore, unreadOnly bool) ([]Notification, error) {
+	return s.notifications.List(context.Background(), user.Id, unreadOnly, MAX_NOTIFICATIONS)
+}
+
+func (s *UserService) MarkNotificationRead(user *UserCore, notificationId uint64) error {
+	return s.notifications.MarkRead(context.Background(), notificationId, user.Id)
+}
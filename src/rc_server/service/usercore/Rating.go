@@ -0,0 +1,240 @@
+package usercore
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"strings"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+)
+
+// Players have a separate rating for every category of time control
+type RatingPool int
+
+const (
+	BULLET RatingPool = iota
+	BLITZ
+	RAPID
+	CLASSICAL
+	CORRESPONDENCE_POOL
+)
+
+// Glicko-2 parameters. New players start at DEFAULT_RATING with the maximum deviation.
+const (
+	DEFAULT_RATING     = 1500.0
+	DEFAULT_DEVIATION  = 350.0
+	DEFAULT_VOLATILITY = 0.06
+
+	glickoScale     = 173.7178 // Converts between Glicko and Glicko-2 scales
+	glickoTau       = 0.5      // Constrains how quickly volatility can change
+	glickoTolerance = 0.000001
+)
+
+var (
+	ratingPoolToStr = map[RatingPool]string{
+		BULLET:              "BULLET",
+		BLITZ:               "BLITZ",
+		RAPID:               "RAPID",
+		CLASSICAL:           "CLASSICAL",
+		CORRESPONDENCE_POOL: "CORRESPONDENCE",
+	}
+
+	strToRatingPool = map[string]RatingPool{
+		"BULLET":         BULLET,
+		"BLITZ":          BLITZ,
+		"RAPID":          RAPID,
+		"CLASSICAL":      CLASSICAL,
+		"CORRESPONDENCE": CORRESPONDENCE_POOL,
+	}
+)
+
+type Rating struct {
+	Pool       RatingPool
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	Games      int
+}
+
+type RatingHistoryEntry struct {
+	GameId     uint64
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+	CreatedAt  time.Time
+}
+
+func (p RatingPool) String() string {
+	return ratingPoolToStr[p]
+}
+
+func RatingPoolFromString(s string) (RatingPool, error) {
+	if pool, ok := strToRatingPool[strings.ToUpper(s)]; ok {
+		return pool, nil
+	}
+
+	return CLASSICAL, sv.NewInvalidInputError("Rating pool " + s)
+}
+
+func (this *RatingPool) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+
+	if !ok {
+		return sv.NewInternalError("Scan source is not []byte")
+	}
+
+	if val, ok := strToRatingPool[string(b)]; ok {
+		*this = val
+	} else {
+		return sv.NewInternalError("Invalid RatingPool enum received: " + string(b))
+	}
+
+	return nil
+}
+
+func (this RatingPool) Value() (driver.Value, error) {
+	if val, ok := ratingPoolToStr[this]; ok {
+		return val, nil
+	} else {
+		return nil, sv.NewInternalError("Unknown RatingPool")
+	}
+}
+
+func MakeRatingDefault(pool RatingPool) Rating {
+	return Rating{Pool: pool, Rating: DEFAULT_RATING, Deviation: DEFAULT_DEVIATION, Volatility: DEFAULT_VOLATILITY}
+}
+
+// The user's ratings in every pool they have played a rated game in
+func (s *UserService) FetchRatings(user *UserCore) ([]Rating, error) {
+	return s.ratings.List(context.Background(), user.Id)
+}
+
+// The user's rating in a single pool, or a new player's rating if they have not played in it
+func (s *UserService) FetchRating(user *UserCore, pool RatingPool) (Rating, error) {
+	ratings, err := s.FetchRatings(user)
+
+	if err != nil {
+		return MakeRatingDefault(pool), err
+	}
+
+	for _, r := range ratings {
+		if r.Pool == pool {
+			return r, nil
+		}
+	}
+
+	return MakeRatingDefault(pool), nil
+}
+
+// Every change to the user's rating in a pool, oldest first
+func (s *UserService) FetchRatingHistory(user *UserCore, pool RatingPool) ([]RatingHistoryEntry, error) {
+	return s.ratings.History(context.Background(), user.Id, pool)
+}
+
+// Rate a finished game between two users as part of the transaction that records it, which ctx carries.
+// whiteScore is 1 for a white win, 0.5 for a draw and 0 for a black win.
+func (s *UserService) UpdateRatings(ctx context.Context, pool RatingPool, gameId uint64, whiteId uint64, blackId uint64, whiteScore float64) error {
+	// Lock the lower user id first so two games finishing together cannot deadlock
+	first, second := whiteId, blackId
+
+	if first > second {
+		first, second = second, first
+	}
+
+	ratings := map[uint64]Rating{}
+
+	for _, id := range []uint64{first, second} {
+		r, err := s.ratings.FetchForUpdate(ctx, id, pool)
+
+		if err != nil {
+			return err
+		}
+
+		ratings[id] = r
+	}
+
+	white, black := ratings[whiteId], ratings[blackId]
+
+	// Both players are rated against their opponent's rating from before the game
+	newWhite := white.afterGame(black, whiteScore)
+	newBlack := black.afterGame(white, 1-whiteScore)
+
+	if err := s.ratings.Store(ctx, whiteId, gameId, newWhite); err != nil {
+		return err
+	}
+
+	return s.ratings.Store(ctx, blackId, gameId, newBlack)
+}
+
+// The Glicko-2 update for a rating period containing a single game against opponent
+func (r Rating) afterGame(opponent Rating, score float64) Rating {
+	mu := (r.Rating - DEFAULT_RATING) / glickoScale
+	phi := r.Deviation / glickoScale
+	muOpp := (opponent.Rating - DEFAULT_RATING) / glickoScale
+	phiOpp := opponent.Deviation / glickoScale
+
+	g := 1 / math.Sqrt(1+3*phiOpp*phiOpp/(math.Pi*math.Pi))
+	expected := 1 / (1 + math.Exp(-g*(mu-muOpp)))
+	v := 1 / (g * g * expected * (1 - expected))
+	delta := v * g * (score - expected)
+
+	sigma := newVolatility(phi, r.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-expected)
+
+	return Rating{
+		Pool:       r.Pool,
+		Rating:     newMu*glickoScale + DEFAULT_RATING,
+		Deviation:  math.Min(newPhi*glickoScale, DEFAULT_DEVIATION),
+		Volatility: sigma,
+		Games:      r.Games + 1,
+	}
+}
+
+// Solve for the new volatility with the Illinois algorithm, step 5 of Glickman's paper
+func newVolatility(phi float64, sigma float64, v float64, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+
+	for math.Abs(B-A) > glickoTolerance {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,60 @@
+package usercore
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewVolatility(t *testing.T) {
+	// The worked example in Glickman's "Example of the Glicko-2 system", steps 3 to 5
+	sigma := newVolatility(200/glickoScale, 0.06, 1.7785, -0.4834)
+
+	if math.Abs(sigma-0.05999) > 0.00001 {
+		t.Fatalf("volatility %f, want 0.05999", sigma)
+	}
+}
+
+func TestAfterGame(t *testing.T) {
+	tests := []struct {
+		name                 string
+		player, opponent     Rating
+		score                float64
+		rating, rd, volatile float64
+	}{
+		{"new players, win", rating(1500, 350), rating(1500, 350), 1, 1662.31, 290.32, 0.06},
+		{"new players, draw", rating(1500, 350), rating(1500, 350), 0.5, 1500, 290.32, 0.06},
+		{"beats a weaker established player", rating(1500, 200), rating(1400, 30), 1, 1563.56, 175.40, 0.06},
+		{"loses to a stronger uncertain player", rating(1500, 200), rating(1700, 300), 0, 1455.86, 186.98, 0.06},
+		{"upset loss raises volatility", rating(2000, 50), rating(1500, 50), 0, 1986.05, 50.96, 0.06001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.player.afterGame(tt.opponent, tt.score)
+
+			if math.Abs(after.Rating-tt.rating) > 0.01 || math.Abs(after.Deviation-tt.rd) > 0.01 || math.Abs(after.Volatility-tt.volatile) > 0.00001 {
+				t.Fatalf("got %.2f/%.2f/%.5f, want %.2f/%.2f/%.5f", after.Rating, after.Deviation, after.Volatility, tt.rating, tt.rd, tt.volatile)
+			}
+
+			if after.Games != tt.player.Games+1 || after.Pool != tt.player.Pool {
+				t.Fatalf("got %+v after %+v", after, tt.player)
+			}
+		})
+	}
+}
+
+// Between two players with the same deviation, the winner gains what the loser loses
+func TestAfterGameIsZeroSum(t *testing.T) {
+	white, black := rating(1620, 80), rating(1480, 80)
+
+	newWhite := white.afterGame(black, 0)
+	newBlack := black.afterGame(white, 1)
+
+	if gained, lost := newBlack.Rating-black.Rating, white.Rating-newWhite.Rating; math.Abs(gained-lost) > 0.01 {
+		t.Fatalf("black gained %.2f, white lost %.2f", gained, lost)
+	}
+}
+
+func rating(r float64, deviation float64) Rating {
+	return Rating{Pool: BLITZ, Rating: r, Deviation: deviation, Volatility: DEFAULT_VOLATILITY, Games: 3}
+}
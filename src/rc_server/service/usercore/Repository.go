@@ -0,0 +1,54 @@
+package usercore
+
+import (
+	"context"
+	"time"
+)
+
+// Where users and their sessions are stored. Missing users are reported with a DoesNotExist error.
+type UserRepository interface {
+	Fetch(ctx context.Context, id uint64) (UserCore, error)
+	Register(ctx context.Context, user *UserCore) error               // user.Password holds the hash. Sets the new user's Id.
+	FetchByLogin(ctx context.Context, login string) (UserCore, error) // By username or email, ignoring case, with the password hash
+
+	CreateSession(ctx context.Context, tokenHash []byte, userId uint64, expiresAt time.Time) error
+	FetchSessionUser(ctx context.Context, tokenHash []byte) (UserCore, error) // Only for sessions that have not expired
+	DeleteSession(ctx context.Context, tokenHash []byte) error
+}
+
+// Friendships are requested by the left user and pending until the right user accepts them
+type FriendRepository interface {
+	Request(ctx context.Context, userId uint64, friendId uint64) error
+	List(ctx context.Context, userId uint64, pending bool) ([]UserCore, error) // Incoming requests if pending, otherwise every friend and request
+	Accept(ctx context.Context, requesterId uint64, userId uint64) error
+	Remove(ctx context.Context, friendId uint64, userId uint64) error // Either direction
+	AreFriends(ctx context.Context, userId uint64, otherId uint64) (bool, error)
+}
+
+type RatingRepository interface {
+	List(ctx context.Context, userId uint64) ([]Rating, error)
+	History(ctx context.Context, userId uint64, pool RatingPool) ([]RatingHistoryEntry, error)
+
+	// The rating locked until the end of the transaction, or a new player's rating if there is none yet
+	FetchForUpdate(ctx context.Context, userId uint64, pool RatingPool) (Rating, error)
+
+	// Store the rating after gameId and count the game
+	Store(ctx context.Context, userId uint64, gameId uint64, r Rating) error
+}
+
+type NotificationRepository interface {
+	List(ctx context.Context, userId uint64, unreadOnly bool, limit int) ([]Notification, error) // Newest first
+	MarkRead(ctx context.Context, id uint64, userId uint64) error
+}
+
+// Accounts, friends, ratings and notifications, kept in the repositories it was created with
+type UserService struct {
+	users         UserRepository
+	friends       FriendRepository
+	ratings       RatingRepository
+	notifications NotificationRepository
+}
+
+func NewUserService(users UserRepository, friends FriendRepository, ratings RatingRepository, notifications NotificationRepository) *UserService {
+	return &UserService{users: users, friends: friends, ratings: ratings, notifications: notifications}
+}
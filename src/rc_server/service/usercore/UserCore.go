@@ -1,11 +1,7 @@
 package usercore
 
 import (
-	"database/sql"
-	. "remotechess/src/rc_server/rcdb/usercore"
-	sv "remotechess/src/rc_server/service"
-
-	"github.com/lib/pq"
+	"context"
 )
 
 type UserCore struct {
@@ -15,105 +11,34 @@ type UserCore struct {
 	Password string
 }
 
-func FetchUserCore(userId uint64) (*UserCore, error) {
-	var user UserCore
-
-	row := sv.Db.QueryRow(GetUserCoreQuery(SELECT_USER), userId)
-
-	if row.Err() != nil {
-		return nil, sv.NewInternalError("FetchUserCore " + row.Err().Error())
-	}
-
-	err := row.Scan(&user.Id, &user.Email, &user.Username)
-
-	if err == sql.ErrNoRows {
-		return nil, sv.NewDoesNotExistError("User")
-	} else {
-		return &user, nil
-	}
-}
-
-func (user *UserCore) SendFriendRequest(friend UserCore) error {
-	res, err := sv.Db.Exec(GetUserCoreQuery(SEND_FRIEND_REQUEST), user.Id, friend.Id)
+func (s *UserService) FetchUserCore(userId uint64) (*UserCore, error) {
+	user, err := s.users.Fetch(context.Background(), userId)
 
 	if err != nil {
-		pqErr, ok := err.(*pq.Error)
-
-		if ok {
-			switch pqErr.Code {
-			case "23503":
-				return sv.NewDoesNotExistError("User")
-			case "23505":
-				return sv.NewAlreadyExistsError("Friendship")
-			case "23514":
-				return sv.NewGenericError("Cannot befriend yourself", 405, sv.NOT_SENSITIVE)
-			}
-		} else {
-			return sv.NewInternalError("SendFriendRequest " + err.Error())
-		}
+		return nil, err
 	}
 
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewInternalError("SendFriendRequest Could Not Be Completed")
-	}
-
-	return nil
+	return &user, nil
 }
 
-func (user *UserCore) GetFriends(pending bool) ([]UserCore, error) {
-	pendingRequests := []UserCore{}
-
-	rows, err := sv.Db.Query(GetUserCoreQuery(GET_FRIENDS), user.Id, pending)
-
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var pending UserCore
-		err = rows.Scan(&pending.Id, &pending.Username)
-
-		if err != nil {
-			return nil, sv.NewInternalError(err.Error())
-		}
-
-		pendingRequests = append(pendingRequests, pending)
-	}
-
-	if err != nil {
-		return nil, sv.NewInternalError(err.Error())
-	}
-
-	return pendingRequests, nil
+func (s *UserService) SendFriendRequest(user *UserCore, friend UserCore) error {
+	return s.friends.Request(context.Background(), user.Id, friend.Id)
 }
 
-func (user *UserCore) AcceptFriendRequest(incoming UserCore) error {
-	res, err := sv.Db.Exec(GetUserCoreQuery(ACCEPT_FRIEND_REQUEST), incoming.Id, user.Id)
-
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
-
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("Friend Request")
-	}
+func (s *UserService) GetFriends(user *UserCore, pending bool) ([]UserCore, error) {
+	return s.friends.List(context.Background(), user.Id, pending)
+}
 
-	return nil
+func (s *UserService) AcceptFriendRequest(user *UserCore, incoming UserCore) error {
+	return s.friends.Accept(context.Background(), incoming.Id, user.Id)
 }
 
 // Works for both existing friends and incoming friend requests
-func (user *UserCore) RemoveFriend(friend UserCore) error {
-	res, err := sv.Db.Exec(GetUserCoreQuery(REMOVE_FRIEND), friend.Id, user.Id)
-
-	if err != nil {
-		return sv.NewInternalError(err.Error())
-	}
-
-	if rowsAffected, _ := res.RowsAffected(); rowsAffected != 1 {
-		return sv.NewDoesNotExistError("Friend")
-	}
+func (s *UserService) RemoveFriend(user *UserCore, friend UserCore) error {
+	return s.friends.Remove(context.Background(), friend.Id, user.Id)
+}
 
-	return nil
+// Whether the two users are friends. Pending friend requests do not count.
+func (s *UserService) IsFriendsWith(user *UserCore, otherId uint64) (bool, error) {
+	return s.friends.AreFriends(context.Background(), user.Id, otherId)
 }
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	sv "remotechess/src/rc_server/service"
+	"remotechess/src/rc_server/service/chessboards"
+	"remotechess/src/rc_server/service/events"
+	"remotechess/src/rc_server/service/games"
+	"remotechess/src/rc_server/service/invitations"
+	"remotechess/src/rc_server/service/usercore"
+)
+
+// Every store the services read and write, all backed by the same database
+type Repositories struct {
+	Transactor    sv.Transactor
+	Games         games.GameRepository
+	Moves         games.MoveRepository
+	Chat          games.ChatRepository
+	Chessboards   chessboards.ChessboardRepository
+	Users         usercore.UserRepository
+	Friends       usercore.FriendRepository
+	Ratings       usercore.RatingRepository
+	Notifications usercore.NotificationRepository
+	Invitations   invitations.InvitationRepository
+	Events        events.EventRepository
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/games"
+)
+
+type chessboardRepository struct {
+	*store
+}
+
+func (r chessboardRepository) Fetch(ctx context.Context, onboardId uint64) (Chessboard, error) {
+	defer r.lock(ctx)()
+
+	board, ok := r.data.boards[onboardId]
+
+	if !ok {
+		return board.Chessboard, sv.NewDoesNotExistError("Chessboard")
+	}
+
+	return board.Chessboard, nil
+}
+
+func (r chessboardRepository) Register(ctx context.Context, onboardId uint64, secretHash []byte) (Chessboard, error) {
+	defer r.lock(ctx)()
+
+	if _, ok := r.data.boards[onboardId]; ok {
+		return Chessboard{}, sv.NewAlreadyExistsError("Chessboard")
+	}
+
+	board := boardRow{Chessboard: Chessboard{OnboardId: onboardId}, SecretHash: secretHash}
+	r.data.boards[onboardId] = board
+
+	return board.Chessboard, nil
+}
+
+func (r chessboardRepository) AssignFirstOwner(ctx context.Context, onboardId uint64, ownerId uint64) (bool, error) {
+	defer r.lock(ctx)()
+
+	board, ok := r.data.boards[onboardId]
+
+	if !ok || board.OwnerId.Valid {
+		return false, nil
+	}
+
+	board.OwnerId = sql.NullInt64{Int64: int64(ownerId), Valid: true}
+	r.data.boards[onboardId] = board
+
+	return true, nil
+}
+
+func (r chessboardRepository) CreateBot(ctx context.Context, onboardId uint64, level int) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.data.boards[onboardId]; !ok {
+		r.data.boards[onboardId] = boardRow{Chessboard: Chessboard{
+			OnboardId: onboardId,
+			BotLevel:  sql.NullInt64{Int64: int64(level), Valid: true},
+		}}
+	}
+
+	return nil
+}
+
+func (r chessboardRepository) FetchSecretHash(ctx context.Context, onboardId uint64) ([]byte, error) {
+	defer r.lock(ctx)()
+
+	board, ok := r.data.boards[onboardId]
+
+	if !ok {
+		return nil, sv.NewDoesNotExistError("Chessboard")
+	}
+
+	return board.SecretHash, nil
+}
+
+func (r chessboardRepository) SetSecretHash(ctx context.Context, onboardId uint64, hash []byte) error {
+	return r.update(ctx, onboardId, func(board *boardRow) {
+		board.SecretHash = hash
+	})
+}
+
+func (r chessboardRepository) SetFocusedGame(ctx context.Context, onboardId uint64, gameId sql.NullInt64) error {
+	return r.update(ctx, onboardId, func(board *boardRow) {
+		board.FocusedGame = gameId
+	})
+}
+
+func (r chessboardRepository) FocusGame(ctx context.Context, onboardId uint64, gameId uint64) error {
+	defer r.lock(ctx)()
+
+	board, ok := r.data.boards[onboardId]
+	game, found := r.data.games[gameId]
+
+	if !ok || !found || !game.playedOn(onboardId) {
+		return sv.NewDoesNotExistError("Game")
+	}
+
+	board.FocusedGame = sql.NullInt64{Int64: int64(gameId), Valid: true}
+	r.data.boards[onboardId] = board
+
+	return nil
+}
+
+func (r chessboardRepository) FocusNewGame(ctx context.Context, gameId uint64, whiteId uint64, blackId uint64, onlyIdle bool) ([]uint64, error) {
+	defer r.lock(ctx)()
+
+	focused := []uint64{}
+
+	for _, onboardId := range []uint64{whiteId, blackId} {
+		board, ok := r.data.boards[onboardId]
+
+		if !ok {
+			continue
+		}
+
+		current, found := r.data.games[uint64(board.FocusedGame.Int64)]
+
+		if onlyIdle && board.FocusedGame.Valid && found && current.Outcome == NO_OUTCOME {
+			continue
+		}
+
+		board.FocusedGame = sql.NullInt64{Int64: int64(gameId), Valid: true}
+		r.data.boards[onboardId] = board
+		focused = append(focused, onboardId)
+	}
+
+	return focused, nil
+}
+
+func (r chessboardRepository) update(ctx context.Context, onboardId uint64, change func(board *boardRow)) error {
+	defer r.lock(ctx)()
+
+	board, ok := r.data.boards[onboardId]
+
+	if !ok {
+		return sv.NewDoesNotExistError("Chessboard")
+	}
+
+	change(&board)
+	r.data.boards[onboardId] = board
+
+	return nil
+}
+
+// Transactions hold the whole store, so boards are as locked as they can be already
+func (r chessboardRepository) Lock(ctx context.Context, onboardIds []uint64) error {
+	return nil
+}
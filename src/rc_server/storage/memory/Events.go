@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	. "remotechess/src/rc_server/service/events"
+)
+
+type eventRepository struct {
+	*store
+}
+
+func (r eventRepository) Append(ctx context.Context, ev *Event) error {
+	defer r.lock(ctx)()
+
+	log := r.data.events[ev.GameId]
+
+	ev.Seq = uint64(len(log)) + 1
+	ev.CreatedAt = time.Now()
+	r.data.events[ev.GameId] = append(log, *ev)
+
+	return nil
+}
+
+func (r eventRepository) ListSince(ctx context.Context, gameId uint64, since uint64) ([]Event, error) {
+	defer r.lock(ctx)()
+
+	events := []Event{}
+
+	for _, ev := range r.data.events[gameId] {
+		if ev.Seq > since {
+			events = append(events, ev)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,653 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+// How many recent games the color balance of a board looks at
+const colorBalanceGames = 20
+
+type gameRepository struct {
+	*store
+}
+
+func (r gameRepository) Create(ctx context.Context, cgp *ChessGamePersistent) error {
+	defer r.lock(ctx)()
+
+	cgp.Id = r.nextId()
+	cgp.CreatedAt = time.Now()
+	r.data.games[cgp.Id] = gameRow{ChessGamePersistent: *cgp}
+
+	return nil
+}
+
+func (r gameRepository) Import(ctx context.Context, cgp *ChessGamePersistent) error {
+	defer r.lock(ctx)()
+
+	cgp.Id = r.nextId()
+	cgp.CreatedAt = time.Now()
+	cgp.Archived = true
+	r.data.games[cgp.Id] = gameRow{ChessGamePersistent: *cgp, EndedAt: sql.NullTime{Time: cgp.CreatedAt, Valid: true}}
+
+	return nil
+}
+
+func (r gameRepository) Fetch(ctx context.Context, id uint64) (ChessGamePersistent, error) {
+	defer r.lock(ctx)()
+
+	g, ok := r.data.games[id]
+
+	if !ok {
+		return g.ChessGamePersistent, sv.NewDoesNotExistError("Game")
+	}
+
+	return g.ChessGamePersistent, nil
+}
+
+func (r gameRepository) Update(ctx context.Context, cgp ChessGamePersistent) error {
+	defer r.lock(ctx)()
+
+	g, ok := r.data.games[cgp.Id]
+
+	if !ok {
+		return sv.NewDoesNotExistError("ChessGame")
+	} else if g.Version != cgp.Version {
+		return sv.NewConflictError("Game")
+	}
+
+	g.Fen, g.CurrentMove, g.Outcome, g.Method = cgp.Fen, cgp.CurrentMove, cgp.Outcome, cgp.Method
+	g.WhiteTimeMs, g.BlackTimeMs, g.TurnStartedAt = cgp.WhiteTimeMs, cgp.BlackTimeMs, cgp.TurnStartedAt
+	g.Plies = cgp.Plies
+	g.Version++
+
+	if g.Outcome == NO_OUTCOME {
+		g.EndedAt = sql.NullTime{}
+	} else if !g.EndedAt.Valid {
+		g.EndedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	r.data.games[cgp.Id] = g
+
+	return nil
+}
+
+func (r gameRepository) Adjudicate(ctx context.Context, id uint64, outcome GameOutcome, method GameMethod, whiteMs int64, blackMs int64) (bool, error) {
+	defer r.lock(ctx)()
+
+	g, ok := r.data.games[id]
+
+	if !ok || g.Outcome != NO_OUTCOME {
+		return false, nil
+	}
+
+	g.Outcome, g.Method, g.WhiteTimeMs, g.BlackTimeMs = outcome, method, whiteMs, blackMs
+	g.EndedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	g.Version++
+	r.data.games[id] = g
+
+	return true, nil
+}
+
+func (r gameRepository) SetDraw(ctx context.Context, id uint64, offered GameMethod, player PlayerColor) error {
+	return r.update(ctx, "Game", id, func(g *gameRow) {
+		g.OfferedDraw, g.OfferingPlayer = offered, player
+	})
+}
+
+func (r gameRepository) SetTakeback(ctx context.Context, id uint64, plies int, player PlayerColor) error {
+	return r.update(ctx, "Game", id, func(g *gameRow) {
+		g.TakebackPlies, g.TakebackPlayer = plies, player
+	})
+}
+
+func (r gameRepository) SetVisibility(ctx context.Context, id uint64, visibility GameVisibility) error {
+	return r.update(ctx, "Game", id, func(g *gameRow) {
+		g.Visibility = visibility
+	})
+}
+
+func (r gameRepository) ConfirmMirrored(ctx context.Context, id uint64, ply int) error {
+	err := r.update(ctx, "Game", id, func(g *gameRow) {
+		if g.MirroredPly < ply {
+			g.MirroredPly = ply
+		}
+	})
+
+	// Like the conditional update, confirming a game that is gone does nothing
+	if sv.IsDoesNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (r gameRepository) ResetMirrored(ctx context.Context, id uint64, ply int) error {
+	return r.update(ctx, "Game", id, func(g *gameRow) {
+		g.MirroredPly = ply
+	})
+}
+
+// Change a single game, reporting it as what if it does not exist
+func (r gameRepository) update(ctx context.Context, what string, id uint64, change func(g *gameRow)) error {
+	defer r.lock(ctx)()
+
+	g, ok := r.data.games[id]
+
+	if !ok {
+		return sv.NewDoesNotExistError(what)
+	}
+
+	change(&g)
+	r.data.games[id] = g
+
+	return nil
+}
+
+func (r gameRepository) MarkRatingsApplied(ctx context.Context, id uint64) (bool, error) {
+	defer r.lock(ctx)()
+
+	g, ok := r.data.games[id]
+
+	if !ok || !g.Rated || g.RatingsApplied || g.Outcome == NO_OUTCOME {
+		return false, nil
+	}
+
+	g.RatingsApplied = true
+	r.data.games[id] = g
+
+	return true, nil
+}
+
+func (r gameRepository) Search(ctx context.Context, userId sql.NullInt64, boardId sql.NullInt64, filter GameFilter) ([]GameSummary, int, error) {
+	defer r.lock(ctx)()
+
+	matches := []GameSummary{}
+
+	for _, g := range r.newestFirst(func(g gameRow) bool { return true }) {
+		gs := r.summarise(g)
+
+		whiteIsPlayer := equalIds(gs.WhiteUserId, userId) || equalIds(g.FkWhite, boardId)
+		blackIsPlayer := equalIds(gs.BlackUserId, userId) || equalIds(g.FkBlack, boardId)
+
+		if !whiteIsPlayer && !blackIsPlayer {
+			continue
+		}
+
+		side, opponent := PLAYER_BLACK, gs.WhiteUserId
+
+		if whiteIsPlayer {
+			side, opponent = PLAYER_WHITE, gs.BlackUserId
+		}
+
+		if filter.Color.Valid && filter.Color.PlayerColor != side {
+			continue
+		}
+
+		if filter.Opponent.Valid && !equalIds(opponent, filter.Opponent) {
+			continue
+		}
+
+		if filter.Result != "" && !matchesResult(g.Outcome, side, filter.Result) {
+			continue
+		}
+
+		if (filter.Method != nil && *filter.Method != g.Method) || (filter.Variant != nil && *filter.Variant != g.Variant) {
+			continue
+		}
+
+		if (filter.From.Valid && g.CreatedAt.Before(filter.From.Time)) || (filter.To.Valid && !g.CreatedAt.Before(filter.To.Time)) {
+			continue
+		}
+
+		matches = append(matches, gs)
+	}
+
+	total := len(matches)
+	start, end := filter.Offset, filter.Offset+filter.Limit
+
+	if start > total {
+		start = total
+	}
+
+	if end > total {
+		end = total
+	}
+
+	return matches[start:end], total, nil
+}
+
+// The summary of a game, naming players without a user the way the history query does
+func (r gameRepository) summarise(g gameRow) GameSummary {
+	gs := GameSummary{
+		Id:          g.Id,
+		WhiteBoard:  g.FkWhite,
+		BlackBoard:  g.FkBlack,
+		Outcome:     g.Outcome,
+		Method:      g.Method,
+		Variant:     g.Variant,
+		Rated:       g.Rated,
+		TimeControl: g.TcKind,
+		MoveCount:   g.Plies,
+		CreatedAt:   g.CreatedAt,
+		EndedAt:     g.EndedAt,
+	}
+
+	gs.WhiteUserId, gs.WhiteName = r.player(g.FkWhite, g.PgnTags["White"])
+	gs.BlackUserId, gs.BlackName = r.player(g.FkBlack, g.PgnTags["Black"])
+
+	return gs
+}
+
+func (r gameRepository) player(boardId sql.NullInt64, tagName string) (sql.NullInt64, sql.NullString) {
+	board, ok := r.data.boards[uint64(boardId.Int64)]
+
+	if boardId.Valid && ok && board.OwnerId.Valid {
+		if user, ok := r.data.users[uint64(board.OwnerId.Int64)]; ok {
+			return board.OwnerId, sql.NullString{String: user.Username, Valid: true}
+		}
+	}
+
+	if tagName != "" {
+		return sql.NullInt64{}, sql.NullString{String: tagName, Valid: true}
+	}
+
+	if boardId.Valid && ok && board.BotLevel.Valid {
+		return sql.NullInt64{}, sql.NullString{String: fmt.Sprint("Computer level ", board.BotLevel.Int64), Valid: true}
+	}
+
+	return sql.NullInt64{}, sql.NullString{}
+}
+
+func matchesResult(outcome GameOutcome, side PlayerColor, result string) bool {
+	won := (side == PLAYER_WHITE && outcome == WHITE_WON) || (side == PLAYER_BLACK && outcome == BLACK_WON)
+
+	switch result {
+	case "ONGOING":
+		return outcome == NO_OUTCOME
+	case "DRAW":
+		return outcome == DRAW
+	case "WIN":
+		return won
+	case "LOSS":
+		return (outcome == WHITE_WON || outcome == BLACK_WON) && !won
+	}
+
+	return false
+}
+
+// Both set and the same, like comparing nullable columns in SQL
+func equalIds(a sql.NullInt64, b sql.NullInt64) bool {
+	return a.Valid && b.Valid && a.Int64 == b.Int64
+}
+
+func (r gameRepository) ListOngoing(ctx context.Context, onboardId uint64) ([]uint64, error) {
+	defer r.lock(ctx)()
+
+	ongoing := r.newestFirst(func(g gameRow) bool { return g.playedOn(onboardId) && g.ongoing() })
+	ids := []uint64{}
+
+	for i := len(ongoing) - 1; i >= 0; i-- {
+		ids = append(ids, ongoing[i].Id)
+	}
+
+	return ids, nil
+}
+
+func (r gameRepository) CountOngoing(ctx context.Context, onboardId uint64) (int, int, error) {
+	defer r.lock(ctx)()
+
+	var correspondence, live int
+
+	for _, g := range r.data.games {
+		if !g.playedOn(onboardId) || !g.ongoing() {
+			continue
+		}
+
+		if g.TcKind == CORRESPONDENCE {
+			correspondence++
+		} else {
+			live++
+		}
+	}
+
+	return correspondence, live, nil
+}
+
+func (r gameRepository) ListOverdueCorrespondence(ctx context.Context) ([]uint64, error) {
+	defer r.lock(ctx)()
+
+	ids := []uint64{}
+	now := time.Now()
+
+	for _, g := range r.data.games {
+		if g.TcKind == CORRESPONDENCE && g.ongoing() && !g.deadline().After(now) {
+			ids = append(ids, g.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r gameRepository) ListFlaggedLive(ctx context.Context) ([]uint64, error) {
+	defer r.lock(ctx)()
+
+	ids := []uint64{}
+	now := time.Now()
+
+	for _, g := range r.data.games {
+		live := g.TcKind == FISCHER || g.TcKind == BRONSTEIN || g.TcKind == SIMPLE_DELAY
+
+		if live && g.ongoing() && g.Plies >= 2 && !g.flagsAt().After(now) {
+			ids = append(ids, g.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r gameRepository) ListAwaitingBot(ctx context.Context) ([]uint64, error) {
+	defer r.lock(ctx)()
+
+	ids := []uint64{}
+
+	for _, g := range r.data.games {
+		mover := g.FkWhite
+
+		if g.CurrentMove == PLAYER_BLACK {
+			mover = g.FkBlack
+		}
+
+		if board, ok := r.data.boards[uint64(mover.Int64)]; ok && mover.Valid && g.ongoing() && board.IsBot() {
+			ids = append(ids, g.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r gameRepository) QueueDeadlineReminders(ctx context.Context, kind NotificationKind, lead time.Duration) ([]Notification, error) {
+	defer r.lock(ctx)()
+
+	queued := []Notification{}
+	now := time.Now()
+
+	for _, g := range r.data.games {
+		if g.TcKind != CORRESPONDENCE || !g.ongoing() {
+			continue
+		}
+
+		moverId := g.FkWhite
+
+		if g.CurrentMove == PLAYER_BLACK {
+			moverId = g.FkBlack
+		}
+
+		mover, ok := r.data.boards[uint64(moverId.Int64)]
+		deadline := g.deadline()
+
+		if !moverId.Valid || !ok || !mover.OwnerId.Valid || deadline.Sub(g.TurnStartedAt) <= lead {
+			continue
+		}
+
+		if deadline.Add(-lead).After(now) || !deadline.After(now) || r.hasReminder(g.Id, kind, deadline) {
+			continue
+		}
+
+		n := Notification{
+			Id:        r.nextId(),
+			UserId:    uint64(mover.OwnerId.Int64),
+			BoardId:   mover.OnboardId,
+			GameId:    g.Id,
+			Kind:      kind,
+			Deadline:  deadline,
+			CreatedAt: now,
+		}
+
+		r.data.notifications = append(r.data.notifications, n)
+		queued = append(queued, n)
+	}
+
+	return queued, nil
+}
+
+func (r gameRepository) hasReminder(gameId uint64, kind NotificationKind, deadline time.Time) bool {
+	for _, n := range r.data.notifications {
+		if n.GameId == gameId && n.Kind == kind && n.Deadline.Equal(deadline) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r gameRepository) ColorBalance(ctx context.Context, onboardId uint64) (int, error) {
+	defer r.lock(ctx)()
+
+	balance := 0
+
+	for i, g := range r.newestFirst(func(g gameRow) bool { return g.playedOn(onboardId) }) {
+		if i == colorBalanceGames {
+			break
+		}
+
+		if equalIds(g.FkWhite, sql.NullInt64{Int64: int64(onboardId), Valid: true}) {
+			balance++
+		} else {
+			balance--
+		}
+	}
+
+	return balance, nil
+}
+
+func (r gameRepository) newestFirst(keep func(g gameRow) bool) []gameRow {
+	games := []gameRow{}
+
+	for _, g := range r.data.games {
+		if keep(g) {
+			games = append(games, g)
+		}
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].CreatedAt.Equal(games[j].CreatedAt) {
+			return games[i].CreatedAt.After(games[j].CreatedAt)
+		}
+
+		return games[i].Id > games[j].Id
+	})
+
+	return games
+}
+
+func (g gameRow) playedOn(onboardId uint64) bool {
+	board := sql.NullInt64{Int64: int64(onboardId), Valid: true}
+
+	return equalIds(g.FkWhite, board) || equalIds(g.FkBlack, board)
+}
+
+func (g gameRow) ongoing() bool {
+	return g.Outcome == NO_OUTCOME && !g.Archived
+}
+
+func (g gameRow) deadline() time.Time {
+	return g.TurnStartedAt.Add(time.Duration(g.TcDaysPerMove) * 24 * time.Hour)
+}
+
+func (g gameRow) flagsAt() time.Time {
+	remaining := g.WhiteTimeMs
+
+	if g.CurrentMove == PLAYER_BLACK {
+		remaining = g.BlackTimeMs
+	}
+
+	if g.TcKind == SIMPLE_DELAY {
+		remaining += g.TcIncrementMs
+	}
+
+	return g.TurnStartedAt.Add(time.Duration(remaining) * time.Millisecond)
+}
+
+type moveRepository struct {
+	*store
+}
+
+func (r moveRepository) Create(ctx context.Context, gameId uint64, m MoveRecord) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.data.games[gameId]; !ok {
+		return sv.NewDoesNotExistError("Game")
+	}
+
+	r.data.moves[gameId] = append(r.data.moves[gameId], moveRow{MoveRecord: m})
+
+	return nil
+}
+
+func (r moveRepository) List(ctx context.Context, gameId uint64) ([]string, error) {
+	defer r.lock(ctx)()
+
+	moves := []string{}
+
+	for _, m := range r.data.moves[gameId] {
+		moves = append(moves, m.From+m.To+m.Promotion)
+	}
+
+	return moves, nil
+}
+
+func (r moveRepository) DeleteLast(ctx context.Context, gameId uint64) error {
+	defer r.lock(ctx)()
+
+	moves := r.data.moves[gameId]
+
+	if len(moves) == 0 {
+		return sv.NewDoesNotExistError("Move")
+	}
+
+	r.data.moves[gameId] = moves[: len(moves)-1 : len(moves)-1]
+
+	return nil
+}
+
+func (r moveRepository) SaveAnalysis(ctx context.Context, gameId uint64, analysis []MoveAnalysis) error {
+	defer r.lock(ctx)()
+
+	moves := append([]moveRow{}, r.data.moves[gameId]...)
+
+	for i := range analysis {
+		if analysis[i].Ply < len(moves) {
+			m := analysis[i]
+			moves[m.Ply].Analysis = &m
+		}
+	}
+
+	r.data.moves[gameId] = moves
+
+	return nil
+}
+
+func (r moveRepository) FetchAnalysis(ctx context.Context, gameId uint64) ([]MoveAnalysis, error) {
+	defer r.lock(ctx)()
+
+	analysis := []MoveAnalysis{}
+
+	for ply, m := range r.data.moves[gameId] {
+		if m.Analysis == nil {
+			return nil, nil
+		}
+
+		a := *m.Analysis
+		a.Ply, a.Move, a.Player = ply, m.From+m.To+m.Promotion, m.Player
+		analysis = append(analysis, a)
+	}
+
+	return analysis, nil
+}
+
+func (r moveRepository) FetchRequest(ctx context.Context, onboardId uint64, key string, ttl time.Duration) (MoveRequest, error) {
+	defer r.lock(ctx)()
+
+	req, ok := r.data.moveRequests[moveRequestKey{onboardId, key}]
+
+	if !ok || time.Since(req.CreatedAt) >= ttl {
+		return MoveRequest{}, sv.NewDoesNotExistError("Move request")
+	}
+
+	return req.MoveRequest, nil
+}
+
+func (r moveRepository) CreateRequest(ctx context.Context, onboardId uint64, key string, req MoveRequest, ttl time.Duration) error {
+	defer r.lock(ctx)()
+
+	for k, v := range r.data.moveRequests {
+		if k.OnboardId == onboardId && time.Since(v.CreatedAt) >= ttl {
+			delete(r.data.moveRequests, k)
+		}
+	}
+
+	r.data.moveRequests[moveRequestKey{onboardId, key}] = moveRequestRow{req, time.Now()}
+
+	return nil
+}
+
+type chatRepository struct {
+	*store
+}
+
+func (r chatRepository) Create(ctx context.Context, gameId uint64, msg *ChatMessage) error {
+	defer r.lock(ctx)()
+
+	msg.Id = r.nextId()
+	msg.CreatedAt = time.Now()
+	r.data.chat[gameId] = append(r.data.chat[gameId], *msg)
+
+	return nil
+}
+
+// Everything the viewer may read: their own messages, and their opponent's unless sent while muted
+func (r chatRepository) List(ctx context.Context, gameId uint64, viewer PlayerColor) ([]ChatMessage, error) {
+	defer r.lock(ctx)()
+
+	mutedAt, muted := r.data.mutes[chatMute{gameId, viewer}]
+	messages := []ChatMessage{}
+
+	for _, msg := range r.data.chat[gameId] {
+		if msg.Player == viewer || !muted || msg.CreatedAt.Before(mutedAt) {
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages, nil
+}
+
+func (r chatRepository) SetMuted(ctx context.Context, gameId uint64, player PlayerColor, muted bool) error {
+	defer r.lock(ctx)()
+
+	key := chatMute{gameId, player}
+
+	if !muted {
+		delete(r.data.mutes, key)
+	} else if _, ok := r.data.mutes[key]; !ok {
+		r.data.mutes[key] = time.Now()
+	}
+
+	return nil
+}
+
+func (r chatRepository) IsMuted(ctx context.Context, gameId uint64, player PlayerColor) (bool, error) {
+	defer r.lock(ctx)()
+
+	_, muted := r.data.mutes[chatMute{gameId, player}]
+
+	return muted, nil
+}
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"sort"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/invitations"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+// Invite codes are six digits, like those the database hands out
+const (
+	minInviteCode = 100000
+	maxInviteCode = 999999
+)
+
+type invitationRepository struct {
+	*store
+}
+
+func (r invitationRepository) CreateWithCode(ctx context.Context, senderId uint64, recipientColor PlayerColor, settings GameSettings) (int, error) {
+	defer r.lock(ctx)()
+
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(maxInviteCode-minInviteCode+1))
+
+		if err != nil {
+			return 0, sv.NewInternalError("CreateGameInviteWithCode " + err.Error())
+		}
+
+		code := minInviteCode + int(n.Int64())
+
+		if _, taken := r.byCode(code); taken {
+			continue
+		}
+
+		id := r.nextId()
+		r.data.invites[id] = inviteRow{
+			Id:             id,
+			Code:           code,
+			SenderId:       senderId,
+			RecipientColor: NullablePlayerColor{PlayerColor: recipientColor, Valid: true},
+			Settings:       settings,
+		}
+
+		return code, nil
+	}
+}
+
+func (r invitationRepository) FetchCodeInvite(ctx context.Context, code int) (ReceivedInvite, error) {
+	defer r.lock(ctx)()
+
+	invite, ok := r.byCode(code)
+	sender, found := r.data.boards[invite.SenderId]
+
+	if !ok || !found {
+		return ReceivedInvite{}, sv.NewDoesNotExistError("Invite")
+	}
+
+	return ReceivedInvite{Sender: sender.Chessboard, RecipientColor: invite.RecipientColor.PlayerColor, Settings: invite.Settings}, nil
+}
+
+func (r invitationRepository) CancelCode(ctx context.Context, code int, ownerId uint64) error {
+	defer r.lock(ctx)()
+
+	invite, ok := r.byCode(code)
+
+	if !ok || !r.ownedBy(invite.SenderId, ownerId) {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	delete(r.data.invites, invite.Id)
+
+	return nil
+}
+
+func (r invitationRepository) Send(ctx context.Context, senderId uint64, recipientId uint64, recipientColor PlayerColor, settings GameSettings) error {
+	defer r.lock(ctx)()
+
+	for _, invite := range r.data.invites {
+		if invite.SenderId == senderId && invite.RecipientId.Valid && uint64(invite.RecipientId.Int64) == recipientId {
+			return sv.NewGenericError("There is already a pending invite for that user", 409, sv.NOT_SENSITIVE)
+		}
+	}
+
+	id := r.nextId()
+	r.data.invites[id] = inviteRow{
+		Id:             id,
+		SenderId:       senderId,
+		RecipientId:    sql.NullInt64{Int64: int64(recipientId), Valid: true},
+		RecipientColor: NullablePlayerColor{PlayerColor: recipientColor, Valid: true},
+		Settings:       settings,
+	}
+
+	return nil
+}
+
+func (r invitationRepository) CancelSent(ctx context.Context, senderId uint64, recipientId uint64) error {
+	defer r.lock(ctx)()
+
+	for id, invite := range r.data.invites {
+		if invite.SenderId == senderId && invite.RecipientId.Valid && uint64(invite.RecipientId.Int64) == recipientId {
+			delete(r.data.invites, id)
+		}
+	}
+
+	return nil
+}
+
+func (r invitationRepository) ListPending(ctx context.Context, userId uint64) ([]PendingInvite, error) {
+	defer r.lock(ctx)()
+
+	invites := []PendingInvite{}
+
+	for _, invite := range r.data.invites {
+		if !invite.RecipientId.Valid || uint64(invite.RecipientId.Int64) != userId || invite.Declined {
+			continue
+		}
+
+		pending := PendingInvite{Id: invite.Id, YourColor: invite.RecipientColor.ToPointer(), Settings: invite.Settings}
+
+		if sender, ok := r.data.boards[invite.SenderId]; ok && sender.OwnerId.Valid {
+			owner := r.data.users[uint64(sender.OwnerId.Int64)]
+			pending.Sender = UserCore{Id: owner.Id, Username: owner.Username}
+		}
+
+		invites = append(invites, pending)
+	}
+
+	sort.Slice(invites, func(i, j int) bool { return invites[i].Id < invites[j].Id })
+
+	return invites, nil
+}
+
+func (r invitationRepository) FetchSent(ctx context.Context, id uint64, recipientBoard uint64, recipientColor PlayerColor) (ReceivedInvite, error) {
+	defer r.lock(ctx)()
+
+	invite, ok := r.data.invites[id]
+	board := r.data.boards[recipientBoard]
+	sender, found := r.data.boards[invite.SenderId]
+
+	if !ok || !found || invite.Declined || !board.OwnerId.Valid || invite.RecipientId != board.OwnerId {
+		return ReceivedInvite{}, sv.NewDoesNotExistError("Invite")
+	}
+
+	if invite.RecipientColor.Valid && invite.RecipientColor.PlayerColor != recipientColor {
+		return ReceivedInvite{}, sv.NewDoesNotExistError("Invite")
+	}
+
+	return ReceivedInvite{Sender: sender.Chessboard, RecipientColor: recipientColor, Settings: invite.Settings}, nil
+}
+
+func (r invitationRepository) Reject(ctx context.Context, id uint64, recipientId uint64) error {
+	defer r.lock(ctx)()
+
+	invite, ok := r.data.invites[id]
+
+	if !ok || !invite.RecipientId.Valid || uint64(invite.RecipientId.Int64) != recipientId {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	invite.Declined = true
+	r.data.invites[id] = invite
+
+	return nil
+}
+
+func (r invitationRepository) Delete(ctx context.Context, id uint64) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.data.invites[id]; !ok {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	delete(r.data.invites, id)
+
+	return nil
+}
+
+func (r invitationRepository) ClearSent(ctx context.Context, senderId uint64) error {
+	defer r.lock(ctx)()
+
+	for id, invite := range r.data.invites {
+		if invite.SenderId == senderId {
+			delete(r.data.invites, id)
+		}
+	}
+
+	return nil
+}
+
+func (r invitationRepository) byCode(code int) (inviteRow, bool) {
+	for _, invite := range r.data.invites {
+		if invite.Code == code {
+			return invite, true
+		}
+	}
+
+	return inviteRow{}, false
+}
+
+func (r invitationRepository) ownedBy(onboardId uint64, ownerId uint64) bool {
+	board, ok := r.data.boards[onboardId]
+
+	return ok && board.OwnerId.Valid && uint64(board.OwnerId.Int64) == ownerId
+}
@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	. "remotechess/src/rc_server/service/chessboards"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/events"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+	"remotechess/src/rc_server/storage"
+)
+
+type txKey struct{}
+
+type gameRow struct {
+	ChessGamePersistent
+	EndedAt        sql.NullTime
+	RatingsApplied bool
+}
+
+type moveRow struct {
+	MoveRecord
+	Analysis *MoveAnalysis // Nil until the game is analysed
+}
+
+type moveRequestKey struct {
+	OnboardId uint64
+	Key       string
+}
+
+type moveRequestRow struct {
+	MoveRequest
+	CreatedAt time.Time
+}
+
+type chatMute struct {
+	GameId uint64
+	Player PlayerColor
+}
+
+type boardRow struct {
+	Chessboard
+	SecretHash []byte
+}
+
+type sessionRow struct {
+	UserId    uint64
+	ExpiresAt time.Time
+}
+
+type friendship struct {
+	Left, Right uint64
+	Pending     bool
+}
+
+type ratingKey struct {
+	UserId uint64
+	Pool   RatingPool
+}
+
+type inviteRow struct {
+	Id             uint64
+	Code           int // 0 for invites sent to a user
+	SenderId       uint64
+	RecipientId    sql.NullInt64
+	RecipientColor NullablePlayerColor
+	Settings       GameSettings
+	Declined       bool
+}
+
+// Everything the store holds. Rows are kept by value so that a copy of the tables is a snapshot.
+type tables struct {
+	games         map[uint64]gameRow
+	moves         map[uint64][]moveRow
+	moveRequests  map[moveRequestKey]moveRequestRow
+	chat          map[uint64][]ChatMessage
+	mutes         map[chatMute]time.Time
+	boards        map[uint64]boardRow
+	users         map[uint64]UserCore // Password holds the hash
+	sessions      map[string]sessionRow
+	friends       []friendship
+	ratings       map[ratingKey]Rating
+	ratingHistory map[ratingKey][]RatingHistoryEntry
+	notifications []Notification
+	invites       map[uint64]inviteRow
+	events        map[uint64][]Event
+
+	lastId uint64 // Shared by every table with generated ids
+}
+
+// Keeps everything in memory and loses it on exit. Every call holds one lock, so transactions are serialized.
+type store struct {
+	sync.Mutex
+	data tables
+}
+
+// Repositories that need no database, for tests and trying the server out
+func NewRepositories() storage.Repositories {
+	s := &store{data: tables{
+		games:         map[uint64]gameRow{},
+		moves:         map[uint64][]moveRow{},
+		moveRequests:  map[moveRequestKey]moveRequestRow{},
+		chat:          map[uint64][]ChatMessage{},
+		mutes:         map[chatMute]time.Time{},
+		boards:        map[uint64]boardRow{},
+		users:         map[uint64]UserCore{},
+		sessions:      map[string]sessionRow{},
+		ratings:       map[ratingKey]Rating{},
+		ratingHistory: map[ratingKey][]RatingHistoryEntry{},
+		invites:       map[uint64]inviteRow{},
+		events:        map[uint64][]Event{},
+	}}
+
+	return storage.Repositories{
+		Transactor:    s,
+		Games:         gameRepository{s},
+		Moves:         moveRepository{s},
+		Chat:          chatRepository{s},
+		Chessboards:   chessboardRepository{s},
+		Users:         userRepository{s},
+		Friends:       friendRepository{s},
+		Ratings:       ratingRepository{s},
+		Notifications: notificationRepository{s},
+		Invitations:   invitationRepository{s},
+		Events:        eventRepository{s},
+	}
+}
+
+// Take the lock unless ctx belongs to a transaction, which already holds it. Returns the unlock to defer.
+func (s *store) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) != nil {
+		return func() {}
+	}
+
+	s.Lock()
+	return s.Unlock
+}
+
+// Holds the lock for the whole of fn and puts the tables back as they were if it fails
+func (s *store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	snapshot := s.data.clone()
+
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		s.data = snapshot
+		return err
+	}
+
+	return nil
+}
+
+func (s *store) nextId() uint64 {
+	s.data.lastId++
+	return s.data.lastId
+}
+
+func (t tables) clone() tables {
+	c := t
+
+	c.games = map[uint64]gameRow{}
+	for k, v := range t.games {
+		c.games[k] = v
+	}
+
+	c.moves = map[uint64][]moveRow{}
+	for k, v := range t.moves {
+		c.moves[k] = append([]moveRow{}, v...)
+	}
+
+	c.moveRequests = map[moveRequestKey]moveRequestRow{}
+	for k, v := range t.moveRequests {
+		c.moveRequests[k] = v
+	}
+
+	c.chat = map[uint64][]ChatMessage{}
+	for k, v := range t.chat {
+		c.chat[k] = append([]ChatMessage{}, v...)
+	}
+
+	c.mutes = map[chatMute]time.Time{}
+	for k, v := range t.mutes {
+		c.mutes[k] = v
+	}
+
+	c.boards = map[uint64]boardRow{}
+	for k, v := range t.boards {
+		c.boards[k] = v
+	}
+
+	c.users = map[uint64]UserCore{}
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+
+	c.sessions = map[string]sessionRow{}
+	for k, v := range t.sessions {
+		c.sessions[k] = v
+	}
+
+	c.friends = append([]friendship{}, t.friends...)
+
+	c.ratings = map[ratingKey]Rating{}
+	for k, v := range t.ratings {
+		c.ratings[k] = v
+	}
+
+	c.ratingHistory = map[ratingKey][]RatingHistoryEntry{}
+	for k, v := range t.ratingHistory {
+		c.ratingHistory[k] = append([]RatingHistoryEntry{}, v...)
+	}
+
+	c.notifications = append([]Notification{}, t.notifications...)
+
+	c.invites = map[uint64]inviteRow{}
+	for k, v := range t.invites {
+		c.invites[k] = v
+	}
+
+	c.events = map[uint64][]Event{}
+	for k, v := range t.events {
+		c.events[k] = append([]Event{}, v...)
+	}
+
+	return c
+}
@@ -0,0 +1,272 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type userRepository struct {
+	*store
+}
+
+func (r userRepository) Fetch(ctx context.Context, id uint64) (UserCore, error) {
+	defer r.lock(ctx)()
+
+	user, ok := r.data.users[id]
+
+	if !ok {
+		return UserCore{}, sv.NewDoesNotExistError("User")
+	}
+
+	user.Password = ""
+
+	return user, nil
+}
+
+func (r userRepository) Register(ctx context.Context, user *UserCore) error {
+	defer r.lock(ctx)()
+
+	for _, existing := range r.data.users {
+		if strings.EqualFold(existing.Email, user.Email) || strings.EqualFold(existing.Username, user.Username) {
+			return sv.NewAlreadyExistsError("User with that email or username")
+		}
+	}
+
+	user.Id = r.nextId()
+	r.data.users[user.Id] = *user
+
+	return nil
+}
+
+func (r userRepository) FetchByLogin(ctx context.Context, login string) (UserCore, error) {
+	defer r.lock(ctx)()
+
+	for _, user := range r.data.users {
+		if strings.EqualFold(user.Username, login) || strings.EqualFold(user.Email, login) {
+			return user, nil
+		}
+	}
+
+	return UserCore{}, sv.NewDoesNotExistError("User")
+}
+
+func (r userRepository) CreateSession(ctx context.Context, tokenHash []byte, userId uint64, expiresAt time.Time) error {
+	defer r.lock(ctx)()
+
+	r.data.sessions[string(tokenHash)] = sessionRow{UserId: userId, ExpiresAt: expiresAt}
+
+	return nil
+}
+
+func (r userRepository) FetchSessionUser(ctx context.Context, tokenHash []byte) (UserCore, error) {
+	defer r.lock(ctx)()
+
+	session, ok := r.data.sessions[string(tokenHash)]
+	user, found := r.data.users[session.UserId]
+
+	if !ok || !found || !session.ExpiresAt.After(time.Now()) {
+		return UserCore{}, sv.NewDoesNotExistError("Session")
+	}
+
+	user.Password = ""
+
+	return user, nil
+}
+
+func (r userRepository) DeleteSession(ctx context.Context, tokenHash []byte) error {
+	defer r.lock(ctx)()
+
+	delete(r.data.sessions, string(tokenHash))
+
+	return nil
+}
+
+type friendRepository struct {
+	*store
+}
+
+func (r friendRepository) Request(ctx context.Context, userId uint64, friendId uint64) error {
+	defer r.lock(ctx)()
+
+	if userId == friendId {
+		return sv.NewGenericError("Cannot befriend yourself", 405, sv.NOT_SENSITIVE)
+	}
+
+	if _, ok := r.data.users[friendId]; !ok {
+		return sv.NewDoesNotExistError("User")
+	}
+
+	if r.find(userId, friendId) >= 0 {
+		return sv.NewAlreadyExistsError("Friendship")
+	}
+
+	r.data.friends = append(r.data.friends, friendship{Left: userId, Right: friendId, Pending: true})
+
+	return nil
+}
+
+// Incoming requests if pending, otherwise every friend and request either way, by username
+func (r friendRepository) List(ctx context.Context, userId uint64, pending bool) ([]UserCore, error) {
+	defer r.lock(ctx)()
+
+	friends := []UserCore{}
+
+	for _, f := range r.data.friends {
+		otherId := f.Left
+
+		if pending && (f.Right != userId || !f.Pending) {
+			continue
+		} else if !pending && f.Left == userId {
+			otherId = f.Right
+		} else if !pending && f.Right != userId {
+			continue
+		}
+
+		if other, ok := r.data.users[otherId]; ok {
+			friends = append(friends, UserCore{Id: other.Id, Username: other.Username})
+		}
+	}
+
+	sort.Slice(friends, func(i, j int) bool { return friends[i].Username < friends[j].Username })
+
+	return friends, nil
+}
+
+func (r friendRepository) Accept(ctx context.Context, requesterId uint64, userId uint64) error {
+	defer r.lock(ctx)()
+
+	for i, f := range r.data.friends {
+		if f.Left == requesterId && f.Right == userId && f.Pending {
+			r.data.friends[i].Pending = false
+			return nil
+		}
+	}
+
+	return sv.NewDoesNotExistError("Friend Request")
+}
+
+func (r friendRepository) Remove(ctx context.Context, friendId uint64, userId uint64) error {
+	defer r.lock(ctx)()
+
+	i := r.find(friendId, userId)
+
+	if i < 0 {
+		return sv.NewDoesNotExistError("Friend")
+	}
+
+	r.data.friends = append(r.data.friends[:i:i], r.data.friends[i+1:]...)
+
+	return nil
+}
+
+func (r friendRepository) AreFriends(ctx context.Context, userId uint64, otherId uint64) (bool, error) {
+	defer r.lock(ctx)()
+
+	i := r.find(userId, otherId)
+
+	return i >= 0 && !r.data.friends[i].Pending, nil
+}
+
+// The friendship or request between the two users in either direction, or -1
+func (r friendRepository) find(a uint64, b uint64) int {
+	for i, f := range r.data.friends {
+		if (f.Left == a && f.Right == b) || (f.Left == b && f.Right == a) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type ratingRepository struct {
+	*store
+}
+
+func (r ratingRepository) List(ctx context.Context, userId uint64) ([]Rating, error) {
+	defer r.lock(ctx)()
+
+	ratings := []Rating{}
+
+	for key, rating := range r.data.ratings {
+		if key.UserId == userId {
+			ratings = append(ratings, rating)
+		}
+	}
+
+	sort.Slice(ratings, func(i, j int) bool { return ratings[i].Pool < ratings[j].Pool })
+
+	return ratings, nil
+}
+
+func (r ratingRepository) History(ctx context.Context, userId uint64, pool RatingPool) ([]RatingHistoryEntry, error) {
+	defer r.lock(ctx)()
+
+	return append([]RatingHistoryEntry{}, r.data.ratingHistory[ratingKey{userId, pool}]...), nil
+}
+
+// Transactions already hold the store's only lock
+func (r ratingRepository) FetchForUpdate(ctx context.Context, userId uint64, pool RatingPool) (Rating, error) {
+	defer r.lock(ctx)()
+
+	if rating, ok := r.data.ratings[ratingKey{userId, pool}]; ok {
+		return rating, nil
+	}
+
+	return MakeRatingDefault(pool), nil
+}
+
+func (r ratingRepository) Store(ctx context.Context, userId uint64, gameId uint64, rating Rating) error {
+	defer r.lock(ctx)()
+
+	key := ratingKey{userId, rating.Pool}
+	rating.Games = r.data.ratings[key].Games + 1
+	r.data.ratings[key] = rating
+
+	r.data.ratingHistory[key] = append(r.data.ratingHistory[key], RatingHistoryEntry{
+		GameId:     gameId,
+		Rating:     rating.Rating,
+		Deviation:  rating.Deviation,
+		Volatility: rating.Volatility,
+		CreatedAt:  time.Now(),
+	})
+
+	return nil
+}
+
+type notificationRepository struct {
+	*store
+}
+
+func (r notificationRepository) List(ctx context.Context, userId uint64, unreadOnly bool, limit int) ([]Notification, error) {
+	defer r.lock(ctx)()
+
+	notifications := []Notification{}
+
+	for i := len(r.data.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		n := r.data.notifications[i]
+
+		if n.UserId == userId && (!unreadOnly || !n.Read) {
+			notifications = append(notifications, n)
+		}
+	}
+
+	return notifications, nil
+}
+
+func (r notificationRepository) MarkRead(ctx context.Context, id uint64, userId uint64) error {
+	defer r.lock(ctx)()
+
+	for i, n := range r.data.notifications {
+		if n.Id == id && n.UserId == userId {
+			r.data.notifications[i].Read = true
+			return nil
+		}
+	}
+
+	return sv.NewDoesNotExistError("Notification")
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	. "remotechess/src/rc_server/rcdb/chessboards"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/chessboards"
+)
+
+type chessboardRepository struct {
+	*store
+}
+
+func (r chessboardRepository) Fetch(ctx context.Context, onboardId uint64) (Chessboard, error) {
+	var cb Chessboard
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetChessboardQuery(SELECT_BOARD), onboardId).Scan(&cb.OnboardId, &cb.OwnerId, &cb.FocusedGame, &cb.BotLevel)
+
+	if err == sql.ErrNoRows {
+		return cb, sv.NewDoesNotExistError("Chessboard")
+	} else if err != nil {
+		return cb, sv.NewInternalError("FetchChessboard " + err.Error())
+	}
+
+	return cb, nil
+}
+
+func (r chessboardRepository) Register(ctx context.Context, onboardId uint64, secretHash []byte) (Chessboard, error) {
+	var cb Chessboard
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetChessboardQuery(REGISTER_BOARD), onboardId, secretHash).Scan(&cb.OnboardId, &cb.OwnerId)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return cb, sv.NewAlreadyExistsError("Chessboard")
+	} else if err != nil {
+		return cb, sv.NewInternalError("RegisterNewChessboard " + err.Error())
+	}
+
+	return cb, nil
+}
+
+func (r chessboardRepository) AssignFirstOwner(ctx context.Context, onboardId uint64, ownerId uint64) (bool, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, GetChessboardQuery(ASSIGN_FIRST_OWNER), ownerId, onboardId)
+
+	if err != nil {
+		return false, sv.NewInternalError("AssignFirstOwner " + err.Error())
+	}
+
+	return rowsAffected(res) == 1, nil
+}
+
+func (r chessboardRepository) CreateBot(ctx context.Context, onboardId uint64, level int) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetChessboardQuery(CREATE_BOT_BOARD), onboardId, level); err != nil {
+		return sv.NewInternalError("FetchBotBoard " + err.Error())
+	}
+
+	return nil
+}
+
+func (r chessboardRepository) FetchSecretHash(ctx context.Context, onboardId uint64) ([]byte, error) {
+	var stored []byte
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetChessboardQuery(SELECT_BOARD_SECRET), onboardId).Scan(&stored)
+
+	if err == sql.ErrNoRows {
+		return nil, sv.NewDoesNotExistError("Chessboard")
+	} else if err != nil {
+		return nil, sv.NewInternalError("Authenticate " + err.Error())
+	}
+
+	return stored, nil
+}
+
+func (r chessboardRepository) SetSecretHash(ctx context.Context, onboardId uint64, hash []byte) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetChessboardQuery(SET_BOARD_SECRET), onboardId, hash)
+
+	if err != nil {
+		return sv.NewInternalError("setSecretHash " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Chessboard")
+	}
+
+	return nil
+}
+
+func (r chessboardRepository) SetFocusedGame(ctx context.Context, onboardId uint64, gameId sql.NullInt64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetChessboardQuery(UPDATE_FOCUSED_GAME), onboardId, gameId)
+
+	if err != nil {
+		return sv.NewInternalError("SetFocusedGame " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Chessboard")
+	}
+
+	return nil
+}
+
+func (r chessboardRepository) FocusGame(ctx context.Context, onboardId uint64, gameId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetChessboardQuery(FOCUS_GAME), onboardId, gameId)
+
+	if err != nil {
+		return sv.NewInternalError("FocusGame " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Game")
+	}
+
+	return nil
+}
+
+func (r chessboardRepository) FocusNewGame(ctx context.Context, gameId uint64, whiteId uint64, blackId uint64, onlyIdle bool) ([]uint64, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetChessboardQuery(FOCUS_NEW_GAME), gameId, whiteId, blackId, onlyIdle)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FocusNewGame " + err.Error())
+	}
+
+	return scanIds(rows, "FocusNewGame")
+}
+
+func (r chessboardRepository) Lock(ctx context.Context, onboardIds []uint64) error {
+	ids := make([]int64, len(onboardIds))
+
+	for i, id := range onboardIds {
+		ids[i] = int64(id)
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, GetChessboardQuery(LOCK_BOARDS), pq.Array(ids))
+
+	if err != nil {
+		return sv.NewInternalError("Lock " + err.Error())
+	}
+
+	_, err = scanIds(rows, "Lock")
+
+	return err
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	. "remotechess/src/rc_server/rcdb/events"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/events"
+)
+
+type eventRepository struct {
+	*store
+}
+
+func (r eventRepository) Append(ctx context.Context, ev *Event) error {
+	encoded, err := json.Marshal(ev.Data)
+
+	if err != nil {
+		return sv.NewInternalError("Publish " + err.Error())
+	}
+
+	err = r.conn(ctx).QueryRowContext(ctx, GetEventQuery(CREATE_EVENT), ev.GameId, ev.Kind, encoded).Scan(&ev.Seq, &ev.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return sv.NewDoesNotExistError("Game")
+	} else if err != nil {
+		return sv.NewInternalError("Publish " + err.Error())
+	}
+
+	return nil
+}
+
+func (r eventRepository) ListSince(ctx context.Context, gameId uint64, since uint64) ([]Event, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetEventQuery(GET_EVENTS_SINCE), gameId, since)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchEventsSince " + err.Error())
+	}
+
+	defer rows.Close()
+
+	events := []Event{}
+
+	for rows.Next() {
+		ev := Event{GameId: gameId}
+		var encoded []byte
+
+		if err = rows.Scan(&ev.Seq, &ev.Kind, &encoded, &ev.CreatedAt); err != nil {
+			return nil, sv.NewInternalError("FetchEventsSince " + err.Error())
+		}
+
+		if err = json.Unmarshal(encoded, &ev.Data); err != nil {
+			return nil, sv.NewInternalError("FetchEventsSince " + err.Error())
+		}
+
+		events = append(events, ev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, sv.NewInternalError("FetchEventsSince " + err.Error())
+	}
+
+	return events, nil
+}
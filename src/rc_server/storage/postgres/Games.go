@@ -0,0 +1,461 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "remotechess/src/rc_server/rcdb/games"
+	. "remotechess/src/rc_server/rcdb/matchmaking"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	"remotechess/src/rc_server/service/engine"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type gameRepository struct {
+	*store
+}
+
+func (r gameRepository) Create(ctx context.Context, cgp *ChessGamePersistent) error {
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(CREATE_GAME), cgp.FkWhite, cgp.FkBlack, cgp.Fen,
+		cgp.TcKind, cgp.TcBaseMs, cgp.TcIncrementMs, cgp.TcDaysPerMove, cgp.WhiteTimeMs, cgp.BlackTimeMs, cgp.TurnStartedAt,
+		cgp.StartFen, cgp.Variant, cgp.Rated, cgp.Visibility, cgp.BroadcastDelayMs, cgp.Takebacks).Scan(&cgp.Id)
+
+	if err != nil {
+		return sv.NewInternalError("CreateChessGame " + err.Error())
+	}
+
+	return nil
+}
+
+func (r gameRepository) Import(ctx context.Context, cgp *ChessGamePersistent) error {
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(IMPORT_GAME), cgp.FkWhite, cgp.FkBlack, cgp.Fen, cgp.CurrentMove,
+		cgp.Outcome, cgp.Method, cgp.PgnTags, cgp.StartFen, cgp.Plies).Scan(&cgp.Id, &cgp.CreatedAt)
+
+	if err != nil {
+		return sv.NewInternalError("ImportPGN " + err.Error())
+	}
+
+	return nil
+}
+
+func (r gameRepository) Fetch(ctx context.Context, id uint64) (ChessGamePersistent, error) {
+	var cgp ChessGamePersistent
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(SELECT_GAME), id).Scan(&cgp.Id, &cgp.FkWhite, &cgp.FkBlack, &cgp.Fen,
+		&cgp.CurrentMove, &cgp.Outcome, &cgp.Method, &cgp.OfferedDraw, &cgp.OfferingPlayer,
+		&cgp.TcKind, &cgp.TcBaseMs, &cgp.TcIncrementMs, &cgp.TcDaysPerMove, &cgp.WhiteTimeMs, &cgp.BlackTimeMs, &cgp.TurnStartedAt,
+		&cgp.CreatedAt, &cgp.Archived, &cgp.PgnTags, &cgp.StartFen, &cgp.Variant, &cgp.Rated, &cgp.Visibility, &cgp.BroadcastDelayMs,
+		&cgp.Takebacks, &cgp.TakebackPlies, &cgp.TakebackPlayer, &cgp.MirroredPly, &cgp.Version, &cgp.Plies)
+
+	if err == sql.ErrNoRows {
+		return cgp, sv.NewDoesNotExistError("Game")
+	} else if err != nil {
+		return cgp, sv.NewInternalError("FetchChessGame " + err.Error())
+	}
+
+	return cgp, nil
+}
+
+func (r gameRepository) Update(ctx context.Context, cgp ChessGamePersistent) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(UPDATE_GAME), cgp.Id, cgp.Fen, cgp.CurrentMove, cgp.Outcome, cgp.Method,
+		cgp.WhiteTimeMs, cgp.BlackTimeMs, cgp.TurnStartedAt, cgp.Version, cgp.Plies)
+
+	if err != nil {
+		return sv.NewInternalError("SaveChessGame " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		// The game was loaded before it is saved, so it still exists but at another version
+		return sv.NewConflictError("Game")
+	}
+
+	return nil
+}
+
+func (r gameRepository) Adjudicate(ctx context.Context, id uint64, outcome GameOutcome, method GameMethod, whiteMs int64, blackMs int64) (bool, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(ADJUDICATE_GAME), id, outcome, method, whiteMs, blackMs)
+
+	if err != nil {
+		return false, sv.NewInternalError("CheckFlag " + err.Error())
+	}
+
+	return rowsAffected(res) == 1, nil
+}
+
+func (r gameRepository) SetDraw(ctx context.Context, id uint64, offered GameMethod, player PlayerColor) error {
+	return r.update(ctx, "SetDraw", UPDATE_DRAW, id, offered, player)
+}
+
+func (r gameRepository) SetTakeback(ctx context.Context, id uint64, plies int, player PlayerColor) error {
+	return r.update(ctx, "SetTakeback", UPDATE_TAKEBACK, id, plies, player)
+}
+
+func (r gameRepository) SetVisibility(ctx context.Context, id uint64, visibility GameVisibility) error {
+	return r.update(ctx, "SetVisibility", UPDATE_VISIBILITY, id, visibility)
+}
+
+func (r gameRepository) ResetMirrored(ctx context.Context, id uint64, ply int) error {
+	return r.update(ctx, "ResetMirrored", RESET_MIRRORED, id, ply)
+}
+
+// Does nothing when the ply is already confirmed, so it affects no rows then
+func (r gameRepository) ConfirmMirrored(ctx context.Context, id uint64, ply int) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(CONFIRM_MIRRORED), id, ply); err != nil {
+		return sv.NewInternalError("ConfirmMirrored " + err.Error())
+	}
+
+	return nil
+}
+
+// Run an update of a single game
+func (r gameRepository) update(ctx context.Context, caller string, q GameQuery, args ...interface{}) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(q), args...)
+
+	if err != nil {
+		return sv.NewInternalError(caller + " " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Game")
+	}
+
+	return nil
+}
+
+func (r gameRepository) MarkRatingsApplied(ctx context.Context, id uint64) (bool, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(MARK_RATINGS_APPLIED), id)
+
+	if err != nil {
+		return false, sv.NewInternalError("applyRatings " + err.Error())
+	}
+
+	return rowsAffected(res) == 1, nil
+}
+
+func (r gameRepository) Search(ctx context.Context, userId sql.NullInt64, boardId sql.NullInt64, filter GameFilter) ([]GameSummary, int, error) {
+	var result, method, variant interface{}
+
+	if filter.Result != "" {
+		result = filter.Result
+	}
+
+	if filter.Method != nil {
+		method = *filter.Method
+	}
+
+	if filter.Variant != nil {
+		variant = *filter.Variant
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(SEARCH_GAMES), userId, boardId, filter.Color, filter.Opponent, result, method, variant,
+		filter.From, filter.To, filter.Limit, filter.Offset)
+
+	if err != nil {
+		return nil, 0, sv.NewInternalError("searchGames " + err.Error())
+	}
+
+	defer rows.Close()
+
+	games := []GameSummary{}
+	total := 0
+
+	for rows.Next() {
+		var gs GameSummary
+
+		err := rows.Scan(&gs.Id, &gs.WhiteBoard, &gs.BlackBoard, &gs.WhiteUserId, &gs.WhiteName, &gs.BlackUserId, &gs.BlackName,
+			&gs.Outcome, &gs.Method, &gs.Variant, &gs.Rated, &gs.TimeControl, &gs.MoveCount, &gs.CreatedAt, &gs.EndedAt, &total)
+
+		if err != nil {
+			return nil, 0, sv.NewInternalError("searchGames " + err.Error())
+		}
+
+		games = append(games, gs)
+	}
+
+	return games, total, nil
+}
+
+func (r gameRepository) ListOngoing(ctx context.Context, onboardId uint64) ([]uint64, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(SELECT_BOARD_ONGOING_GAMES), onboardId)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchOngoingGames " + err.Error())
+	}
+
+	return scanIds(rows, "FetchOngoingGames")
+}
+
+func (r gameRepository) CountOngoing(ctx context.Context, onboardId uint64) (int, int, error) {
+	var correspondence, live int
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(COUNT_BOARD_ONGOING_GAMES), onboardId).Scan(&correspondence, &live)
+
+	if err != nil {
+		return 0, 0, sv.NewInternalError("CheckGameLimits " + err.Error())
+	}
+
+	return correspondence, live, nil
+}
+
+func (r gameRepository) ListOverdueCorrespondence(ctx context.Context) ([]uint64, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(SELECT_OVERDUE_CORRESPONDENCE))
+
+	if err != nil {
+		return nil, sv.NewInternalError("adjudicateOverdueGames " + err.Error())
+	}
+
+	return scanIds(rows, "adjudicateOverdueGames")
+}
+
+func (r gameRepository) ListFlaggedLive(ctx context.Context) ([]uint64, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(SELECT_FLAGGED_LIVE))
+
+	if err != nil {
+		return nil, sv.NewInternalError("adjudicateFlaggedGames " + err.Error())
+	}
+
+	return scanIds(rows, "adjudicateFlaggedGames")
+}
+
+func (r gameRepository) ListAwaitingBot(ctx context.Context) ([]uint64, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(SELECT_AWAITING_BOT))
+
+	if err != nil {
+		return nil, sv.NewInternalError("scheduleBotMoves " + err.Error())
+	}
+
+	return scanIds(rows, "scheduleBotMoves")
+}
+
+func (r gameRepository) QueueDeadlineReminders(ctx context.Context, kind NotificationKind, lead time.Duration) ([]Notification, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(QUEUE_DEADLINE_REMINDERS), kind, lead.Milliseconds())
+
+	if err != nil {
+		return nil, sv.NewInternalError("queueDeadlineReminders " + err.Error())
+	}
+
+	defer rows.Close()
+
+	queued := []Notification{}
+
+	for rows.Next() {
+		var n Notification
+
+		if err = rows.Scan(&n.Id, &n.UserId, &n.BoardId, &n.GameId, &n.Kind, &n.Deadline, &n.CreatedAt); err != nil {
+			return nil, sv.NewInternalError("queueDeadlineReminders " + err.Error())
+		}
+
+		queued = append(queued, n)
+	}
+
+	return queued, nil
+}
+
+func (r gameRepository) ColorBalance(ctx context.Context, onboardId uint64) (int, error) {
+	var balance int
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetMatchmakingQuery(GET_COLOR_BALANCE), onboardId).Scan(&balance)
+
+	if err != nil {
+		return 0, sv.NewInternalError("colorBalance " + err.Error())
+	}
+
+	return balance, nil
+}
+
+type moveRepository struct {
+	*store
+}
+
+func (r moveRepository) Create(ctx context.Context, gameId uint64, m MoveRecord) error {
+	_, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(CREATE_MOVE), gameId, m.Player, m.From, m.To, m.Piece, m.Tags, m.Promotion)
+
+	if err != nil {
+		return sv.NewInternalError("CreateMove " + err.Error())
+	}
+
+	return nil
+}
+
+func (r moveRepository) List(ctx context.Context, gameId uint64) ([]string, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(GET_MOVES), gameId)
+
+	if err != nil {
+		return nil, sv.NewInternalError(err.Error())
+	}
+
+	defer rows.Close()
+
+	moves := []string{}
+
+	for rows.Next() {
+		var from, to, promotion string
+
+		if err = rows.Scan(&from, &to, &promotion); err != nil {
+			return nil, sv.NewInternalError(err.Error())
+		}
+
+		moves = append(moves, (from + to + promotion))
+	}
+
+	return moves, nil
+}
+
+func (r moveRepository) DeleteLast(ctx context.Context, gameId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(DELETE_LAST_MOVE), gameId)
+
+	if err != nil {
+		return sv.NewInternalError("undoMoves " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Move")
+	}
+
+	return nil
+}
+
+func (r moveRepository) SaveAnalysis(ctx context.Context, gameId uint64, analysis []MoveAnalysis) error {
+	for _, m := range analysis {
+		_, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(UPDATE_MOVE_ANALYSIS), gameId, m.Ply,
+			m.Score.Centipawns, m.Score.Mate, m.BestMove, m.Loss, m.Classification)
+
+		if err != nil {
+			return sv.NewInternalError("saveAnalysis " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (r moveRepository) FetchAnalysis(ctx context.Context, gameId uint64) ([]MoveAnalysis, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(GET_MOVE_ANALYSIS), gameId)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchAnalysis " + err.Error())
+	}
+
+	defer rows.Close()
+
+	analysis := []MoveAnalysis{}
+
+	for rows.Next() {
+		var from, to, promotion string
+		var player PlayerColor
+		var evalCp, evalMate, loss sql.NullInt64
+		var bestMove sql.NullString
+		var classification NullableMoveClassification
+
+		err = rows.Scan(&from, &to, &promotion, &player, &evalCp, &evalMate, &bestMove, &loss, &classification)
+
+		if err != nil {
+			return nil, sv.NewInternalError("FetchAnalysis " + err.Error())
+		}
+
+		if !classification.Valid {
+			return nil, nil
+		}
+
+		analysis = append(analysis, MoveAnalysis{
+			Ply:            len(analysis),
+			Move:           from + to + promotion,
+			Player:         player,
+			Score:          engine.Score{Centipawns: int(evalCp.Int64), Mate: int(evalMate.Int64)},
+			BestMove:       bestMove.String,
+			Loss:           int(loss.Int64),
+			Classification: classification.MoveClassification,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, sv.NewInternalError("FetchAnalysis " + err.Error())
+	}
+
+	return analysis, nil
+}
+
+func (r moveRepository) FetchRequest(ctx context.Context, onboardId uint64, key string, ttl time.Duration) (MoveRequest, error) {
+	var req MoveRequest
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(SELECT_MOVE_REQUEST), onboardId, key, ttl.Milliseconds()).
+		Scan(&req.GameId, &req.Move)
+
+	if err == sql.ErrNoRows {
+		return req, sv.NewDoesNotExistError("Move request")
+	} else if err != nil {
+		return req, sv.NewInternalError("FetchRequest " + err.Error())
+	}
+
+	return req, nil
+}
+
+func (r moveRepository) CreateRequest(ctx context.Context, onboardId uint64, key string, req MoveRequest, ttl time.Duration) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(PURGE_MOVE_REQUESTS), onboardId, ttl.Milliseconds()); err != nil {
+		return sv.NewInternalError("CreateRequest " + err.Error())
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(CREATE_MOVE_REQUEST), onboardId, key, req.GameId, req.Move); err != nil {
+		return sv.NewInternalError("CreateRequest " + err.Error())
+	}
+
+	return nil
+}
+
+type chatRepository struct {
+	*store
+}
+
+func (r chatRepository) Create(ctx context.Context, gameId uint64, msg *ChatMessage) error {
+	err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(CREATE_CHAT_MESSAGE), gameId, msg.Player, msg.PresetId, msg.Body).Scan(&msg.Id, &msg.CreatedAt)
+
+	if err != nil {
+		return sv.NewInternalError("sendChat " + err.Error())
+	}
+
+	return nil
+}
+
+func (r chatRepository) List(ctx context.Context, gameId uint64, viewer PlayerColor) ([]ChatMessage, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetGameQuery(GET_CHAT_MESSAGES), gameId, viewer)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchChat " + err.Error())
+	}
+
+	defer rows.Close()
+
+	messages := []ChatMessage{}
+
+	for rows.Next() {
+		var msg ChatMessage
+
+		if err := rows.Scan(&msg.Id, &msg.Player, &msg.PresetId, &msg.Body, &msg.CreatedAt); err != nil {
+			return nil, sv.NewInternalError("FetchChat " + err.Error())
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+func (r chatRepository) SetMuted(ctx context.Context, gameId uint64, player PlayerColor, muted bool) error {
+	query := MUTE_CHAT
+
+	if !muted {
+		query = UNMUTE_CHAT
+	}
+
+	if _, err := r.conn(ctx).ExecContext(ctx, GetGameQuery(query), gameId, player); err != nil {
+		return sv.NewInternalError("MuteOpponent " + err.Error())
+	}
+
+	return nil
+}
+
+func (r chatRepository) IsMuted(ctx context.Context, gameId uint64, player PlayerColor) (bool, error) {
+	var muted bool
+
+	if err := r.conn(ctx).QueryRowContext(ctx, GetGameQuery(IS_CHAT_MUTED), gameId, player).Scan(&muted); err != nil {
+		return false, sv.NewInternalError("isChatMuted " + err.Error())
+	}
+
+	return muted, nil
+}
@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	. "remotechess/src/rc_server/rcdb/invitations"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/common"
+	. "remotechess/src/rc_server/service/games"
+	. "remotechess/src/rc_server/service/invitations"
+)
+
+type invitationRepository struct {
+	*store
+}
+
+func (r invitationRepository) CreateWithCode(ctx context.Context, senderId uint64, recipientColor PlayerColor, settings GameSettings) (int, error) {
+	var inviteCode int
+
+	// The stored function only knows about the sender and color, the settings follow in the same transaction
+	err := r.WithinTx(ctx, func(ctx context.Context) error {
+		err := r.conn(ctx).QueryRowContext(ctx, GetInvitationQuery(CREATE_INVITE_WITH_CODE), senderId, recipientColor).Scan(&inviteCode)
+
+		if err != nil {
+			return sv.NewInternalError("CreateGameInviteWithCode " + err.Error())
+		}
+
+		if _, err = r.conn(ctx).ExecContext(ctx, GetInvitationQuery(SET_CODE_INVITE_SETTINGS), inviteCode, settings); err != nil {
+			return sv.NewInternalError("CreateGameInviteWithCode " + err.Error())
+		}
+
+		return nil
+	})
+
+	return inviteCode, err
+}
+
+func (r invitationRepository) FetchCodeInvite(ctx context.Context, code int) (ReceivedInvite, error) {
+	var invite ReceivedInvite
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetInvitationQuery(GET_CODE_INVITE_SENDER_BOARD), code).Scan(&invite.Sender.OnboardId,
+		&invite.Sender.OwnerId, &invite.Sender.FocusedGame, &invite.RecipientColor, &invite.Settings)
+
+	if err == sql.ErrNoRows {
+		return invite, sv.NewDoesNotExistError("Invite")
+	} else if err != nil {
+		return invite, sv.NewInternalError("JoinCodeInvite " + err.Error())
+	}
+
+	return invite, nil
+}
+
+func (r invitationRepository) CancelCode(ctx context.Context, code int, ownerId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(CANCEL_CODE_INVITE), code, ownerId)
+
+	if err != nil {
+		return sv.NewInternalError(err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	return nil
+}
+
+func (r invitationRepository) Send(ctx context.Context, senderId uint64, recipientId uint64, recipientColor PlayerColor, settings GameSettings) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(SEND_INVITE), senderId, recipientId, recipientColor, settings)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return sv.NewGenericError("There is already a pending invite for that user", 409, sv.NOT_SENSITIVE)
+	} else if err != nil {
+		return sv.NewInternalError(err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewInternalError("SendInvite did not affect 1 row")
+	}
+
+	return nil
+}
+
+func (r invitationRepository) CancelSent(ctx context.Context, senderId uint64, recipientId uint64) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(CANCEL_SENT_INVITE), senderId, recipientId); err != nil {
+		return sv.NewInternalError(err.Error())
+	}
+
+	return nil
+}
+
+func (r invitationRepository) ListPending(ctx context.Context, userId uint64) ([]PendingInvite, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetInvitationQuery(GET_PENDING_INVITES), userId)
+
+	if err != nil {
+		return nil, sv.NewInternalError(err.Error())
+	}
+
+	defer rows.Close()
+
+	invites := []PendingInvite{}
+
+	for rows.Next() {
+		var pending PendingInvite
+		var recipientColor NullablePlayerColor
+
+		err = rows.Scan(&pending.Id, &pending.Sender.Id, &pending.Sender.Username, &recipientColor, &pending.Settings)
+
+		if err != nil {
+			return nil, sv.NewInternalError(err.Error())
+		}
+
+		pending.YourColor = recipientColor.ToPointer()
+		invites = append(invites, pending)
+	}
+
+	return invites, nil
+}
+
+func (r invitationRepository) FetchSent(ctx context.Context, id uint64, recipientBoard uint64, recipientColor PlayerColor) (ReceivedInvite, error) {
+	invite := ReceivedInvite{RecipientColor: recipientColor}
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetInvitationQuery(GET_SENT_INVITE_SENDER_BOARD), id, recipientBoard, recipientColor).
+		Scan(&invite.Sender.OnboardId, &invite.Sender.OwnerId, &invite.Sender.FocusedGame, &invite.Settings)
+
+	if err == sql.ErrNoRows {
+		return invite, sv.NewDoesNotExistError("Invite")
+	} else if err != nil {
+		return invite, sv.NewInternalError("AcceptInvite " + err.Error())
+	}
+
+	return invite, nil
+}
+
+func (r invitationRepository) Reject(ctx context.Context, id uint64, recipientId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(REJECT_INVITE), id, recipientId)
+
+	if err != nil {
+		return sv.NewInternalError("RejectInvite " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	return nil
+}
+
+func (r invitationRepository) Delete(ctx context.Context, id uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(DELETE_INVITE), id)
+
+	if err != nil {
+		return sv.NewInternalError("DeleteInvite " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Invite")
+	}
+
+	return nil
+}
+
+func (r invitationRepository) ClearSent(ctx context.Context, senderId uint64) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetInvitationQuery(CLEAR_INVITES), senderId); err != nil {
+		return sv.NewInternalError("ClearInvites " + err.Error())
+	}
+
+	return nil
+}
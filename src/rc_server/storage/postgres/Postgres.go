@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	sv "remotechess/src/rc_server/service"
+	"remotechess/src/rc_server/storage"
+)
+
+type txKey struct{}
+
+// What both a connection pool and a transaction can run queries on
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type store struct {
+	db *sql.DB
+}
+
+// Repositories that keep everything in Postgres using the queries in rcdb
+func NewRepositories(db *sql.DB) storage.Repositories {
+	s := &store{db}
+
+	return storage.Repositories{
+		Transactor:    s,
+		Games:         gameRepository{s},
+		Moves:         moveRepository{s},
+		Chat:          chatRepository{s},
+		Chessboards:   chessboardRepository{s},
+		Users:         userRepository{s},
+		Friends:       friendRepository{s},
+		Ratings:       ratingRepository{s},
+		Notifications: notificationRepository{s},
+		Invitations:   invitationRepository{s},
+		Events:        eventRepository{s},
+	}
+}
+
+// Queries run in the transaction ctx carries, if there is one
+func (s *store) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return s.db
+}
+
+// A call made inside another transaction joins it rather than starting its own
+func (s *store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return sv.NewInternalError("WithinTx " + err.Error())
+	}
+
+	defer tx.Rollback()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return sv.NewInternalError("WithinTx " + err.Error())
+	}
+
+	return nil
+}
+
+func rowsAffected(res sql.Result) int64 {
+	n, _ := res.RowsAffected()
+	return n
+}
+
+func scanIds(rows *sql.Rows, caller string) ([]uint64, error) {
+	defer rows.Close()
+
+	ids := []uint64{}
+
+	for rows.Next() {
+		var id uint64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, sv.NewInternalError(caller + " " + err.Error())
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, sv.NewInternalError(caller + " " + err.Error())
+	}
+
+	return ids, nil
+}
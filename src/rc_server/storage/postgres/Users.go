@@ -0,0 +1,295 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	. "remotechess/src/rc_server/rcdb/usercore"
+	sv "remotechess/src/rc_server/service"
+	. "remotechess/src/rc_server/service/usercore"
+)
+
+type userRepository struct {
+	*store
+}
+
+func (r userRepository) Fetch(ctx context.Context, id uint64) (UserCore, error) {
+	var user UserCore
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(SELECT_USER), id).Scan(&user.Id, &user.Email, &user.Username)
+
+	if err == sql.ErrNoRows {
+		return user, sv.NewDoesNotExistError("User")
+	} else if err != nil {
+		return user, sv.NewInternalError("FetchUserCore " + err.Error())
+	}
+
+	return user, nil
+}
+
+func (r userRepository) Register(ctx context.Context, user *UserCore) error {
+	err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(REGISTER_USER), user.Email, user.Username, user.Password).Scan(&user.Id)
+
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return sv.NewAlreadyExistsError("User with that email or username")
+	} else if err != nil {
+		return sv.NewInternalError("RegisterUser " + err.Error())
+	}
+
+	return nil
+}
+
+func (r userRepository) FetchByLogin(ctx context.Context, login string) (UserCore, error) {
+	var user UserCore
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(SELECT_USER_LOGIN), login).Scan(&user.Id, &user.Email, &user.Username, &user.Password)
+
+	if err == sql.ErrNoRows {
+		return user, sv.NewDoesNotExistError("User")
+	} else if err != nil {
+		return user, sv.NewInternalError("Login " + err.Error())
+	}
+
+	return user, nil
+}
+
+func (r userRepository) CreateSession(ctx context.Context, tokenHash []byte, userId uint64, expiresAt time.Time) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(CREATE_SESSION), tokenHash, userId, expiresAt); err != nil {
+		return sv.NewInternalError("createSession " + err.Error())
+	}
+
+	return nil
+}
+
+func (r userRepository) FetchSessionUser(ctx context.Context, tokenHash []byte) (UserCore, error) {
+	var user UserCore
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(SELECT_SESSION_USER), tokenHash).Scan(&user.Id, &user.Email, &user.Username)
+
+	if err == sql.ErrNoRows {
+		return user, sv.NewDoesNotExistError("Session")
+	} else if err != nil {
+		return user, sv.NewInternalError("FetchSessionUser " + err.Error())
+	}
+
+	return user, nil
+}
+
+func (r userRepository) DeleteSession(ctx context.Context, tokenHash []byte) error {
+	if _, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(DELETE_SESSION), tokenHash); err != nil {
+		return sv.NewInternalError("Logout " + err.Error())
+	}
+
+	return nil
+}
+
+type friendRepository struct {
+	*store
+}
+
+func (r friendRepository) Request(ctx context.Context, userId uint64, friendId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(SEND_FRIEND_REQUEST), userId, friendId)
+
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "23503":
+			return sv.NewDoesNotExistError("User")
+		case "23505":
+			return sv.NewAlreadyExistsError("Friendship")
+		case "23514":
+			return sv.NewGenericError("Cannot befriend yourself", 405, sv.NOT_SENSITIVE)
+		}
+	}
+
+	if err != nil {
+		return sv.NewInternalError("SendFriendRequest " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewInternalError("SendFriendRequest Could Not Be Completed")
+	}
+
+	return nil
+}
+
+func (r friendRepository) List(ctx context.Context, userId uint64, pending bool) ([]UserCore, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetUserCoreQuery(GET_FRIENDS), userId, pending)
+
+	if err != nil {
+		return nil, sv.NewInternalError(err.Error())
+	}
+
+	defer rows.Close()
+
+	friends := []UserCore{}
+
+	for rows.Next() {
+		var friend UserCore
+
+		if err = rows.Scan(&friend.Id, &friend.Username); err != nil {
+			return nil, sv.NewInternalError(err.Error())
+		}
+
+		friends = append(friends, friend)
+	}
+
+	return friends, nil
+}
+
+func (r friendRepository) Accept(ctx context.Context, requesterId uint64, userId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(ACCEPT_FRIEND_REQUEST), requesterId, userId)
+
+	if err != nil {
+		return sv.NewInternalError(err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Friend Request")
+	}
+
+	return nil
+}
+
+func (r friendRepository) Remove(ctx context.Context, friendId uint64, userId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(REMOVE_FRIEND), friendId, userId)
+
+	if err != nil {
+		return sv.NewInternalError(err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Friend")
+	}
+
+	return nil
+}
+
+func (r friendRepository) AreFriends(ctx context.Context, userId uint64, otherId uint64) (bool, error) {
+	var friends bool
+
+	if err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(ARE_FRIENDS), userId, otherId).Scan(&friends); err != nil {
+		return false, sv.NewInternalError("IsFriendsWith " + err.Error())
+	}
+
+	return friends, nil
+}
+
+type ratingRepository struct {
+	*store
+}
+
+func (r ratingRepository) List(ctx context.Context, userId uint64) ([]Rating, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetUserCoreQuery(SELECT_RATINGS), userId)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchRatings " + err.Error())
+	}
+
+	defer rows.Close()
+
+	ratings := []Rating{}
+
+	for rows.Next() {
+		var rating Rating
+
+		if err := rows.Scan(&rating.Pool, &rating.Rating, &rating.Deviation, &rating.Volatility, &rating.Games); err != nil {
+			return nil, sv.NewInternalError("FetchRatings " + err.Error())
+		}
+
+		ratings = append(ratings, rating)
+	}
+
+	return ratings, nil
+}
+
+func (r ratingRepository) History(ctx context.Context, userId uint64, pool RatingPool) ([]RatingHistoryEntry, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetUserCoreQuery(SELECT_RATING_HISTORY), userId, pool)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchRatingHistory " + err.Error())
+	}
+
+	defer rows.Close()
+
+	history := []RatingHistoryEntry{}
+
+	for rows.Next() {
+		var h RatingHistoryEntry
+
+		if err := rows.Scan(&h.GameId, &h.Rating, &h.Deviation, &h.Volatility, &h.CreatedAt); err != nil {
+			return nil, sv.NewInternalError("FetchRatingHistory " + err.Error())
+		}
+
+		history = append(history, h)
+	}
+
+	return history, nil
+}
+
+func (r ratingRepository) FetchForUpdate(ctx context.Context, userId uint64, pool RatingPool) (Rating, error) {
+	rating := MakeRatingDefault(pool)
+
+	err := r.conn(ctx).QueryRowContext(ctx, GetUserCoreQuery(SELECT_RATING_FOR_UPDATE), userId, pool).
+		Scan(&rating.Rating, &rating.Deviation, &rating.Volatility, &rating.Games)
+
+	if err != nil && err != sql.ErrNoRows {
+		return rating, sv.NewInternalError("fetchRatingForUpdate " + err.Error())
+	}
+
+	return rating, nil
+}
+
+func (r ratingRepository) Store(ctx context.Context, userId uint64, gameId uint64, rating Rating) error {
+	conn := r.conn(ctx)
+
+	_, err := conn.ExecContext(ctx, GetUserCoreQuery(UPSERT_RATING), userId, rating.Pool, rating.Rating, rating.Deviation, rating.Volatility)
+
+	if err != nil {
+		return sv.NewInternalError("storeRating " + err.Error())
+	}
+
+	_, err = conn.ExecContext(ctx, GetUserCoreQuery(CREATE_RATING_HISTORY), userId, rating.Pool, gameId, rating.Rating, rating.Deviation, rating.Volatility)
+
+	if err != nil {
+		return sv.NewInternalError("storeRating " + err.Error())
+	}
+
+	return nil
+}
+
+type notificationRepository struct {
+	*store
+}
+
+func (r notificationRepository) List(ctx context.Context, userId uint64, unreadOnly bool, limit int) ([]Notification, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, GetUserCoreQuery(SELECT_NOTIFICATIONS), userId, unreadOnly, limit)
+
+	if err != nil {
+		return nil, sv.NewInternalError("FetchNotifications " + err.Error())
+	}
+
+	defer rows.Close()
+
+	notifications := []Notification{}
+
+	for rows.Next() {
+		var n Notification
+
+		if err := rows.Scan(&n.Id, &n.UserId, &n.BoardId, &n.GameId, &n.Kind, &n.Deadline, &n.CreatedAt, &n.Read); err != nil {
+			return nil, sv.NewInternalError("FetchNotifications " + err.Error())
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+func (r notificationRepository) MarkRead(ctx context.Context, id uint64, userId uint64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, GetUserCoreQuery(MARK_NOTIFICATION_READ), id, userId)
+
+	if err != nil {
+		return sv.NewInternalError("MarkNotificationRead " + err.Error())
+	} else if rowsAffected(res) != 1 {
+		return sv.NewDoesNotExistError("Notification")
+	}
+
+	return nil
+}
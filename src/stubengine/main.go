@@ -0,0 +1,90 @@
+// A stand-in UCI engine for running the server without a real one installed.
+// It answers just enough of the protocol for the server's engine pool: every
+// position is scored as level and the first legal move is played.
+//
+//	go build -o stubengine ./src/stubengine
+//
+// then configure the server's engine path to point at the binary.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+func main() {
+	game := chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			fmt.Println("id name RemoteChess stub engine")
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "ucinewgame":
+			game = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+		case "position":
+			game = setPosition(fields[1:])
+		case "go":
+			fmt.Println(search(game))
+		case "quit":
+			return
+		}
+	}
+}
+
+// Parse the arguments of "position startpos|fen <fen> [moves ...]"
+func setPosition(args []string) *chess.Game {
+	game := chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+	i := 0
+
+	if len(args) > 0 && args[0] == "fen" {
+		end := 1
+
+		for end < len(args) && args[end] != "moves" {
+			end++
+		}
+
+		if fen, err := chess.FEN(strings.Join(args[1:end], " ")); err == nil {
+			game = chess.NewGame(chess.UseNotation(chess.UCINotation{}), fen)
+		}
+
+		i = end
+	} else if len(args) > 0 && args[0] == "startpos" {
+		i = 1
+	}
+
+	if i < len(args) && args[i] == "moves" {
+		for _, m := range args[i+1:] {
+			if _, err := game.MoveStr(m); err != nil {
+				break
+			}
+		}
+	}
+
+	return game
+}
+
+func search(game *chess.Game) string {
+	moves := game.Position().ValidMoves()
+
+	if len(moves) == 0 {
+		return "info depth 0 score cp 0\nbestmove (none)"
+	}
+
+	best := chess.UCINotation{}.Encode(game.Position(), moves[0])
+
+	return "info depth 1 score cp 0 pv " + best + "\nbestmove " + best
+}